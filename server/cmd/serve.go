@@ -153,10 +153,14 @@ func (c *serve) Start(cmd *cobra.Command) {
 	)
 	c.managers.desktop.Start()
 
-	c.managers.capture = capture.New(
+	var err error
+	c.managers.capture, err = capture.New(
 		c.managers.desktop,
 		&c.configs.Capture,
 	)
+	if err != nil {
+		c.logger.Panic().Err(err).Msg("unable to create capture manager")
+	}
 	c.managers.capture.Start()
 
 	c.managers.webRTC = webrtc.New(
@@ -179,6 +183,7 @@ func (c *serve) Start(cmd *cobra.Command) {
 		c.managers.member,
 		c.managers.desktop,
 		c.managers.capture,
+		c.managers.webRTC,
 	)
 
 	c.managers.plugins = plugins.New(
@@ -0,0 +1,62 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
+)
+
+func appError(err error) error {
+	switch {
+	case errors.Is(err, types.ErrAppNotFound):
+		return utils.HttpNotFound("app not found").WithInternalErr(err)
+	case errors.Is(err, types.ErrAppAlreadyRunning), errors.Is(err, types.ErrAppNotRunning):
+		return utils.HttpUnprocessableEntity(err.Error()).WithInternalErr(err)
+	default:
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+}
+
+func (h *RoomHandler) appsList(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.desktop.Apps())
+}
+
+func (h *RoomHandler) appRead(w http.ResponseWriter, r *http.Request) error {
+	app, err := h.desktop.App(chi.URLParam(r, "appName"))
+	if err != nil {
+		return appError(err)
+	}
+
+	return utils.HttpSuccess(w, app)
+}
+
+func (h *RoomHandler) appStart(w http.ResponseWriter, r *http.Request) error {
+	name := chi.URLParam(r, "appName")
+	if err := h.desktop.AppStart(name); err != nil {
+		return appError(err)
+	}
+
+	return h.appRead(w, r)
+}
+
+func (h *RoomHandler) appStop(w http.ResponseWriter, r *http.Request) error {
+	name := chi.URLParam(r, "appName")
+	if err := h.desktop.AppStop(name); err != nil {
+		return appError(err)
+	}
+
+	return h.appRead(w, r)
+}
+
+func (h *RoomHandler) appRestart(w http.ResponseWriter, r *http.Request) error {
+	name := chi.URLParam(r, "appName")
+	if err := h.desktop.AppRestart(name); err != nil {
+		return appError(err)
+	}
+
+	return h.appRead(w, r)
+}
@@ -1,24 +1,41 @@
 package room
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi"
+
+	"m1k1o/neko/pkg/types"
 	"m1k1o/neko/pkg/types/event"
-	"m1k1o/neko/pkg/types/message"
 	"m1k1o/neko/pkg/utils"
 )
 
 type BroadcastStatusPayload struct {
 	URL      string `json:"url,omitempty"`
 	IsActive bool   `json:"is_active"`
+	// health of the default output
+	Error   string  `json:"error,omitempty"`
+	Uptime  float64 `json:"uptime,omitempty"`
+	Bitrate int     `json:"bitrate,omitempty"`
+}
+
+type BroadcastOutputPayload struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// start output right after it is created
+	Start bool `json:"start,omitempty"`
 }
 
 func (h *RoomHandler) broadcastStatus(w http.ResponseWriter, r *http.Request) error {
-	broadcast := h.capture.Broadcast()
+	status := h.capture.Broadcast().Status()
 
 	return utils.HttpSuccess(w, BroadcastStatusPayload{
-		IsActive: broadcast.Started(),
-		URL:      broadcast.Url(),
+		IsActive: status.IsActive,
+		URL:      status.URL,
+		Error:    status.Error,
+		Uptime:   status.Uptime,
+		Bitrate:  status.Bitrate,
 	})
 }
 
@@ -41,13 +58,7 @@ func (h *RoomHandler) broadcastStart(w http.ResponseWriter, r *http.Request) err
 		return utils.HttpInternalServerError().WithInternalErr(err)
 	}
 
-	h.sessions.AdminBroadcast(
-		event.BROADCAST_STATUS,
-		message.BroadcastStatus{
-			IsActive: broadcast.Started(),
-			URL:      broadcast.Url(),
-		})
-
+	h.broadcastStatusBroadcast()
 	return utils.HttpSuccess(w)
 }
 
@@ -59,12 +70,122 @@ func (h *RoomHandler) broadcastStop(w http.ResponseWriter, r *http.Request) erro
 
 	broadcast.Stop()
 
-	h.sessions.AdminBroadcast(
-		event.BROADCAST_STATUS,
-		message.BroadcastStatus{
-			IsActive: broadcast.Started(),
-			URL:      broadcast.Url(),
-		})
+	h.broadcastStatusBroadcast()
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) broadcastOutputsList(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.capture.Broadcast().Outputs())
+}
+
+func (h *RoomHandler) broadcastOutputCreate(w http.ResponseWriter, r *http.Request) error {
+	data := &BroadcastOutputPayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if data.Name == "" {
+		return utils.HttpBadRequest("missing output name")
+	}
+
+	if data.URL == "" {
+		return utils.HttpBadRequest("missing output URL")
+	}
+
+	broadcast := h.capture.Broadcast()
+
+	err := broadcast.AddOutput(data.Name, data.URL)
+	if errors.Is(err, types.ErrBroadcastOutputAlreadyExists) {
+		return utils.HttpUnprocessableEntity("output with this name already exists").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpBadRequest("invalid output").WithInternalErr(err)
+	}
+
+	if data.Start {
+		if err := broadcast.StartOutput(data.Name); err != nil {
+			h.broadcastStatusBroadcast()
+			return utils.HttpInternalServerError().WithInternalErr(err)
+		}
+	}
+
+	h.broadcastStatusBroadcast()
+
+	output, _ := broadcast.Output(data.Name)
+	return utils.HttpSuccess(w, output)
+}
+
+func (h *RoomHandler) broadcastOutputRead(w http.ResponseWriter, r *http.Request) error {
+	outputName := chi.URLParam(r, "outputName")
+
+	output, ok := h.capture.Broadcast().Output(outputName)
+	if !ok {
+		return utils.HttpNotFound("output not found")
+	}
+
+	return utils.HttpSuccess(w, output)
+}
+
+func (h *RoomHandler) broadcastOutputDelete(w http.ResponseWriter, r *http.Request) error {
+	outputName := chi.URLParam(r, "outputName")
+
+	err := h.capture.Broadcast().RemoveOutput(outputName)
+	if errors.Is(err, types.ErrBroadcastOutputNotFound) {
+		return utils.HttpNotFound("output not found").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	h.broadcastStatusBroadcast()
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) broadcastOutputStart(w http.ResponseWriter, r *http.Request) error {
+	outputName := chi.URLParam(r, "outputName")
+
+	broadcast := h.capture.Broadcast()
+
+	output, ok := broadcast.Output(outputName)
+	if !ok {
+		return utils.HttpNotFound("output not found")
+	}
+
+	if output.IsActive {
+		return utils.HttpUnprocessableEntity("output is already started")
+	}
+
+	if err := broadcast.StartOutput(outputName); err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
 
+	h.broadcastStatusBroadcast()
 	return utils.HttpSuccess(w)
 }
+
+func (h *RoomHandler) broadcastOutputStop(w http.ResponseWriter, r *http.Request) error {
+	outputName := chi.URLParam(r, "outputName")
+
+	broadcast := h.capture.Broadcast()
+
+	output, ok := broadcast.Output(outputName)
+	if !ok {
+		return utils.HttpNotFound("output not found")
+	}
+
+	if !output.IsActive {
+		return utils.HttpUnprocessableEntity("output is not started")
+	}
+
+	if err := broadcast.StopOutput(outputName); err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	h.broadcastStatusBroadcast()
+	return utils.HttpSuccess(w)
+}
+
+// let admins know, that broadcast outputs changed
+func (h *RoomHandler) broadcastStatusBroadcast() {
+	h.sessions.AdminBroadcast(
+		event.BROADCAST_STATUS,
+		h.capture.Broadcast().Status())
+}
@@ -0,0 +1,165 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/event"
+	"m1k1o/neko/pkg/types/message"
+	"m1k1o/neko/pkg/utils"
+)
+
+func (h *RoomHandler) captureEncoderGet(w http.ResponseWriter, r *http.Request) error {
+	videoId := chi.URLParam(r, "videoId")
+
+	stream, ok := h.capture.Video().GetStream(types.StreamSelector{
+		ID: videoId,
+	})
+	if !ok {
+		return utils.HttpNotFound("video stream not found")
+	}
+
+	return utils.HttpSuccess(w, stream.EncoderParams())
+}
+
+func (h *RoomHandler) captureEncoderSet(w http.ResponseWriter, r *http.Request) error {
+	videoId := chi.URLParam(r, "videoId")
+
+	stream, ok := h.capture.Video().GetStream(types.StreamSelector{
+		ID: videoId,
+	})
+	if !ok {
+		return utils.HttpNotFound("video stream not found")
+	}
+
+	data := &types.EncoderParams{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if err := stream.SetEncoderParams(*data); err != nil {
+		if errors.Is(err, types.ErrCaptureEncoderParamNotSupported) {
+			return utils.HttpUnprocessableEntity("encoder param not supported").WithInternalErr(err)
+		}
+
+		return utils.HttpBadRequest("unable to set encoder params").WithInternalErr(err)
+	}
+
+	params := stream.EncoderParams()
+
+	h.sessions.AdminBroadcast(
+		event.CAPTURE_ENCODER,
+		message.CaptureEncoder{
+			VideoID:       stream.ID(),
+			EncoderParams: params,
+		})
+
+	return utils.HttpSuccess(w, params)
+}
+
+func (h *RoomHandler) captureStatus(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.capture.Status())
+}
+
+func (h *RoomHandler) captureDiagnostics(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.capture.Diagnostics())
+}
+
+type CapturePipelinesPayload struct {
+	IDs       []string                     `json:"ids"`
+	Pipelines map[string]types.VideoConfig `json:"pipelines"`
+}
+
+type CapturePipelinePayload struct {
+	ID string `json:"id"`
+	types.VideoConfig
+}
+
+func (h *RoomHandler) capturePipelinesList(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, CapturePipelinesPayload{
+		IDs:       h.capture.Video().IDs(),
+		Pipelines: h.capture.Video().Pipelines(),
+	})
+}
+
+func (h *RoomHandler) capturePipelineCreate(w http.ResponseWriter, r *http.Request) error {
+	data := &CapturePipelinePayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if data.ID == "" {
+		return utils.HttpBadRequest("pipeline id must be provided")
+	}
+
+	err := h.capture.Video().AddPipeline(data.ID, data.VideoConfig)
+	if errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return utils.HttpUnprocessableEntity("pipeline with this id already exists").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpBadRequest("invalid pipeline").WithInternalErr(err)
+	}
+
+	h.capturePipelinesBroadcast()
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) capturePipelineRead(w http.ResponseWriter, r *http.Request) error {
+	videoId := chi.URLParam(r, "videoId")
+
+	config, ok := h.capture.Video().Pipelines()[videoId]
+	if !ok {
+		return utils.HttpNotFound("pipeline not found")
+	}
+
+	return utils.HttpSuccess(w, CapturePipelinePayload{
+		ID:          videoId,
+		VideoConfig: config,
+	})
+}
+
+func (h *RoomHandler) capturePipelineUpdate(w http.ResponseWriter, r *http.Request) error {
+	videoId := chi.URLParam(r, "videoId")
+
+	data := &types.VideoConfig{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	err := h.capture.Video().UpdatePipeline(videoId, *data)
+	if errors.Is(err, types.ErrCapturePipelineNotFound) {
+		return utils.HttpNotFound("pipeline not found").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpBadRequest("invalid pipeline").WithInternalErr(err)
+	}
+
+	h.capturePipelinesBroadcast()
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) capturePipelineDelete(w http.ResponseWriter, r *http.Request) error {
+	videoId := chi.URLParam(r, "videoId")
+
+	err := h.capture.Video().RemovePipeline(videoId)
+	if errors.Is(err, types.ErrCapturePipelineNotFound) {
+		return utils.HttpNotFound("pipeline not found").WithInternalErr(err)
+	} else if errors.Is(err, types.ErrCapturePipelineLast) {
+		return utils.HttpUnprocessableEntity("last pipeline cannot be removed").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	h.capturePipelinesBroadcast()
+	return utils.HttpSuccess(w)
+}
+
+// let clients know, that available video streams changed
+func (h *RoomHandler) capturePipelinesBroadcast() {
+	h.sessions.Broadcast(
+		event.CAPTURE_PIPELINES,
+		message.CapturePipelines{
+			Videos: h.capture.Video().IDs(),
+		})
+}
@@ -0,0 +1,189 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/rs/zerolog/log"
+
+	"m1k1o/neko/pkg/auth"
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/event"
+	"m1k1o/neko/pkg/types/message"
+	"m1k1o/neko/pkg/utils"
+)
+
+// how long are dragged out files offered for download
+const downloadExpiry = 5 * time.Minute
+
+// resolves dragged out file, it must be a regular file inside of dir
+func downloadPath(dir string, path string) (string, os.FileInfo, error) {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return "", nil, fmt.Errorf("file is outside of %s", dir)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !stat.Mode().IsRegular() {
+		return "", nil, errors.New("not a regular file")
+	}
+
+	return path, stat, nil
+}
+
+// files dragged out of the desktop, offered to a single session
+type downloadCache struct {
+	mu    sync.Mutex
+	files map[string]downloadFile
+}
+
+type downloadFile struct {
+	path      string
+	sessionId string
+	expiresAt time.Time
+}
+
+func (cache *downloadCache) add(session types.Session, path string) (string, time.Time, error) {
+	token, err := utils.NewUID(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.files == nil {
+		cache.files = map[string]downloadFile{}
+	}
+
+	// remove expired offers
+	now := time.Now()
+	for token, file := range cache.files {
+		if now.After(file.expiresAt) {
+			delete(cache.files, token)
+		}
+	}
+
+	expiresAt := now.Add(downloadExpiry)
+	cache.files[token] = downloadFile{
+		path:      path,
+		sessionId: session.ID(),
+		expiresAt: expiresAt,
+	}
+
+	return token, expiresAt, nil
+}
+
+func (cache *downloadCache) get(session types.Session, token string) (downloadFile, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	file, ok := cache.files[token]
+	if !ok || file.sessionId != session.ID() {
+		return downloadFile{}, false
+	}
+
+	if time.Now().After(file.expiresAt) {
+		delete(cache.files, token)
+		return downloadFile{}, false
+	}
+
+	return file, true
+}
+
+// offer dragged out files to the current host
+func (h *RoomHandler) downloadOffer(paths []string) {
+	host, ok := h.sessions.GetHost()
+	if !ok {
+		log.Debug().Strs("files", paths).Msg("no host to offer dragged files to")
+		return
+	}
+
+	offer := message.DownloadOffer{
+		Files: []message.DownloadFile{},
+	}
+
+	dir := h.desktop.DownloadDragDir()
+	for _, dragged := range paths {
+		path, stat, err := downloadPath(dir, dragged)
+		if err != nil {
+			log.Debug().Err(err).Str("file", dragged).Msg("skipping dragged file")
+			continue
+		}
+
+		token, expiresAt, err := h.downloads.add(host, path)
+		if err != nil {
+			log.Err(err).Msg("could not create download token")
+			return
+		}
+
+		offer.Files = append(offer.Files, message.DownloadFile{
+			Name:      filepath.Base(path),
+			Size:      stat.Size(),
+			URL:       "/api/room/download/" + token,
+			ExpiresAt: expiresAt,
+		})
+	}
+
+	if len(offer.Files) == 0 {
+		return
+	}
+
+	host.Send(event.DOWNLOAD_OFFER, offer)
+}
+
+func (h *RoomHandler) downloadGet(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+
+	file, ok := h.downloads.get(session, chi.URLParam(r, "token"))
+	if !ok {
+		return utils.HttpNotFound("download not found or expired")
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		return utils.HttpNotFound("file not found").WithInternalErr(err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return utils.HttpInternalServerError().
+			WithInternalErr(err).
+			Msg("unable to read file")
+	}
+
+	name := filepath.Base(file.path)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, name, stat.ModTime(), f)
+
+	log.Debug().Str("file", file.path).Str("session_id", session.ID()).Msg("served dragged file")
+	return nil
+}
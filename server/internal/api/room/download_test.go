@@ -0,0 +1,107 @@
+package room
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadPath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "Downloads")
+	outside := filepath.Join(root, "secret.txt")
+
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{
+		filepath.Join(dir, "file.txt"),
+		filepath.Join(dir, "nested", "file.txt"),
+		filepath.Join(root, "Downloads..txt"),
+		outside,
+	} {
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "link-out.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "file.txt"), filepath.Join(root, "link-in.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+		ok   bool
+	}{
+		{
+			name: "file in directory",
+			path: filepath.Join(dir, "file.txt"),
+			want: filepath.Join(dir, "file.txt"),
+			ok:   true,
+		},
+		{
+			name: "file in nested directory",
+			path: filepath.Join(dir, "nested", "file.txt"),
+			want: filepath.Join(dir, "nested", "file.txt"),
+			ok:   true,
+		},
+		{
+			name: "symlink from outside to directory",
+			path: filepath.Join(root, "link-in.txt"),
+			want: filepath.Join(dir, "file.txt"),
+			ok:   true,
+		},
+		{
+			name: "file outside of directory",
+			path: outside,
+		},
+		{
+			name: "relative path escaping directory",
+			path: filepath.Join(dir, "..", "secret.txt"),
+		},
+		{
+			name: "sibling with directory prefix",
+			path: filepath.Join(root, "Downloads..txt"),
+		},
+		{
+			name: "symlink from directory to outside",
+			path: filepath.Join(dir, "link-out.txt"),
+		},
+		{
+			name: "directory itself",
+			path: dir,
+		},
+		{
+			name: "nested directory",
+			path: filepath.Join(dir, "nested"),
+		},
+		{
+			name: "missing file",
+			path: filepath.Join(dir, "missing.txt"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _, err := downloadPath(dir, tt.path)
+			if (err == nil) != tt.ok {
+				t.Fatalf("downloadPath() error = %v, want ok %v", err, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+
+			want, err := filepath.EvalSymlinks(tt.want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if path != want {
+				t.Errorf("downloadPath() = %v, want %v", path, want)
+			}
+		})
+	}
+}
@@ -15,19 +15,26 @@ type RoomHandler struct {
 	sessions types.SessionManager
 	desktop  types.DesktopManager
 	capture  types.CaptureManager
+	webrtc   types.WebRTCManager
 
 	privateModeImage []byte
+	screenshots      screenshotCache
+	inputs           inputSequencer
+	downloads        downloadCache
+	hlsTokens        hlsTokenCache
 }
 
 func New(
 	sessions types.SessionManager,
 	desktop types.DesktopManager,
 	capture types.CaptureManager,
+	webrtc types.WebRTCManager,
 ) *RoomHandler {
 	h := &RoomHandler{
 		sessions: sessions,
 		desktop:  desktop,
 		capture:  capture,
+		webrtc:   webrtc,
 	}
 
 	// generate fallback image for private mode when needed
@@ -51,9 +58,35 @@ func New(
 		}
 	})
 
+	// input sequences can run only while their session is host
+	sessions.OnHostChanged(func(session, host types.Session) {
+		h.inputs.hostChanged(host)
+	})
+
+	// whip peers live only as long as their session is allowed to share media
+	sessions.OnDeleted(func(session types.Session) {
+		h.webrtc.DestroyWhipPeers(session)
+	})
+
+	sessions.OnProfileChanged(func(session types.Session, new, old types.MemberProfile) {
+		if !new.CanLogin || !new.CanConnect || !new.CanShareMedia {
+			h.webrtc.DestroyWhipPeers(session)
+		}
+	})
+
+	// files dragged out to the screen edge are offered to the host
+	if desktop.IsDownloadDragEnabled() {
+		desktop.OnFilesDragged(h.downloadOffer)
+	}
+
 	return h
 }
 
+// segments are outside of authenticated area, they are authorized by token issued with the playlist
+func (h *RoomHandler) RouteHlsSegments(r types.Router) {
+	r.Get("/{token}/{segment}", h.hlsSegmentGet)
+}
+
 func (h *RoomHandler) Route(r types.Router) {
 	r.With(auth.AdminsOnly).Route("/settings", func(r types.Router) {
 		r.Post("/", h.settingsSet)
@@ -64,6 +97,25 @@ func (h *RoomHandler) Route(r types.Router) {
 		r.Get("/", h.broadcastStatus)
 		r.Post("/start", h.broadcastStart)
 		r.Post("/stop", h.broadcastStop)
+
+		r.Get("/outputs", h.broadcastOutputsList)
+		r.Post("/outputs", h.broadcastOutputCreate)
+		r.Get("/outputs/{outputName}", h.broadcastOutputRead)
+		r.Delete("/outputs/{outputName}", h.broadcastOutputDelete)
+		r.Post("/outputs/{outputName}/start", h.broadcastOutputStart)
+		r.Post("/outputs/{outputName}/stop", h.broadcastOutputStop)
+	})
+
+	r.With(auth.AdminsOnly).Route("/capture", func(r types.Router) {
+		r.Get("/status", h.captureStatus)
+		r.Get("/diagnostics", h.captureDiagnostics)
+		r.Get("/pipelines", h.capturePipelinesList)
+		r.Post("/pipelines", h.capturePipelineCreate)
+		r.Get("/pipelines/{videoId}", h.capturePipelineRead)
+		r.Post("/pipelines/{videoId}", h.capturePipelineUpdate)
+		r.Delete("/pipelines/{videoId}", h.capturePipelineDelete)
+		r.Get("/pipelines/{videoId}/encoder", h.captureEncoderGet)
+		r.Post("/pipelines/{videoId}/encoder", h.captureEncoderSet)
 	})
 
 	r.With(auth.CanAccessClipboardOnly).With(auth.HostsOnly).Route("/clipboard", func(r types.Router) {
@@ -84,11 +136,40 @@ func (h *RoomHandler) Route(r types.Router) {
 	r.With(auth.CanHostOnly).Route("/keyboard", func(r types.Router) {
 		r.Get("/map", h.keyboardMapGet)
 		r.With(auth.HostsOnly).Post("/map", h.keyboardMapSet)
+		r.Get("/layouts", h.keyboardLayoutsGet)
+		r.With(auth.HostsOnly).Post("/type", h.keyboardType)
 
 		r.Get("/modifiers", h.keyboardModifiersGet)
 		r.With(auth.HostsOnly).Post("/modifiers", h.keyboardModifiersSet)
 	})
 
+	r.With(auth.AdminsOnly).Route("/apps", func(r types.Router) {
+		r.Get("/", h.appsList)
+		r.Get("/{appName}", h.appRead)
+		r.Post("/{appName}/start", h.appStart)
+		r.Post("/{appName}/stop", h.appStop)
+		r.Post("/{appName}/restart", h.appRestart)
+	})
+
+	r.With(auth.CanHostOnly).Route("/windows", func(r types.Router) {
+		r.Get("/", h.windowsList)
+		r.Get("/{windowId}", h.windowRead)
+
+		r.With(auth.HostsOrAdminsOnly).Group(func(r types.Router) {
+			r.Post("/{windowId}/focus", h.windowFocus)
+			r.Post("/{windowId}/geometry", h.windowGeometry)
+			r.Post("/{windowId}/maximize", h.windowMaximize)
+			r.Post("/{windowId}/unmaximize", h.windowUnmaximize)
+			r.Delete("/{windowId}", h.windowClose)
+		})
+	})
+
+	r.With(auth.CanHostOnly).Route("/input", func(r types.Router) {
+		r.Get("/sequence", h.inputSequenceStatus)
+		r.With(auth.HostsOnly).Post("/sequence", h.inputSequenceStart)
+		r.With(auth.HostsOrAdminsOnly).Delete("/sequence", h.inputSequenceCancel)
+	})
+
 	r.With(auth.CanHostOnly).Route("/control", func(r types.Router) {
 		r.Get("/", h.controlStatus)
 		r.Post("/request", h.controlRequest)
@@ -103,9 +184,18 @@ func (h *RoomHandler) Route(r types.Router) {
 		r.Get("/", h.screenConfiguration)
 		r.With(auth.AdminsOnly).Post("/", h.screenConfigurationChange)
 		r.With(auth.AdminsOnly).Get("/configurations", h.screenConfigurationsList)
+		r.Get("/outputs", h.screenOutputsList)
 
 		r.Get("/cast.jpg", h.screenCastGet)
+		r.Get("/cast.mjpeg", h.screenCastMjpegGet)
+		r.Get("/cast.sse", h.screenCastEventsGet)
 		r.With(auth.AdminsOnly).Get("/shot.jpg", h.screenShotGet)
+		r.With(auth.AdminsOnly).Get("/shot", h.screenShotGet)
+		r.Get("/thumbnail", h.screenThumbnailGet)
+	})
+
+	r.With(auth.CanWatchOnly).Route("/hls", func(r types.Router) {
+		r.Get("/"+types.HlsPlaylistName, h.hlsPlaylistGet)
 	})
 
 	r.With(h.uploadMiddleware).Route("/upload", func(r types.Router) {
@@ -114,6 +204,13 @@ func (h *RoomHandler) Route(r types.Router) {
 		r.Delete("/dialog", h.uploadDialogClose)
 	})
 
+	r.With(auth.CanHostOnly).Get("/download/{token}", h.downloadGet)
+
+	r.With(auth.CanShareMediaOnly).Route("/whip", func(r types.Router) {
+		r.Post("/", h.whipCreate)
+		r.Delete("/{resourceId}", h.whipDelete)
+	})
+
 }
 
 func (h *RoomHandler) uploadMiddleware(w http.ResponseWriter, r *http.Request) (context.Context, error) {
@@ -0,0 +1,166 @@
+package room
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"m1k1o/neko/pkg/auth"
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
+)
+
+// segments are requested without session token, so that it does not leak through
+// the playlist, they are authorized by token that expires when playlist is not requested
+const hlsTokenExpiry = time.Minute
+
+// segment tokens issued with the playlist, by their value
+type hlsTokenCache struct {
+	mu     sync.Mutex
+	tokens map[string]hlsToken
+}
+
+type hlsToken struct {
+	sessionId string
+	expiresAt time.Time
+}
+
+// token of the session is reused and its expiration is extended
+func (cache *hlsTokenCache) issue(session types.Session) (string, error) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.tokens == nil {
+		cache.tokens = map[string]hlsToken{}
+	}
+
+	// remove expired tokens
+	now := time.Now()
+	token := ""
+	for value, t := range cache.tokens {
+		if now.After(t.expiresAt) {
+			delete(cache.tokens, value)
+		} else if t.sessionId == session.ID() {
+			token = value
+		}
+	}
+
+	if token == "" {
+		var err error
+		token, err = utils.NewUID(32)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	cache.tokens[token] = hlsToken{
+		sessionId: session.ID(),
+		expiresAt: now.Add(hlsTokenExpiry),
+	}
+
+	return token, nil
+}
+
+func (cache *hlsTokenCache) get(token string) (string, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	t, ok := cache.tokens[token]
+	if !ok {
+		return "", false
+	}
+
+	if time.Now().After(t.expiresAt) {
+		delete(cache.tokens, token)
+		return "", false
+	}
+
+	return t.sessionId, true
+}
+
+func (h *RoomHandler) hlsPlaylistGet(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+	if session.PrivateModeEnabled() {
+		return utils.HttpForbidden("private mode is enabled")
+	}
+
+	hls := h.capture.Hls()
+	if !hls.Enabled() {
+		return utils.HttpBadRequest("hls is not enabled")
+	}
+
+	playlist, err := hls.Playlist(r.Context())
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	token, err := h.hlsTokens.issue(session)
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	// segments are requested relative to the playlist
+	playlist = hlsPrefixSegments(playlist, "segments/"+token+"/")
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+
+	_, err = w.Write(playlist)
+	return err
+}
+
+// segments are routed outside of authenticated area, token is checked here
+func (h *RoomHandler) hlsSegmentGet(w http.ResponseWriter, r *http.Request) error {
+	sessionId, ok := h.hlsTokens.get(chi.URLParam(r, "token"))
+	if !ok {
+		return utils.HttpUnauthorized("invalid or expired segment token")
+	}
+
+	session, ok := h.sessions.Get(sessionId)
+	if !ok || !session.Profile().CanLogin || !session.Profile().CanWatch {
+		return utils.HttpForbidden("session is not allowed to watch")
+	}
+
+	if session.PrivateModeEnabled() {
+		return utils.HttpForbidden("private mode is enabled")
+	}
+
+	hls := h.capture.Hls()
+	if !hls.Enabled() {
+		return utils.HttpBadRequest("hls is not enabled")
+	}
+
+	path, err := hls.Segment(chi.URLParam(r, "segment"))
+	if errors.Is(err, os.ErrNotExist) {
+		return utils.HttpNotFound("segment not found")
+	} else if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	// segments never change, but are removed from the rolling window
+	w.Header().Set("Cache-Control", "private, max-age=60")
+	w.Header().Set("Content-Type", "video/mp2t")
+
+	http.ServeFile(w, r, path)
+	return nil
+}
+
+// hlsPrefixSegments prepends prefix to every URI line of the playlist
+func hlsPrefixSegments(playlist []byte, prefix string) []byte {
+	lines := bytes.Split(playlist, []byte("\n"))
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		lines[i] = append([]byte(prefix), line...)
+	}
+
+	return bytes.Join(lines, []byte("\n"))
+}
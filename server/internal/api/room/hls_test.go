@@ -0,0 +1,88 @@
+package room
+
+import (
+	"testing"
+
+	"m1k1o/neko/internal/config"
+	"m1k1o/neko/internal/session"
+	"m1k1o/neko/pkg/types"
+)
+
+func TestHlsPrefixSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		want     string
+	}{
+		{
+			name:     "segments are prefixed",
+			playlist: "#EXTM3U\n#EXTINF:2.0,\nsegment00001.ts\n#EXTINF:2.0,\nsegment00002.ts\n",
+			want:     "#EXTM3U\n#EXTINF:2.0,\nsegments/t/segment00001.ts\n#EXTINF:2.0,\nsegments/t/segment00002.ts\n",
+		},
+		{
+			name:     "whitespace is trimmed",
+			playlist: "#EXTM3U\n  segment00001.ts \r\n",
+			want:     "#EXTM3U\nsegments/t/segment00001.ts\n",
+		},
+		{
+			name:     "tags only",
+			playlist: "#EXTM3U\n#EXT-X-TARGETDURATION:2\n",
+			want:     "#EXTM3U\n#EXT-X-TARGETDURATION:2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(hlsPrefixSegments([]byte(tt.playlist), "segments/t/")); got != tt.want {
+				t.Errorf("hlsPrefixSegments() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHlsTokenCache(t *testing.T) {
+	sessions := session.New(&config.Session{})
+
+	first, _, err := sessions.Create("hls-first", types.MemberProfile{CanWatch: true})
+	if err != nil {
+		t.Fatalf("could not create session %s", err)
+	}
+
+	second, _, err := sessions.Create("hls-second", types.MemberProfile{CanWatch: true})
+	if err != nil {
+		t.Fatalf("could not create session %s", err)
+	}
+
+	cache := hlsTokenCache{}
+
+	token, err := cache.issue(first)
+	if err != nil {
+		t.Fatalf("issue() error = %v", err)
+	}
+
+	if again, _ := cache.issue(first); again != token {
+		t.Errorf("issue() = %q, want reused token %q", again, token)
+	}
+
+	if other, _ := cache.issue(second); other == token {
+		t.Errorf("issue() returned the same token for another session")
+	}
+
+	if id, ok := cache.get(token); !ok || id != first.ID() {
+		t.Errorf("get() = %q, %v, want %q, true", id, ok, first.ID())
+	}
+
+	if _, ok := cache.get("unknown"); ok {
+		t.Errorf("get() of unknown token succeeded")
+	}
+
+	// expired token is rejected and removed
+	cache.tokens[token] = hlsToken{sessionId: first.ID()}
+	if _, ok := cache.get(token); ok {
+		t.Errorf("get() of expired token succeeded")
+	}
+
+	if _, ok := cache.tokens[token]; ok {
+		t.Errorf("expired token was not removed")
+	}
+}
@@ -0,0 +1,450 @@
+package room
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"m1k1o/neko/pkg/auth"
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/event"
+	"m1k1o/neko/pkg/types/message"
+	"m1k1o/neko/pkg/utils"
+)
+
+const (
+	inputSequenceMaxActions = 1000
+	// longest wait, drag or screen change timeout
+	inputSequenceMaxDuration = 60 * time.Second
+	// screen change timeout when not specified
+	inputScreenDefaultTimeout = 10 * time.Second
+	// how often is screen damage checked while waiting for screen change
+	inputScreenPollInterval = 50 * time.Millisecond
+	// how often is cursor moved while dragging
+	inputDragInterval = 16 * time.Millisecond
+)
+
+var errInputSequenceNotHost = errors.New("session is no longer host")
+
+type InputAction struct {
+	// move, click, drag, keys, type, wait or wait_screen
+	Type string `json:"type"`
+	// position, optional for click
+	X *int `json:"x,omitempty"`
+	Y *int `json:"y,omitempty"`
+	// drag target position
+	ToX int `json:"to_x,omitempty"`
+	ToY int `json:"to_y,omitempty"`
+	// mouse button 1 left, 2 middle or 3 right, left by default
+	Button uint32 `json:"button,omitempty"`
+	// number of clicks up to 3, one by default
+	Count int `json:"count,omitempty"`
+	// keys pressed together
+	Keysyms []uint32 `json:"keysyms,omitempty"`
+	Text    string   `json:"text,omitempty"`
+	// in milliseconds, wait time, drag time or screen change timeout
+	Duration int `json:"duration,omitempty"`
+}
+
+type InputSequencePayload struct {
+	Actions []InputAction `json:"actions"`
+}
+
+func (action InputAction) validate() error {
+	if action.Duration < 0 || time.Duration(action.Duration)*time.Millisecond > inputSequenceMaxDuration {
+		return fmt.Errorf("duration must be between 0 and %d ms", inputSequenceMaxDuration.Milliseconds())
+	}
+
+	if action.Count < 0 || action.Count > 3 {
+		return errors.New("count must be between 1 and 3, or 0 for a single click")
+	}
+
+	if action.Button > 3 {
+		return errors.New("button must be 1 (left), 2 (middle) or 3 (right), or 0 for left")
+	}
+
+	switch action.Type {
+	case "move", "drag":
+		if action.X == nil || action.Y == nil {
+			return errors.New("x and y must be set")
+		}
+	case "click":
+		if (action.X == nil) != (action.Y == nil) {
+			return errors.New("x and y must be set together")
+		}
+	case "keys":
+		if len(action.Keysyms) == 0 {
+			return errors.New("keysyms must not be empty")
+		}
+	case "type":
+		if action.Text == "" {
+			return errors.New("text must not be empty")
+		}
+	case "wait", "wait_screen":
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+
+	return nil
+}
+
+type inputSequence struct {
+	session types.Session
+	actions []InputAction
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu     sync.Mutex
+	status message.InputSequence
+}
+
+func (seq *inputSequence) Status() message.InputSequence {
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+
+	return seq.status
+}
+
+func (seq *inputSequence) running() bool {
+	select {
+	case <-seq.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (seq *inputSequence) progress(done int) {
+	seq.mu.Lock()
+	seq.status.Done = done
+	status := seq.status
+	seq.mu.Unlock()
+
+	seq.session.Send(event.INPUT_SEQUENCE_PROGRESS, status)
+}
+
+func (seq *inputSequence) finish(err error) {
+	seq.mu.Lock()
+	switch {
+	case err == nil:
+		seq.status.State = "finished"
+	case errors.Is(err, context.Canceled):
+		seq.status.State = "cancelled"
+	default:
+		seq.status.State = "failed"
+		seq.status.Error = err.Error()
+	}
+	status := seq.status
+	seq.mu.Unlock()
+
+	seq.session.Send(event.INPUT_SEQUENCE_PROGRESS, status)
+}
+
+// only one sequence can run at a time, the last one is kept for its status
+type inputSequencer struct {
+	mu      sync.Mutex
+	current *inputSequence
+}
+
+func (s *inputSequencer) get() (*inputSequence, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.current, s.current != nil
+}
+
+// sequence is bound to its host, it is cancelled when control is lost
+func (s *inputSequencer) hostChanged(host types.Session) {
+	seq, ok := s.get()
+	if !ok || !seq.running() {
+		return
+	}
+
+	if host == nil || host.ID() != seq.session.ID() {
+		seq.cancel()
+	}
+}
+
+// keys and buttons pressed by a sequence, only these are released when it ends
+type inputPressed struct {
+	desktop types.DesktopManager
+	keys    map[uint32]struct{}
+	buttons map[uint32]struct{}
+}
+
+func newInputPressed(desktop types.DesktopManager) *inputPressed {
+	return &inputPressed{
+		desktop: desktop,
+		keys:    map[uint32]struct{}{},
+		buttons: map[uint32]struct{}{},
+	}
+}
+
+func (p *inputPressed) keyDown(code uint32) error {
+	if err := p.desktop.KeyDown(code); err != nil {
+		return err
+	}
+
+	p.keys[code] = struct{}{}
+	return nil
+}
+
+func (p *inputPressed) keyUp(code uint32) error {
+	delete(p.keys, code)
+	return p.desktop.KeyUp(code)
+}
+
+func (p *inputPressed) buttonDown(code uint32) error {
+	if err := p.desktop.ButtonDown(code); err != nil {
+		return err
+	}
+
+	p.buttons[code] = struct{}{}
+	return nil
+}
+
+func (p *inputPressed) buttonUp(code uint32) error {
+	delete(p.buttons, code)
+	return p.desktop.ButtonUp(code)
+}
+
+func (p *inputPressed) release() {
+	for code := range p.buttons {
+		_ = p.buttonUp(code)
+	}
+	for code := range p.keys {
+		_ = p.keyUp(code)
+	}
+}
+
+func inputSleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (h *RoomHandler) inputSequenceRun(ctx context.Context, seq *inputSequence) {
+	defer close(seq.done)
+	// input of sessions is accepted again only after sequence ends
+	defer h.desktop.UnlockInput()
+
+	// no key or button pressed by sequence can stay pressed after it ends
+	pressed := newInputPressed(h.desktop)
+	defer pressed.release()
+
+	// screen change is awaited since the last input action
+	damage := h.desktop.GetScreenDamage()
+
+	for i, action := range seq.actions {
+		if !seq.session.IsHost() {
+			seq.finish(errInputSequenceNotHost)
+			return
+		}
+
+		if action.Type != "wait" && action.Type != "wait_screen" {
+			damage = h.desktop.GetScreenDamage()
+		}
+
+		if err := h.inputAction(ctx, pressed, action, damage); err != nil {
+			seq.finish(fmt.Errorf("action %d (%s): %w", i, action.Type, err))
+			return
+		}
+
+		seq.progress(i + 1)
+	}
+
+	seq.finish(nil)
+}
+
+func (h *RoomHandler) inputAction(ctx context.Context, pressed *inputPressed, action InputAction, damage uint64) error {
+	duration := time.Duration(action.Duration) * time.Millisecond
+
+	button := action.Button
+	if button == 0 {
+		button = 1
+	}
+
+	switch action.Type {
+	case "move":
+		h.desktop.Move(*action.X, *action.Y)
+	case "click":
+		if action.X != nil {
+			h.desktop.Move(*action.X, *action.Y)
+		}
+
+		count := max(action.Count, 1)
+		for i := 0; i < count; i++ {
+			if err := pressed.buttonDown(button); err != nil {
+				return err
+			}
+			if err := pressed.buttonUp(button); err != nil {
+				return err
+			}
+		}
+	case "drag":
+		h.desktop.Move(*action.X, *action.Y)
+		if err := pressed.buttonDown(button); err != nil {
+			return err
+		}
+
+		steps := max(int(duration/inputDragInterval), 1)
+		for i := 1; i <= steps; i++ {
+			if err := inputSleep(ctx, duration/time.Duration(steps)); err != nil {
+				return err
+			}
+
+			x := *action.X + (action.ToX-*action.X)*i/steps
+			y := *action.Y + (action.ToY-*action.Y)*i/steps
+			h.desktop.Move(x, y)
+		}
+
+		return pressed.buttonUp(button)
+	case "keys":
+		for _, keysym := range action.Keysyms {
+			if err := pressed.keyDown(keysym); err != nil {
+				return err
+			}
+		}
+
+		if len(action.Keysyms) > 1 {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// keys are released in reverse order, modifiers last
+		for i := len(action.Keysyms) - 1; i >= 0; i-- {
+			if err := pressed.keyUp(action.Keysyms[i]); err != nil {
+				return err
+			}
+		}
+	case "type":
+		return h.desktop.TypeText(action.Text)
+	case "wait":
+		return inputSleep(ctx, duration)
+	case "wait_screen":
+		if damage == 0 {
+			return errors.New("screen change tracking is not available")
+		}
+
+		if duration == 0 {
+			duration = inputScreenDefaultTimeout
+		}
+
+		ticker := time.NewTicker(inputScreenPollInterval)
+		defer ticker.Stop()
+
+		timeout := time.NewTimer(duration)
+		defer timeout.Stop()
+
+		for h.desktop.GetScreenDamage() == damage {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timeout.C:
+				return fmt.Errorf("screen did not change within %v", duration)
+			case <-ticker.C:
+			}
+		}
+	}
+
+	return nil
+}
+
+func (h *RoomHandler) inputSequenceStart(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+
+	data := &InputSequencePayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if len(data.Actions) == 0 || len(data.Actions) > inputSequenceMaxActions {
+		return utils.HttpBadRequest(fmt.Sprintf("sequence must have between 1 and %d actions", inputSequenceMaxActions))
+	}
+
+	for i, action := range data.Actions {
+		if err := action.validate(); err != nil {
+			return utils.HttpBadRequest(fmt.Sprintf("action %d: %s", i, err))
+		}
+	}
+
+	id, err := utils.NewUID(16)
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	seq := &inputSequence{
+		session: session,
+		actions: data.Actions,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		status: message.InputSequence{
+			ID:    id,
+			State: "running",
+			Total: len(data.Actions),
+		},
+	}
+
+	h.inputs.mu.Lock()
+	if h.inputs.current != nil && h.inputs.current.running() {
+		h.inputs.mu.Unlock()
+		cancel()
+		return utils.HttpError(http.StatusConflict, "another input sequence is running")
+	}
+
+	// other input is ignored for the whole sequence, so that it can not interleave
+	if !h.desktop.LockInput() {
+		h.inputs.mu.Unlock()
+		cancel()
+		return utils.HttpError(http.StatusConflict, "input is locked")
+	}
+	h.inputs.current = seq
+	h.inputs.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		h.inputSequenceRun(ctx, seq)
+	}()
+
+	// optionally wait for the sequence to end, closing request cancels it
+	if r.URL.Query().Get("wait") == "true" {
+		select {
+		case <-seq.done:
+		case <-r.Context().Done():
+			seq.cancel()
+			<-seq.done
+		}
+	}
+
+	return utils.HttpSuccess(w, seq.Status())
+}
+
+func (h *RoomHandler) inputSequenceStatus(w http.ResponseWriter, r *http.Request) error {
+	seq, ok := h.inputs.get()
+	if !ok {
+		return utils.HttpNotFound("no input sequence was started")
+	}
+
+	return utils.HttpSuccess(w, seq.Status())
+}
+
+func (h *RoomHandler) inputSequenceCancel(w http.ResponseWriter, r *http.Request) error {
+	seq, ok := h.inputs.get()
+	if !ok || !seq.running() {
+		return utils.HttpNotFound("no input sequence is running")
+	}
+
+	seq.cancel()
+	<-seq.done
+
+	return utils.HttpSuccess(w, seq.Status())
+}
@@ -0,0 +1,151 @@
+package room
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"m1k1o/neko/pkg/types"
+)
+
+// desktop with pressed keys, only key methods are implemented
+type testKeysDesktop struct {
+	types.DesktopManager
+	keys map[uint32]bool
+}
+
+func (d *testKeysDesktop) KeyDown(code uint32) error {
+	if d.keys[code] {
+		return errors.New("already pressed")
+	}
+	d.keys[code] = true
+	return nil
+}
+
+func (d *testKeysDesktop) KeyUp(code uint32) error {
+	if !d.keys[code] {
+		return errors.New("not pressed")
+	}
+	delete(d.keys, code)
+	return nil
+}
+
+func TestInputPressedRelease(t *testing.T) {
+	tests := []struct {
+		name string
+		// keys pressed before the sequence
+		held []uint32
+		down []uint32
+		up   []uint32
+		want []uint32
+	}{
+		{
+			name: "released keys",
+			down: []uint32{1, 2},
+			up:   []uint32{2, 1},
+			want: []uint32{},
+		},
+		{
+			name: "pressed keys are released",
+			down: []uint32{1, 2},
+			up:   []uint32{2},
+			want: []uint32{},
+		},
+		{
+			name: "keys held by others are kept",
+			held: []uint32{1},
+			down: []uint32{1, 2},
+			want: []uint32{1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desktop := &testKeysDesktop{keys: map[uint32]bool{}}
+			for _, code := range tt.held {
+				desktop.keys[code] = true
+			}
+
+			pressed := newInputPressed(desktop)
+			for _, code := range tt.down {
+				_ = pressed.keyDown(code)
+			}
+			for _, code := range tt.up {
+				_ = pressed.keyUp(code)
+			}
+			pressed.release()
+
+			got := []uint32{}
+			for code := range desktop.keys {
+				got = append(got, code)
+			}
+			sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pressed keys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInputActionValidate(t *testing.T) {
+	x, y := 10, 20
+
+	tests := []struct {
+		name    string
+		action  InputAction
+		wantErr bool
+	}{
+		{
+			name:   "click with defaults",
+			action: InputAction{Type: "click"},
+		},
+		{
+			name:   "triple right click",
+			action: InputAction{Type: "click", X: &x, Y: &y, Button: 3, Count: 3},
+		},
+		{
+			name:    "negative count",
+			action:  InputAction{Type: "click", Count: -1},
+			wantErr: true,
+		},
+		{
+			name:    "too many clicks",
+			action:  InputAction{Type: "click", Count: 4},
+			wantErr: true,
+		},
+		{
+			name:    "scroll button",
+			action:  InputAction{Type: "click", Button: 4},
+			wantErr: true,
+		},
+		{
+			name:    "arbitrary button",
+			action:  InputAction{Type: "drag", X: &x, Y: &y, Button: 1 << 31},
+			wantErr: true,
+		},
+		{
+			name:   "middle button drag",
+			action: InputAction{Type: "drag", X: &x, Y: &y, Button: 2},
+		},
+		{
+			name:    "click with only x",
+			action:  InputAction{Type: "click", X: &x},
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			action:  InputAction{Type: "scroll"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.action.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
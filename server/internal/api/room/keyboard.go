@@ -2,6 +2,7 @@ package room
 
 import (
 	"net/http"
+	"unicode/utf8"
 
 	"m1k1o/neko/pkg/types"
 	"m1k1o/neko/pkg/utils"
@@ -30,6 +31,41 @@ func (h *RoomHandler) keyboardMapGet(w http.ResponseWriter, r *http.Request) err
 	return utils.HttpSuccess(w, keyboardMap)
 }
 
+func (h *RoomHandler) keyboardLayoutsGet(w http.ResponseWriter, r *http.Request) error {
+	layouts, err := h.desktop.KeyboardLayouts()
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w, layouts)
+}
+
+type KeyboardTypePayload struct {
+	Text string `json:"text"`
+}
+
+func (h *RoomHandler) keyboardType(w http.ResponseWriter, r *http.Request) error {
+	data := &KeyboardTypePayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	if !utf8.ValidString(data.Text) {
+		return utils.HttpBadRequest("text is not valid utf-8")
+	}
+
+	if h.desktop.InputLocked() {
+		return utils.HttpError(http.StatusConflict, "input is locked by input sequence")
+	}
+
+	err := h.desktop.TypeText(data.Text)
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
 func (h *RoomHandler) keyboardModifiersSet(w http.ResponseWriter, r *http.Request) error {
 	keyboardModifiers := types.KeyboardModifiers{}
 	if err := utils.HttpJsonRequest(w, r, &keyboardModifiers); err != nil {
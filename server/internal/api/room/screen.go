@@ -1,8 +1,16 @@
 package room
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/jpeg"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/rs/zerolog/log"
 
 	"m1k1o/neko/pkg/auth"
 	"m1k1o/neko/pkg/types"
@@ -31,6 +39,10 @@ func (h *RoomHandler) screenConfigurationChange(w http.ResponseWriter, r *http.R
 		Rate:   data.Rate,
 	})
 
+	if errors.Is(err, types.ErrScreenSizeOutOfBounds) {
+		return utils.HttpUnprocessableEntity(err.Error())
+	}
+
 	if err != nil {
 		return utils.HttpUnprocessableEntity("cannot set screen size").WithInternalErr(err)
 	}
@@ -50,23 +62,10 @@ func (h *RoomHandler) screenConfigurationsList(w http.ResponseWriter, r *http.Re
 	return utils.HttpSuccess(w, configurations)
 }
 
-func (h *RoomHandler) screenShotGet(w http.ResponseWriter, r *http.Request) error {
-	quality, err := strconv.Atoi(r.URL.Query().Get("quality"))
-	if err != nil {
-		quality = 90
-	}
-
-	img := h.desktop.GetScreenshotImage()
-	bytes, err := utils.CreateJPGImage(img, quality)
-	if err != nil {
-		return utils.HttpInternalServerError().WithInternalErr(err)
-	}
-
-	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Header().Set("Content-Type", "image/jpeg")
+func (h *RoomHandler) screenOutputsList(w http.ResponseWriter, r *http.Request) error {
+	outputs := h.desktop.ScreenOutputs()
 
-	_, err = w.Write(bytes)
-	return err
+	return utils.HttpSuccess(w, outputs)
 }
 
 func (h *RoomHandler) screenCastGet(w http.ResponseWriter, r *http.Request) error {
@@ -99,3 +98,178 @@ func (h *RoomHandler) screenCastGet(w http.ResponseWriter, r *http.Request) erro
 	_, err = w.Write(bytes)
 	return err
 }
+
+const screenCastBoundary = "nekoframe"
+
+type screenCastOptions struct {
+	// minimal time between two frames
+	interval time.Duration
+	// zero values keep frames as they are encoded by the pipeline
+	quality int
+	width   int
+	height  int
+}
+
+func screenCastOptionsFromQuery(r *http.Request) (*screenCastOptions, error) {
+	query := r.URL.Query()
+	opts := &screenCastOptions{}
+
+	if str := query.Get("rate"); str != "" {
+		rate, err := strconv.ParseFloat(str, 64)
+		if err != nil || rate <= 0 {
+			return nil, utils.HttpBadRequest("rate must be a positive number")
+		}
+		opts.interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	if str := query.Get("quality"); str != "" {
+		quality, err := strconv.Atoi(str)
+		if err != nil || quality < 1 || quality > 100 {
+			return nil, utils.HttpBadRequest("quality must be between 1 and 100")
+		}
+		opts.quality = quality
+	}
+
+	if str := query.Get("width"); str != "" {
+		width, err := strconv.Atoi(str)
+		if err != nil || width <= 0 {
+			return nil, utils.HttpBadRequest("width must be a positive integer")
+		}
+		opts.width = width
+	}
+
+	if str := query.Get("height"); str != "" {
+		height, err := strconv.Atoi(str)
+		if err != nil || height <= 0 {
+			return nil, utils.HttpBadRequest("height must be a positive integer")
+		}
+		opts.height = height
+	}
+
+	return opts, nil
+}
+
+// re-encode frame when different quality or size was requested
+func (opts *screenCastOptions) apply(frame []byte) ([]byte, error) {
+	if opts.quality == 0 && opts.width == 0 && opts.height == 0 {
+		return frame, nil
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(frame))
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := utils.ImageToRGBA(img)
+	bounds := rgba.Bounds()
+
+	// missing dimension keeps aspect ratio, upscaling is not supported
+	width, height := opts.width, opts.height
+	if width == 0 && height == 0 {
+		width, height = bounds.Dx(), bounds.Dy()
+	} else if width == 0 {
+		width = max(bounds.Dx()*height/bounds.Dy(), 1)
+	} else if height == 0 {
+		height = max(bounds.Dy()*width/bounds.Dx(), 1)
+	}
+
+	rgba = utils.ScaleImage(rgba, min(width, bounds.Dx()), min(height, bounds.Dy()))
+
+	quality := opts.quality
+	if quality == 0 {
+		quality = 90
+	}
+
+	return utils.CreateJPGImage(rgba, quality)
+}
+
+// sends screencast frames until client disconnects
+func (h *RoomHandler) screenCastStream(w http.ResponseWriter, r *http.Request, contentType string, writeFrame func(frame []byte) error) error {
+	session, _ := auth.GetSession(r)
+
+	screencast := h.capture.Screencast()
+	if !screencast.Enabled() {
+		return utils.HttpBadRequest("screencast pipeline is not enabled")
+	}
+
+	opts, err := screenCastOptionsFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return utils.HttpInternalServerError().WithInternalMsg("streaming is not supported")
+	}
+
+	frames, unsubscribe, err := screencast.Subscribe()
+	if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+	defer unsubscribe()
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var last time.Time
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case frame, ok := <-frames:
+			if !ok {
+				return nil
+			}
+
+			if opts.interval > 0 && time.Since(last) < opts.interval {
+				continue
+			}
+
+			// display fallback image when private mode is enabled
+			if session.PrivateModeEnabled() {
+				if h.privateModeImage == nil {
+					continue
+				}
+				frame = h.privateModeImage
+			} else if frame, err = opts.apply(frame); err != nil {
+				log.Warn().Err(err).Msg("failed to transform screencast frame")
+				continue
+			}
+
+			// client disconnected
+			if err := writeFrame(frame); err != nil {
+				return nil
+			}
+
+			flusher.Flush()
+			last = time.Now()
+		}
+	}
+}
+
+func (h *RoomHandler) screenCastMjpegGet(w http.ResponseWriter, r *http.Request) error {
+	contentType := "multipart/x-mixed-replace; boundary=" + screenCastBoundary
+
+	return h.screenCastStream(w, r, contentType, func(frame []byte) error {
+		_, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", screenCastBoundary, len(frame))
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+
+		_, err = w.Write([]byte("\r\n"))
+		return err
+	})
+}
+
+func (h *RoomHandler) screenCastEventsGet(w http.ResponseWriter, r *http.Request) error {
+	return h.screenCastStream(w, r, "text/event-stream", func(frame []byte) error {
+		_, err := fmt.Fprintf(w, "event: frame\ndata: data:image/jpeg;base64,%s\n\n", base64.StdEncoding.EncodeToString(frame))
+		return err
+	})
+}
@@ -0,0 +1,281 @@
+package room
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"m1k1o/neko/pkg/auth"
+	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
+)
+
+// thumbnails can not be larger than this in any dimension
+const thumbnailMaxSize = 640
+
+// encoded screenshots kept for the same screen content
+const screenshotCacheSize = 16
+
+var errScreenshotCropOutside = errors.New("crop rectangle is outside of the screen")
+
+var screenshotMimeTypes = map[string]string{
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"webp": "image/webp",
+}
+
+// screenshots are reused until the screen is damaged
+type screenshotCache struct {
+	mu      sync.Mutex
+	damage  uint64
+	image   *image.RGBA
+	encoded map[screenshotOptions][]byte
+}
+
+type screenshotOptions struct {
+	format  string
+	quality int
+	// fit into this box while keeping aspect ratio, zero means unbounded
+	width  int
+	height int
+	// empty rectangle means whole screen
+	crop image.Rectangle
+}
+
+func screenshotOptionsFromQuery(r *http.Request) (screenshotOptions, error) {
+	query := r.URL.Query()
+	opts := screenshotOptions{
+		format:  "jpeg",
+		quality: 90,
+	}
+
+	if str := query.Get("format"); str != "" {
+		opts.format = strings.ToLower(str)
+		if opts.format == "jpg" {
+			opts.format = "jpeg"
+		}
+
+		if _, ok := screenshotMimeTypes[opts.format]; !ok {
+			return opts, utils.HttpBadRequest("format must be one of jpeg, png or webp")
+		}
+	}
+
+	if str := query.Get("quality"); str != "" {
+		quality, err := strconv.Atoi(str)
+		if err != nil || quality < 1 || quality > 100 {
+			return opts, utils.HttpBadRequest("quality must be between 1 and 100")
+		}
+		opts.quality = quality
+	}
+
+	if str := query.Get("width"); str != "" {
+		width, err := strconv.Atoi(str)
+		if err != nil || width <= 0 {
+			return opts, utils.HttpBadRequest("width must be a positive integer")
+		}
+		opts.width = width
+	}
+
+	if str := query.Get("height"); str != "" {
+		height, err := strconv.Atoi(str)
+		if err != nil || height <= 0 {
+			return opts, utils.HttpBadRequest("height must be a positive integer")
+		}
+		opts.height = height
+	}
+
+	// crop=x,y,width,height
+	if str := query.Get("crop"); str != "" {
+		parts := strings.Split(str, ",")
+		if len(parts) != 4 {
+			return opts, utils.HttpBadRequest("crop must be in format x,y,width,height")
+		}
+
+		values := make([]int, 4)
+		for i, part := range parts {
+			value, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || value < 0 {
+				return opts, utils.HttpBadRequest("crop must be in format x,y,width,height")
+			}
+			values[i] = value
+		}
+
+		opts.crop = image.Rect(values[0], values[1], values[0]+values[2], values[1]+values[3])
+		if opts.crop.Empty() {
+			return opts, utils.HttpBadRequest("crop rectangle must not be empty")
+		}
+	}
+
+	return opts, nil
+}
+
+func (opts screenshotOptions) encode(img *image.RGBA) ([]byte, error) {
+	if !opts.crop.Empty() {
+		crop := opts.crop.Add(img.Bounds().Min).Intersect(img.Bounds())
+		if crop.Empty() {
+			return nil, errScreenshotCropOutside
+		}
+		img = img.SubImage(crop).(*image.RGBA)
+	}
+
+	// fit into requested box, upscaling is not supported
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if opts.width > 0 && opts.width < width {
+		height = max(height*opts.width/width, 1)
+		width = opts.width
+	}
+	if opts.height > 0 && opts.height < height {
+		width = max(width*opts.height/height, 1)
+		height = opts.height
+	}
+
+	img = utils.ScaleImage(img, width, height)
+
+	switch opts.format {
+	case "png":
+		return utils.CreatePNGImage(img)
+	case "webp":
+		return createWEBPImage(img, opts.quality)
+	default:
+		return utils.CreateJPGImage(img, opts.quality)
+	}
+}
+
+// image/webp has no encoder, so it is encoded using gstreamer
+func createWEBPImage(img *image.RGBA, quality int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// appsrc expects tightly packed rows
+	pixels := make([]byte, 0, width*height*4)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		i := img.PixOffset(bounds.Min.X, y)
+		pixels = append(pixels, img.Pix[i:i+width*4]...)
+	}
+
+	pipeline, err := gst.CreatePipeline(fmt.Sprintf(
+		"appsrc name=appsrc format=time do-timestamp=true caps=video/x-raw,format=RGBA,width=%d,height=%d,framerate=0/1 "+
+			"! videoconvert ! webpenc quality=%d ! appsink name=appsink",
+		width, height, quality,
+	))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		// late samples must not block destroying the pipeline
+		go func(samples chan types.Sample) {
+			for range samples {
+			}
+		}(pipeline.Sample())
+
+		pipeline.Destroy()
+	}()
+
+	pipeline.AttachAppsrc("appsrc")
+	pipeline.AttachAppsink("appsink")
+	pipeline.Play()
+	pipeline.Push(pixels)
+
+	select {
+	case sample, ok := <-pipeline.Sample():
+		if !ok {
+			return nil, errors.New("unable to encode image")
+		}
+		return sample.Data, nil
+	case <-time.After(5 * time.Second):
+		return nil, errors.New("timeouted while encoding image")
+	}
+}
+
+func (h *RoomHandler) screenshot(opts screenshotOptions) ([]byte, error) {
+	cache := &h.screenshots
+	damage := h.desktop.GetScreenDamage()
+
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// without damage tracking every request takes new screenshot
+	if damage == 0 || damage != cache.damage || cache.image == nil {
+		cache.image = h.desktop.GetScreenshotImage()
+		cache.damage = damage
+		cache.encoded = map[screenshotOptions][]byte{}
+	} else if data, ok := cache.encoded[opts]; ok {
+		return data, nil
+	}
+
+	data, err := opts.encode(cache.image)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cache.encoded) >= screenshotCacheSize {
+		cache.encoded = map[screenshotOptions][]byte{}
+	}
+
+	cache.encoded[opts] = data
+	return data, nil
+}
+
+func (h *RoomHandler) screenshotWrite(w http.ResponseWriter, opts screenshotOptions) error {
+	bytes, err := h.screenshot(opts)
+	if errors.Is(err, errScreenshotCropOutside) {
+		return utils.HttpBadRequest(err.Error())
+	} else if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", screenshotMimeTypes[opts.format])
+
+	_, err = w.Write(bytes)
+	return err
+}
+
+func (h *RoomHandler) screenShotGet(w http.ResponseWriter, r *http.Request) error {
+	opts, err := screenshotOptionsFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	return h.screenshotWrite(w, opts)
+}
+
+func (h *RoomHandler) screenThumbnailGet(w http.ResponseWriter, r *http.Request) error {
+	// display fallback image when private mode is enabled
+	if session, ok := auth.GetSession(r); ok && session.PrivateModeEnabled() {
+		if h.privateModeImage != nil {
+			w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+			w.Header().Set("Content-Type", "image/jpeg")
+
+			_, err := w.Write(h.privateModeImage)
+			return err
+		}
+
+		return utils.HttpBadRequest("private mode is enabled but no fallback image available")
+	}
+
+	opts, err := screenshotOptionsFromQuery(r)
+	if err != nil {
+		return err
+	}
+
+	// thumbnails are always bounded
+	if opts.width == 0 && opts.height == 0 {
+		opts.width = thumbnailMaxSize / 2
+	}
+	if opts.width == 0 || opts.width > thumbnailMaxSize {
+		opts.width = thumbnailMaxSize
+	}
+	if opts.height == 0 || opts.height > thumbnailMaxSize {
+		opts.height = thumbnailMaxSize
+	}
+
+	return h.screenshotWrite(w, opts)
+}
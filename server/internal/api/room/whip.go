@@ -0,0 +1,67 @@
+package room
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"m1k1o/neko/pkg/auth"
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
+)
+
+func (h *RoomHandler) whipCreate(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+
+	contentType := r.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/sdp") {
+		return utils.HttpError(http.StatusUnsupportedMediaType, "expected application/sdp content type")
+	}
+
+	offer, err := io.ReadAll(r.Body)
+	if err != nil {
+		return utils.HttpBadRequest("unable to read request body").WithInternalErr(err)
+	}
+
+	if len(offer) == 0 {
+		return utils.HttpBadRequest("no sdp offer provided")
+	}
+
+	answer, id, err := h.webrtc.CreateWhipPeer(session, string(offer))
+	if errors.Is(err, types.ErrWebRTCWhipNotEnabled) {
+		return utils.HttpUnprocessableEntity("whip ingest is not enabled").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpBadRequest("unable to negotiate whip session").WithInternalErr(err)
+	}
+
+	// use original request uri, because path prefix might be stripped
+	location := r.URL.Path
+	if uri, err := url.Parse(r.RequestURI); err == nil {
+		location = uri.Path
+	}
+
+	w.Header().Set("Content-Type", "application/sdp")
+	w.Header().Set("Location", path.Join(location, id))
+	w.WriteHeader(http.StatusCreated)
+	_, err = w.Write([]byte(answer.SDP))
+	return err
+}
+
+func (h *RoomHandler) whipDelete(w http.ResponseWriter, r *http.Request) error {
+	session, _ := auth.GetSession(r)
+	resourceId := chi.URLParam(r, "resourceId")
+
+	err := h.webrtc.DestroyWhipPeer(session, resourceId)
+	if errors.Is(err, types.ErrWebRTCConnectionNotFound) {
+		return utils.HttpNotFound("whip resource not found").WithInternalErr(err)
+	} else if err != nil {
+		return utils.HttpInternalServerError().WithInternalErr(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
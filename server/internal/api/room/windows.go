@@ -0,0 +1,148 @@
+package room
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
+)
+
+type WindowGeometryPayload struct {
+	X      *int `json:"x,omitempty"`
+	Y      *int `json:"y,omitempty"`
+	Width  *int `json:"width,omitempty"`
+	Height *int `json:"height,omitempty"`
+}
+
+// window id can be decimal or hexadecimal with 0x prefix
+func windowIdParam(r *http.Request) (uint32, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "windowId"), 0, 32)
+	if err != nil {
+		return 0, utils.HttpBadRequest("invalid window id")
+	}
+
+	return uint32(id), nil
+}
+
+func windowError(err error) error {
+	if errors.Is(err, types.ErrWindowNotFound) {
+		return utils.HttpNotFound(err.Error())
+	}
+
+	return utils.HttpInternalServerError().WithInternalErr(err)
+}
+
+func (h *RoomHandler) windowsList(w http.ResponseWriter, r *http.Request) error {
+	return utils.HttpSuccess(w, h.desktop.Windows())
+}
+
+func (h *RoomHandler) windowRead(w http.ResponseWriter, r *http.Request) error {
+	id, err := windowIdParam(r)
+	if err != nil {
+		return err
+	}
+
+	window, err := h.desktop.Window(id)
+	if err != nil {
+		return windowError(err)
+	}
+
+	return utils.HttpSuccess(w, window)
+}
+
+func (h *RoomHandler) windowFocus(w http.ResponseWriter, r *http.Request) error {
+	id, err := windowIdParam(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.desktop.FocusWindow(id); err != nil {
+		return windowError(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) windowGeometry(w http.ResponseWriter, r *http.Request) error {
+	id, err := windowIdParam(r)
+	if err != nil {
+		return err
+	}
+
+	data := &WindowGeometryPayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	window, err := h.desktop.Window(id)
+	if err != nil {
+		return windowError(err)
+	}
+
+	// values not set are kept
+	if data.X != nil {
+		window.X = *data.X
+	}
+	if data.Y != nil {
+		window.Y = *data.Y
+	}
+	if data.Width != nil {
+		window.Width = *data.Width
+	}
+	if data.Height != nil {
+		window.Height = *data.Height
+	}
+
+	if window.Width <= 0 || window.Height <= 0 {
+		return utils.HttpBadRequest("width and height must be positive")
+	}
+
+	if err := h.desktop.MoveResizeWindow(id, window.X, window.Y, window.Width, window.Height); err != nil {
+		return windowError(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) windowMaximize(w http.ResponseWriter, r *http.Request) error {
+	id, err := windowIdParam(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.desktop.MaximizeWindow(id, true); err != nil {
+		return windowError(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) windowUnmaximize(w http.ResponseWriter, r *http.Request) error {
+	id, err := windowIdParam(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.desktop.MaximizeWindow(id, false); err != nil {
+		return windowError(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
+
+func (h *RoomHandler) windowClose(w http.ResponseWriter, r *http.Request) error {
+	id, err := windowIdParam(r)
+	if err != nil {
+		return err
+	}
+
+	if err := h.desktop.CloseWindow(id); err != nil {
+		return windowError(err)
+	}
+
+	return utils.HttpSuccess(w)
+}
@@ -18,6 +18,7 @@ type ApiManagerCtx struct {
 	members  types.MemberManager
 	desktop  types.DesktopManager
 	capture  types.CaptureManager
+	webrtc   types.WebRTCManager
 	routers  map[string]func(types.Router)
 }
 
@@ -26,6 +27,7 @@ func New(
 	members types.MemberManager,
 	desktop types.DesktopManager,
 	capture types.CaptureManager,
+	webrtc types.WebRTCManager,
 ) *ApiManagerCtx {
 
 	return &ApiManagerCtx{
@@ -33,6 +35,7 @@ func New(
 		members:  members,
 		desktop:  desktop,
 		capture:  capture,
+		webrtc:   webrtc,
 		routers:  make(map[string]func(types.Router)),
 	}
 }
@@ -40,6 +43,9 @@ func New(
 func (api *ApiManagerCtx) Route(r types.Router) {
 	r.Post("/login", api.Login)
 
+	roomHandler := room.New(api.sessions, api.desktop, api.capture, api.webrtc)
+	r.Route("/room/hls/segments", roomHandler.RouteHlsSegments)
+
 	// Authenticated area
 	r.Group(func(r types.Router) {
 		r.Use(api.Authenticate)
@@ -56,7 +62,6 @@ func (api *ApiManagerCtx) Route(r types.Router) {
 		r.Route("/members", membersHandler.Route)
 		r.Route("/members_bulk", membersHandler.RouteBulk)
 
-		roomHandler := room.New(api.sessions, api.desktop, api.capture)
 		r.Route("/room", roomHandler.Route)
 
 		for path, router := range api.routers {
@@ -11,6 +11,10 @@ import (
 	"github.com/go-chi/chi"
 )
 
+type SessionAudioPayload struct {
+	Muted bool `json:"muted"`
+}
+
 type SessionDataPayload struct {
 	ID      string              `json:"id"`
 	Profile types.MemberProfile `json:"profile"`
@@ -79,3 +83,36 @@ func (h *SessionsHandler) sessionsDisconnect(w http.ResponseWriter, r *http.Requ
 
 	return utils.HttpSuccess(w)
 }
+
+func (h *SessionsHandler) sessionsLatency(w http.ResponseWriter, r *http.Request) error {
+	sessionId := chi.URLParam(r, "sessionId")
+
+	session, ok := h.sessions.Get(sessionId)
+	if !ok {
+		return utils.HttpNotFound("session not found")
+	}
+
+	peer := session.GetWebRTCPeer()
+	if peer == nil {
+		return utils.HttpUnprocessableEntity("session is not connected to webrtc")
+	}
+
+	return utils.HttpSuccess(w, peer.Latency())
+}
+
+func (h *SessionsHandler) sessionsAudio(w http.ResponseWriter, r *http.Request) error {
+	sessionId := chi.URLParam(r, "sessionId")
+
+	session, ok := h.sessions.Get(sessionId)
+	if !ok {
+		return utils.HttpNotFound("session not found")
+	}
+
+	data := &SessionAudioPayload{}
+	if err := utils.HttpJsonRequest(w, r, data); err != nil {
+		return err
+	}
+
+	session.SetAudioMuted(data.Muted)
+	return utils.HttpSuccess(w)
+}
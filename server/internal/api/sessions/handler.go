@@ -26,5 +26,7 @@ func (h *SessionsHandler) Route(r types.Router) {
 		r.Get("/", h.sessionsRead)
 		r.Delete("/", h.sessionsDelete)
 		r.Post("/disconnect", h.sessionsDisconnect)
+		r.Get("/latency", h.sessionsLatency)
+		r.Post("/audio", h.sessionsAudio)
 	})
 }
@@ -0,0 +1,310 @@
+package capture
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/codec"
+)
+
+// audio stream selected by default, captured from the main audio device
+const audioDefaultID = "main"
+
+// volume in percent is rounded to these steps, so that streams can be shared
+const audioVolumeStep = 10
+
+// streams created on demand are removed, when they were not used for this long
+const audioLevelTimeout = 30 * time.Second
+
+// channels captured by audio pipelines, unless custom pipeline is used
+const audioChannels = 2
+
+// separates ids of audio streams that are mixed together, e.g. main+microphone
+const audioMixSeparator = "+"
+
+type AudioSelectorManagerCtx struct {
+	logger     zerolog.Logger
+	codec      codec.RTPCodec
+	pipelineFn func(configs []types.AudioConfig, volume int) func() (string, error)
+	statusFn   func(id string, status types.CapturePipelineStatus)
+
+	configs map[string]types.AudioConfig
+	streams map[string]*StreamSinkManagerCtx
+	ids     []string
+
+	// mixes and streams with applied volume, created on demand
+	levels     map[string]*StreamSinkManagerCtx
+	levelsUsed map[string]time.Time
+	levelsMu   sync.Mutex
+}
+
+func audioSelectorNew(codec codec.RTPCodec, pipelineFn func(configs []types.AudioConfig, volume int) func() (string, error), configs map[string]types.AudioConfig, statusFn func(id string, status types.CapturePipelineStatus)) *AudioSelectorManagerCtx {
+	logger := log.With().
+		Str("module", "capture").
+		Str("submodule", "audio-selector").
+		Logger()
+
+	manager := &AudioSelectorManagerCtx{
+		logger:     logger,
+		codec:      codec,
+		pipelineFn: pipelineFn,
+		statusFn:   statusFn,
+
+		configs: configs,
+		streams: map[string]*StreamSinkManagerCtx{},
+		ids:     []string{},
+		levels:  map[string]*StreamSinkManagerCtx{},
+
+		levelsUsed: map[string]time.Time{},
+	}
+
+	for id, config := range configs {
+		manager.streams[id] = streamSinkNew(codec, pipelineFn([]types.AudioConfig{config}, 100), "", audioStreamID(id), statusFn)
+		manager.ids = append(manager.ids, id)
+	}
+
+	sortAudioIDs(manager.ids)
+	return manager
+}
+
+// default stream is always first
+func sortAudioIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i] == audioDefaultID || ids[j] == audioDefaultID {
+			return ids[i] == audioDefaultID
+		}
+		return ids[i] < ids[j]
+	})
+}
+
+// audio pipeline capturing from devices of all configs, multiple devices are mixed together
+func audioPipeline(configs []types.AudioConfig, volume int, codecPipeline string) string {
+	gain := ""
+	if volume != 100 {
+		gain = fmt.Sprintf("! volume volume=%.2f ", float64(volume)/100)
+	}
+
+	if len(configs) == 1 {
+		return fmt.Sprintf(
+			"pulsesrc device=%s "+
+				"! audio/x-raw,channels=%d "+
+				"! audioconvert "+
+				"%s"+
+				"! queue "+
+				"! %s "+
+				"! appsink name=appsink", configs[0].Device, audioChannels, gain, codecPipeline,
+		)
+	}
+
+	pipeline := fmt.Sprintf(
+		"audiomixer name=mix "+
+			"! audioconvert "+
+			"%s"+
+			"! queue "+
+			"! %s "+
+			"! appsink name=appsink", gain, codecPipeline,
+	)
+
+	for _, config := range configs {
+		pipeline += fmt.Sprintf(
+			" pulsesrc device=%s "+
+				"! audio/x-raw,channels=%d "+
+				"! audioconvert "+
+				"! audioresample "+
+				"! queue "+
+				"! mix.", config.Device, audioChannels,
+		)
+	}
+
+	return pipeline
+}
+
+// default stream keeps its original id
+func audioStreamID(id string) string {
+	if id == audioDefaultID {
+		return "audio"
+	}
+	return "audio/" + id
+}
+
+func (manager *AudioSelectorManagerCtx) shutdown() {
+	manager.logger.Info().Msgf("shutdown")
+
+	for _, stream := range manager.allStreams() {
+		stream.shutdown()
+	}
+}
+
+func (manager *AudioSelectorManagerCtx) allStreams() []*StreamSinkManagerCtx {
+	manager.levelsMu.Lock()
+	defer manager.levelsMu.Unlock()
+
+	streams := make([]*StreamSinkManagerCtx, 0, len(manager.streams)+len(manager.levels))
+	for _, stream := range manager.streams {
+		streams = append(streams, stream)
+	}
+	for _, stream := range manager.levels {
+		streams = append(streams, stream)
+	}
+	return streams
+}
+
+func (manager *AudioSelectorManagerCtx) defaultStream() *StreamSinkManagerCtx {
+	return manager.streams[audioDefaultID]
+}
+
+// channels of the default stream, 0 if custom pipeline is used
+func (manager *AudioSelectorManagerCtx) defaultChannels() int {
+	if manager.configs[audioDefaultID].GstPipeline != "" {
+		return 0
+	}
+	return audioChannels
+}
+
+// status of all streams, by their stream id
+func (manager *AudioSelectorManagerCtx) status() map[string]types.CapturePipelineStatus {
+	status := map[string]types.CapturePipelineStatus{}
+	for _, stream := range manager.allStreams() {
+		status[stream.ID()] = stream.supervisor.Status()
+	}
+	return status
+}
+
+func (manager *AudioSelectorManagerCtx) diagnostics() map[string]types.CapturePipelineDiagnostics {
+	diagnostics := map[string]types.CapturePipelineDiagnostics{}
+	for _, stream := range manager.allStreams() {
+		diagnostics[stream.ID()] = stream.Diagnostics()
+	}
+	return diagnostics
+}
+
+func (manager *AudioSelectorManagerCtx) IDs() []string {
+	return manager.ids
+}
+
+func (manager *AudioSelectorManagerCtx) Codec() codec.RTPCodec {
+	return manager.codec
+}
+
+// ids of selected streams in the same order as IDs(), without duplicates
+func (manager *AudioSelectorManagerCtx) selectIDs(selector types.StreamSelector) ([]string, bool) {
+	ids := []string{}
+	for _, id := range strings.Split(selector.ID, audioMixSeparator) {
+		if _, ok := manager.configs[id]; !ok {
+			// audio streams have no order, nearest one is the default
+			if selector.Type != types.StreamSelectorTypeNearest {
+				return nil, false
+			}
+			return []string{audioDefaultID}, true
+		}
+
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	sortAudioIDs(ids)
+	return ids, true
+}
+
+// volume rounded to steps, 0 means silent
+func audioVolume(volume int) int {
+	volume = (volume + audioVolumeStep/2) / audioVolumeStep * audioVolumeStep
+	return max(volume, 0)
+}
+
+func (manager *AudioSelectorManagerCtx) GetStream(selector types.StreamSelector, volume int) (types.StreamSinkManager, string, int, bool) {
+	ids, ok := manager.selectIDs(selector)
+	if !ok {
+		return nil, "", 0, false
+	}
+
+	id := strings.Join(ids, audioMixSeparator)
+
+	configs := make([]types.AudioConfig, len(ids))
+	for i, id := range ids {
+		configs[i] = manager.configs[id]
+	}
+
+	// silent stream is not needed, track is paused instead
+	volume = audioVolume(volume)
+	gain := volume
+	if gain == 0 {
+		gain = 100
+	}
+
+	if len(ids) == 1 {
+		stream := manager.streams[id]
+		if gain == 100 {
+			return stream, id, volume, true
+		}
+
+		// gain can not be applied to custom pipelines, stream is sent at its real volume
+		if configs[0].GstPipeline != "" {
+			manager.logger.Warn().Str("audio_id", id).Msg("volume is not supported for custom audio pipeline")
+			return stream, id, 100, true
+		}
+	}
+
+	// custom pipelines can not be mixed
+	if len(configs) > 1 {
+		for i, config := range configs {
+			if config.GstPipeline != "" {
+				manager.logger.Warn().Str("audio_id", ids[i]).Msg("mix is not supported for custom audio pipeline")
+				return nil, "", 0, false
+			}
+		}
+	}
+
+	manager.levelsMu.Lock()
+	defer manager.levelsMu.Unlock()
+
+	streamID := audioStreamID(id)
+	if gain != 100 {
+		streamID = fmt.Sprintf("%s@%d", streamID, gain)
+	}
+
+	level, ok := manager.levels[streamID]
+	if !ok {
+		level = streamSinkNew(manager.codec, manager.pipelineFn(configs, gain), "", streamID, manager.statusFn)
+		level.stoppedFn = func() {
+			time.AfterFunc(audioLevelTimeout, func() {
+				manager.removeLevel(streamID)
+			})
+		}
+		manager.levels[streamID] = level
+
+		// stream might never get a listener
+		level.stoppedFn()
+	}
+
+	// stream is kept for a while, so that its listener can be added
+	manager.levelsUsed[streamID] = time.Now()
+	return level, id, volume, true
+}
+
+// remove stream created on demand, if it has no listeners and was not used recently
+func (manager *AudioSelectorManagerCtx) removeLevel(streamID string) {
+	manager.levelsMu.Lock()
+	level, ok := manager.levels[streamID]
+	if !ok || level.ListenersCount() > 0 || time.Since(manager.levelsUsed[streamID]) < audioLevelTimeout {
+		manager.levelsMu.Unlock()
+		return
+	}
+
+	delete(manager.levels, streamID)
+	delete(manager.levelsUsed, streamID)
+	manager.levelsMu.Unlock()
+
+	level.shutdown()
+	// metrics are unregistered, so that stream can be created again
+	level.unregisterMetrics()
+}
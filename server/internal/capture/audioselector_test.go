@@ -0,0 +1,154 @@
+package capture
+
+import (
+	"reflect"
+	"testing"
+
+	"m1k1o/neko/pkg/types"
+)
+
+func TestAudioSelectorSelectIDs(t *testing.T) {
+	manager := &AudioSelectorManagerCtx{
+		configs: map[string]types.AudioConfig{
+			audioDefaultID: {Device: "audio_output.monitor"},
+			"microphone":   {Device: "audio_input.monitor"},
+			"music":        {Device: "music.monitor"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		selector types.StreamSelector
+		want     []string
+		wantOk   bool
+	}{
+		{
+			name:     "single stream",
+			selector: types.StreamSelector{ID: "microphone"},
+			want:     []string{"microphone"},
+			wantOk:   true,
+		},
+		{
+			name:     "mix is ordered with default first",
+			selector: types.StreamSelector{ID: "music+microphone+main"},
+			want:     []string{"main", "microphone", "music"},
+			wantOk:   true,
+		},
+		{
+			name:     "duplicates are removed",
+			selector: types.StreamSelector{ID: "music+music"},
+			want:     []string{"music"},
+			wantOk:   true,
+		},
+		{
+			name:     "unknown stream",
+			selector: types.StreamSelector{ID: "main+unknown"},
+			wantOk:   false,
+		},
+		{
+			name:     "unknown nearest stream falls back to default",
+			selector: types.StreamSelector{ID: "main+unknown", Type: types.StreamSelectorTypeNearest},
+			want:     []string{"main"},
+			wantOk:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := manager.selectIDs(tt.selector)
+			if ok != tt.wantOk {
+				t.Errorf("selectIDs() ok = %v, want %v", ok, tt.wantOk)
+				return
+			}
+
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectIDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioPipeline(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []types.AudioConfig
+		volume  int
+		want    string
+	}{
+		{
+			name:    "single device",
+			configs: []types.AudioConfig{{Device: "a"}},
+			volume:  100,
+			want:    "pulsesrc device=a ! audio/x-raw,channels=2 ! audioconvert ! queue ! opusenc ! appsink name=appsink",
+		},
+		{
+			name:    "single device with gain",
+			configs: []types.AudioConfig{{Device: "a"}},
+			volume:  50,
+			want:    "pulsesrc device=a ! audio/x-raw,channels=2 ! audioconvert ! volume volume=0.50 ! queue ! opusenc ! appsink name=appsink",
+		},
+		{
+			name:    "mixed devices with gain",
+			configs: []types.AudioConfig{{Device: "a"}, {Device: "b"}},
+			volume:  150,
+			want: "audiomixer name=mix ! audioconvert ! volume volume=1.50 ! queue ! opusenc ! appsink name=appsink" +
+				" pulsesrc device=a ! audio/x-raw,channels=2 ! audioconvert ! audioresample ! queue ! mix." +
+				" pulsesrc device=b ! audio/x-raw,channels=2 ! audioconvert ! audioresample ! queue ! mix.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audioPipeline(tt.configs, tt.volume, "opusenc"); got != tt.want {
+				t.Errorf("audioPipeline() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAudioVolume(t *testing.T) {
+	tests := []struct {
+		name   string
+		volume int
+		want   int
+	}{
+		{
+			name:   "silent",
+			volume: 0,
+			want:   0,
+		},
+		{
+			name:   "rounded down to silent",
+			volume: 4,
+			want:   0,
+		},
+		{
+			name:   "rounded up from silent",
+			volume: 5,
+			want:   10,
+		},
+		{
+			name:   "full volume",
+			volume: 104,
+			want:   100,
+		},
+		{
+			name:   "amplified",
+			volume: 200,
+			want:   200,
+		},
+		{
+			name:   "negative",
+			volume: -20,
+			want:   0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := audioVolume(tt.volume); got != tt.want {
+				t.Errorf("audioVolume() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
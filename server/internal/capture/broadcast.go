@@ -1,7 +1,10 @@
 package capture
 
 import (
+	"errors"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -9,36 +12,62 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/gst/rtsp"
 	"m1k1o/neko/pkg/types"
 )
 
+// output controlled by legacy Start and Stop
+const broadcastDefaultOutput = "main"
+
+// built-in RTSP server payloads encoder output, that is pushed to its appsrc
+const broadcastRtspLaunch = "( " + broadcastOutputSrc + "! tsdemux name=demux " +
+	"demux. ! video/x-h264 ! queue ! h264parse ! rtph264pay name=pay0 pt=96 config-interval=-1 " +
+	"demux. ! audio/mpeg ! queue ! aacparse ! rtpmp4gpay name=pay1 pt=97 )"
+
 type BroacastManagerCtx struct {
 	logger zerolog.Logger
 	mu     sync.Mutex
 
-	pipeline   gst.Pipeline
-	pipelineMu sync.Mutex
-	pipelineFn func(url string) (string, error)
+	// shared encoder, its samples are pushed to all outputs
+	encoder   gst.Pipeline
+	encoderMu sync.Mutex
+	encoderFn func() (string, error)
+
+	outputs   map[string]*broadcastOutput
+	outputsMu sync.RWMutex
 
-	url     string
-	started bool
+	// built-in rtsp server is fed by the encoder, while it has some clients
+	rtsp        *rtsp.Server
+	rtspClients bool
+	outputFn    func(url string) (string, error)
+	statusFn    func(id string, status types.CapturePipelineStatus)
+
+	// outputs not sending any data for this long are restarted
+	stallTimeout time.Duration
+
+	supervisor  *pipelineSupervisor
+	diagnostics *pipelineDiagnostics
 
 	// metrics
 	pipelinesCounter prometheus.Counter
 	pipelinesActive  prometheus.Gauge
 }
 
-func broadcastNew(pipelineFn func(url string) (string, error), defaultUrl string, autostart bool) *BroacastManagerCtx {
+// when encoderFn is nil, every output runs its own standalone pipeline
+func broadcastNew(encoderFn func() (string, error), outputFn func(url string) (string, error), defaultUrl string, autostart bool, stallTimeout time.Duration, statusFn func(id string, status types.CapturePipelineStatus)) *BroacastManagerCtx {
 	logger := log.With().
 		Str("module", "capture").
 		Str("submodule", "broadcast").
 		Logger()
 
-	return &BroacastManagerCtx{
-		logger:     logger,
-		pipelineFn: pipelineFn,
-		url:        defaultUrl,
-		started:    defaultUrl != "" && autostart,
+	manager := &BroacastManagerCtx{
+		logger:    logger,
+		encoderFn: encoderFn,
+		outputs:   map[string]*broadcastOutput{},
+		outputFn:  outputFn,
+		statusFn:  statusFn,
+
+		stallTimeout: stallTimeout,
 
 		// metrics
 		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
@@ -66,92 +95,469 @@ func broadcastNew(pipelineFn func(url string) (string, error), defaultUrl string
 			},
 		}),
 	}
+
+	manager.supervisor = newPipelineSupervisor(logger, "broadcast", manager.restartEncoder, statusFn)
+	manager.diagnostics = newPipelineDiagnostics(logger, manager.supervisor, map[string]string{
+		"submodule":  "broadcast",
+		"video_id":   "main",
+		"codec_name": "-",
+		"codec_type": "-",
+	})
+
+	// pipelines of the default output are created when capture starts
+	if defaultUrl != "" {
+		output := manager.newOutput(broadcastDefaultOutput, defaultUrl)
+		output.started = autostart
+		manager.outputs[broadcastDefaultOutput] = output
+	}
+
+	return manager
 }
 
 func (manager *BroacastManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
-	manager.destroyPipeline()
+	manager.outputsMu.Lock()
+	server := manager.rtsp
+	manager.rtsp = nil
+	manager.outputsMu.Unlock()
+
+	if server != nil {
+		server.Close()
+	}
+
+	manager.mu.Lock()
+	manager.rtspClients = false
+	manager.mu.Unlock()
+
+	manager.destroyPipelines()
+}
+
+func (manager *BroacastManagerCtx) newOutput(name string, url string) *broadcastOutput {
+	return broadcastOutputNew(manager.logger, name, url, manager.outputFn, manager.encoderFn != nil, manager.stallTimeout, manager.statusFn)
+}
+
+func (manager *BroacastManagerCtx) getOutput(name string) (*broadcastOutput, bool) {
+	manager.outputsMu.RLock()
+	defer manager.outputsMu.RUnlock()
+
+	output, ok := manager.outputs[name]
+	return output, ok
+}
+
+// outputs sorted by name
+func (manager *BroacastManagerCtx) outputsList() []*broadcastOutput {
+	manager.outputsMu.RLock()
+	defer manager.outputsMu.RUnlock()
+
+	outputs := make([]*broadcastOutput, 0, len(manager.outputs))
+	for _, output := range manager.outputs {
+		outputs = append(outputs, output)
+	}
+
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].name < outputs[j].name
+	})
+
+	return outputs
 }
 
+func (manager *BroacastManagerCtx) anyStarted() bool {
+	if manager.rtspClients {
+		return true
+	}
+
+	for _, output := range manager.outputsList() {
+		if output.Started() {
+			return true
+		}
+	}
+
+	return false
+}
+
+//
+// default output
+//
+
 func (manager *BroacastManagerCtx) Start(url string) error {
+	if output, ok := manager.getOutput(broadcastDefaultOutput); ok {
+		// clients may send start again, it only changes the url of started output
+		if output.Started() {
+			if output.Url() == url {
+				return nil
+			}
+
+			output.stop()
+		}
+
+		if err := output.setUrl(url); err != nil {
+			return err
+		}
+	} else if err := manager.AddOutput(broadcastDefaultOutput, url); err != nil {
+		return err
+	}
+
+	return manager.StartOutput(broadcastDefaultOutput)
+}
+
+func (manager *BroacastManagerCtx) Stop() {
+	err := manager.StopOutput(broadcastDefaultOutput)
+	if err != nil && !errors.Is(err, types.ErrBroadcastOutputNotFound) {
+		manager.logger.Err(err).Msg("failed to stop default output")
+	}
+}
+
+func (manager *BroacastManagerCtx) Started() bool {
+	output, ok := manager.getOutput(broadcastDefaultOutput)
+	return ok && output.Started()
+}
+
+func (manager *BroacastManagerCtx) Url() string {
+	output, ok := manager.getOutput(broadcastDefaultOutput)
+	if !ok {
+		return ""
+	}
+
+	return output.Url()
+}
+
+func (manager *BroacastManagerCtx) Status() types.BroadcastStatus {
+	status := types.BroadcastStatus{
+		Outputs: manager.Outputs(),
+	}
+
+	if output, ok := manager.getOutput(broadcastDefaultOutput); ok {
+		main := output.Status()
+		status.IsActive = main.IsActive
+		status.URL = main.URL
+		status.Error = main.Error
+		status.Uptime = main.Uptime
+		status.Bitrate = main.Bitrate
+	}
+
+	return status
+}
+
+//
+// named outputs
+//
+
+func (manager *BroacastManagerCtx) Outputs() []types.BroadcastOutput {
+	outputs := []types.BroadcastOutput{}
+	for _, output := range manager.outputsList() {
+		outputs = append(outputs, output.Status())
+	}
+
+	return outputs
+}
+
+func (manager *BroacastManagerCtx) Output(name string) (types.BroadcastOutput, bool) {
+	output, ok := manager.getOutput(name)
+	if !ok {
+		return types.BroadcastOutput{}, false
+	}
+
+	return output.Status(), true
+}
+
+func (manager *BroacastManagerCtx) AddOutput(name string, url string) error {
+	if name == "" {
+		return errors.New("output name must not be empty")
+	}
+
+	// fail early on unsupported destination
+	if _, err := manager.outputFn(url); err != nil {
+		return err
+	}
+
+	manager.outputsMu.Lock()
+	defer manager.outputsMu.Unlock()
+
+	if _, ok := manager.outputs[name]; ok {
+		return types.ErrBroadcastOutputAlreadyExists
+	}
+
+	manager.outputs[name] = manager.newOutput(name, url)
+	return nil
+}
+
+func (manager *BroacastManagerCtx) RemoveOutput(name string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	output, ok := manager.getOutput(name)
+	if !ok {
+		return types.ErrBroadcastOutputNotFound
+	}
+
+	output.stop()
+
+	manager.outputsMu.Lock()
+	delete(manager.outputs, name)
+	manager.outputsMu.Unlock()
+
+	output.unregisterMetrics()
+
+	manager.stopEncoderIfUnused()
+	return nil
+}
+
+func (manager *BroacastManagerCtx) StartOutput(name string) error {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	manager.url = url
+	output, ok := manager.getOutput(name)
+	if !ok {
+		return types.ErrBroadcastOutputNotFound
+	}
+
+	if err := manager.createEncoder(); err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		manager.stopEncoderIfUnused()
+		return err
+	}
+
+	if err := output.start(); err != nil {
+		manager.stopEncoderIfUnused()
+		return err
+	}
+
+	return nil
+}
+
+func (manager *BroacastManagerCtx) StopOutput(name string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	output, ok := manager.getOutput(name)
+	if !ok {
+		return types.ErrBroadcastOutputNotFound
+	}
+
+	output.stop()
+
+	manager.stopEncoderIfUnused()
+	return nil
+}
+
+//
+// rtsp server
+//
+
+func (manager *BroacastManagerCtx) startRtspServer(port int, path string, username string, password string) error {
+	if manager.encoderFn == nil {
+		return errors.New("rtsp server requires built-in broadcast encoder")
+	}
 
-	err := manager.createPipeline()
+	server, err := rtsp.NewServer(port, path, broadcastRtspLaunch, username, password, manager.rtspClientsChanged)
 	if err != nil {
 		return err
 	}
 
-	manager.started = true
+	manager.outputsMu.Lock()
+	manager.rtsp = server
+	manager.outputsMu.Unlock()
+
 	return nil
 }
 
-func (manager *BroacastManagerCtx) Stop() {
+// clients need to reconnect, when encoder output changes
+func (manager *BroacastManagerCtx) disconnectRtspClients() {
+	manager.outputsMu.RLock()
+	server := manager.rtsp
+	manager.outputsMu.RUnlock()
+
+	if server != nil {
+		server.DisconnectClients()
+	}
+}
+
+// encoder runs while rtsp server has some clients
+func (manager *BroacastManagerCtx) rtspClientsChanged(connected bool) {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	manager.started = false
-	manager.destroyPipeline()
+	manager.rtspClients = connected
+
+	if !connected {
+		manager.stopEncoderIfUnused()
+		return
+	}
+
+	err := manager.createEncoder()
+	if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		manager.supervisor.createFailed(err)
+	}
 }
 
-func (manager *BroacastManagerCtx) Started() bool {
+//
+// pipelines
+//
+
+// restart encoder after failure, outputs are restarted as well
+func (manager *BroacastManagerCtx) restartEncoder(_ bool) error {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	return manager.started
+	if !manager.anyStarted() {
+		manager.supervisor.stopped()
+		return nil
+	}
+
+	outputs := manager.outputsList()
+	for _, output := range outputs {
+		output.destroyPipeline()
+	}
+
+	manager.destroyEncoder()
+	if err := manager.createEncoder(); err != nil {
+		return err
+	}
+
+	manager.createOutputs(outputs)
+	return nil
 }
 
-func (manager *BroacastManagerCtx) Url() string {
+// pipelines that fail to create are restarted by their supervisors
+func (manager *BroacastManagerCtx) recreatePipelines() {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
 
-	return manager.url
+	if !manager.anyStarted() {
+		return
+	}
+
+	err := manager.createEncoder()
+	if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		manager.supervisor.createFailed(err)
+	}
+
+	manager.createOutputs(manager.outputsList())
 }
 
-func (manager *BroacastManagerCtx) createPipeline() error {
-	manager.pipelineMu.Lock()
-	defer manager.pipelineMu.Unlock()
+func (manager *BroacastManagerCtx) createOutputs(outputs []*broadcastOutput) {
+	for _, output := range outputs {
+		if !output.Started() {
+			continue
+		}
 
-	if manager.pipeline != nil {
+		err := output.createPipeline()
+		if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+			output.supervisor.createFailed(err)
+		}
+	}
+}
+
+func (manager *BroacastManagerCtx) destroyPipelines() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, output := range manager.outputsList() {
+		output.destroyPipeline()
+	}
+
+	manager.destroyEncoder()
+}
+
+func (manager *BroacastManagerCtx) stopEncoderIfUnused() {
+	if manager.anyStarted() {
+		return
+	}
+
+	manager.destroyEncoder()
+	manager.supervisor.stopped()
+}
+
+func (manager *BroacastManagerCtx) createEncoder() error {
+	if manager.encoderFn == nil {
+		return nil
+	}
+
+	manager.encoderMu.Lock()
+	defer manager.encoderMu.Unlock()
+
+	if manager.encoder != nil {
 		return types.ErrCapturePipelineAlreadyExists
 	}
 
-	pipelineStr, err := manager.pipelineFn(manager.url)
+	pipelineStr, err := manager.encoderFn()
 	if err != nil {
 		return err
 	}
 
 	manager.logger.Info().
-		Str("url", manager.url).
 		Str("src", pipelineStr).
-		Msgf("starting pipeline")
+		Msgf("starting encoder pipeline")
 
-	manager.pipeline, err = gst.CreatePipeline(pipelineStr)
+	manager.encoder, err = gst.CreatePipeline(pipelineStr)
 	if err != nil {
 		return err
 	}
 
-	manager.pipeline.Play()
+	manager.encoder.AttachAppsink("appsink")
+	manager.encoder.Play()
+
+	// channel is closed when pipeline is destroyed
+	go func(samples chan types.Sample) {
+		for sample := range samples {
+			manager.outputsMu.RLock()
+			for _, output := range manager.outputs {
+				output.push(sample)
+			}
+			if manager.rtsp != nil {
+				manager.rtsp.Push(sample.Data)
+			}
+			manager.outputsMu.RUnlock()
+		}
+	}(manager.encoder.Sample())
+
+	manager.diagnostics.created(manager.encoder)
+	manager.supervisor.running(false)
+
 	manager.pipelinesCounter.Inc()
 	manager.pipelinesActive.Set(1)
 
 	return nil
 }
 
-func (manager *BroacastManagerCtx) destroyPipeline() {
-	manager.pipelineMu.Lock()
-	defer manager.pipelineMu.Unlock()
+func (manager *BroacastManagerCtx) destroyEncoder() {
+	manager.encoderMu.Lock()
+	defer manager.encoderMu.Unlock()
 
-	if manager.pipeline == nil {
+	if manager.encoder == nil {
 		return
 	}
 
-	manager.pipeline.Destroy()
-	manager.logger.Info().Msgf("destroying pipeline")
-	manager.pipeline = nil
+	manager.encoder.Destroy()
+	manager.logger.Info().Msgf("destroying encoder pipeline")
+	manager.encoder = nil
+	manager.diagnostics.destroyed()
 
 	manager.pipelinesActive.Set(0)
 }
+
+func (manager *BroacastManagerCtx) status() map[string]types.CapturePipelineStatus {
+	status := map[string]types.CapturePipelineStatus{
+		"broadcast": manager.supervisor.Status(),
+	}
+
+	for _, output := range manager.outputsList() {
+		status["broadcast/"+output.name] = output.supervisor.Status()
+	}
+
+	return status
+}
+
+func (manager *BroacastManagerCtx) Diagnostics() map[string]types.CapturePipelineDiagnostics {
+	manager.encoderMu.Lock()
+	diagnostics := map[string]types.CapturePipelineDiagnostics{
+		"broadcast": manager.diagnostics.Diagnostics(manager.encoder),
+	}
+	manager.encoderMu.Unlock()
+
+	for _, output := range manager.outputsList() {
+		diagnostics["broadcast/"+output.name] = output.Diagnostics()
+	}
+
+	return diagnostics
+}
@@ -0,0 +1,518 @@
+package capture
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/types"
+)
+
+// output reads MPEG-TS produced by the shared encoder
+const broadcastOutputSrc = "appsrc name=appsrc format=time is-live=true do-timestamp=true "
+
+// demuxed elementary streams are linked to the muxer named mux
+const broadcastOutputDemux = "! tsdemux name=demux " +
+	"demux. ! video/x-h264 ! queue ! h264parse ! mux. " +
+	"demux. ! audio/mpeg ! queue ! aacparse ! mux. "
+
+// bytes received by element with this name are considered sent,
+// when they stop flowing while encoder pushes data, output is stalled
+const broadcastOutputMeter = "meter"
+
+// broadcastProtocols create pipeline remuxing shared encoder output for the destination
+var broadcastProtocols = map[string]func(u *url.URL) (string, error){
+	"rtmp":  broadcastRtmpPipeline,
+	"rtmps": broadcastRtmpPipeline,
+	"srt":   broadcastSrtPipeline,
+	"rtsp":  broadcastRtspPipeline,
+	"rtsps": broadcastRtspPipeline,
+}
+
+// broadcastOutputPipeline chooses muxer and sink by the URL scheme,
+// files can be written only to the recordings directory.
+func broadcastOutputPipeline(rawUrl string, recordings string) (string, error) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", err
+	}
+
+	if u.Scheme == "file" {
+		return broadcastFilePipeline(u, recordings)
+	}
+
+	pipelineFn, ok := broadcastProtocols[u.Scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported broadcast url scheme '%s'", u.Scheme)
+	}
+
+	return pipelineFn(u)
+}
+
+// url is quoted in the pipeline, query is encoded again so that it is escaped
+// and characters that could leave the quotes or add elements are rejected
+func broadcastUrl(u *url.URL) (string, error) {
+	if u.RawQuery != "" {
+		u.RawQuery = u.Query().Encode()
+	}
+
+	location := u.String()
+	if i := strings.IndexFunc(location, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("'\"\\!", r)
+	}); i >= 0 {
+		return "", fmt.Errorf("broadcast url contains forbidden character %q", location[i])
+	}
+
+	return location, nil
+}
+
+func broadcastRtmpPipeline(u *url.URL) (string, error) {
+	location, err := broadcastUrl(u)
+	if err != nil {
+		return "", err
+	}
+
+	return broadcastOutputSrc + broadcastOutputDemux +
+		fmt.Sprintf("flvmux name=mux streamable=true ! rtmpsink name=meter location='%s live=1'", location), nil
+}
+
+// MPEG-TS is sent as it is, options such as mode, latency,
+// passphrase or streamid are passed in the URL query
+func broadcastSrtPipeline(u *url.URL) (string, error) {
+	if u.Port() == "" {
+		return "", fmt.Errorf("srt output requires port")
+	}
+
+	location, err := broadcastUrl(u)
+	if err != nil {
+		return "", err
+	}
+
+	return broadcastOutputSrc +
+		fmt.Sprintf("! srtsink name=meter uri=\"%s\" wait-for-connection=false", location), nil
+}
+
+// stream is announced to RTSP server, transport can be set by the
+// transport query param to tcp (default), udp or udp-mcast
+func broadcastRtspPipeline(u *url.URL) (string, error) {
+	query := u.Query()
+
+	transport := query.Get("transport")
+	switch transport {
+	case "":
+		transport = "tcp"
+	case "tcp", "udp", "udp-mcast":
+	default:
+		return "", fmt.Errorf("unsupported rtsp transport '%s'", transport)
+	}
+
+	query.Del("transport")
+	u.RawQuery = query.Encode()
+
+	location, err := broadcastUrl(u)
+	if err != nil {
+		return "", err
+	}
+
+	// sink muxes on its own, all of its request pads are counted by the meter
+	return broadcastOutputSrc + "! tsdemux name=demux " +
+		"demux. ! video/x-h264 ! queue ! h264parse ! meter. " +
+		"demux. ! audio/mpeg ! queue ! aacparse ! meter. " +
+		fmt.Sprintf("rtspclientsink name=meter location=\"%s\" protocols=%s", location, transport), nil
+}
+
+// path is either absolute or relative to the recordings directory, e.g.
+// file:///recordings/stream.mkv or file:stream.mkv, it must not leave it
+func broadcastFilePipeline(u *url.URL, recordings string) (string, error) {
+	if recordings == "" {
+		return "", fmt.Errorf("file output requires recordings directory to be configured")
+	}
+
+	path := u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+
+	path, err := broadcastRecordingPath(recordings, path)
+	if err != nil {
+		return "", err
+	}
+
+	var mux string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ts":
+		// encoder output is written as it is
+		return broadcastOutputSrc + fmt.Sprintf("! filesink name=meter location=\"%s\"", path), nil
+	case ".mkv":
+		mux = "matroskamux name=mux"
+	case ".mp4":
+		// fragmented, so that file is playable even if not finished properly
+		mux = "mp4mux name=mux fragment-duration=1000"
+	case ".flv":
+		mux = "flvmux name=mux"
+	default:
+		return "", fmt.Errorf("unsupported file extension '%s'", filepath.Ext(path))
+	}
+
+	return broadcastOutputSrc + broadcastOutputDemux +
+		fmt.Sprintf("%s ! filesink name=meter location=\"%s\"", mux, path), nil
+}
+
+// file is resolved within the recordings directory, its parent must exist
+func broadcastRecordingPath(recordings string, name string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("file output requires path")
+	}
+
+	// path is quoted in the pipeline
+	if strings.ContainsAny(name, "\"\\") {
+		return "", fmt.Errorf("file output path contains invalid characters")
+	}
+
+	root, err := filepath.EvalSymlinks(recordings)
+	if err != nil {
+		return "", fmt.Errorf("recordings directory: %w", err)
+	}
+
+	if !filepath.IsAbs(name) {
+		name = filepath.Join(recordings, name)
+	}
+
+	// parent is resolved, so that symlinks cannot point outside
+	dir, err := filepath.EvalSymlinks(filepath.Dir(filepath.Clean(name)))
+	if err != nil {
+		return "", fmt.Errorf("file output directory: %w", err)
+	}
+
+	path := filepath.Join(dir, filepath.Base(name))
+	if rel, err := filepath.Rel(root, path); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("file output must be inside recordings directory")
+	}
+
+	return path, nil
+}
+
+type broadcastOutput struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+	name   string
+
+	pipeline   gst.Pipeline
+	pipelineMu sync.Mutex
+	pipelineFn func(url string) (string, error)
+	// samples of the shared encoder are pushed to appsrc
+	shared bool
+
+	url     string
+	started bool
+
+	supervisor  *pipelineSupervisor
+	diagnostics *pipelineDiagnostics
+
+	// health of current pipeline
+	stallTimeout time.Duration
+	monitorStop  chan struct{}
+	connectedAt  time.Time
+	pushed       atomic.Uint64
+	bitrate      atomic.Int64
+
+	// metrics
+	pipelinesCounter  prometheus.Counter
+	pipelinesActive   prometheus.Gauge
+	reconnectsCounter prometheus.Counter
+	sentCounter       prometheus.Counter
+}
+
+func broadcastOutputNew(logger zerolog.Logger, name string, url string, pipelineFn func(url string) (string, error), shared bool, stallTimeout time.Duration, statusFn func(id string, status types.CapturePipelineStatus)) *broadcastOutput {
+	logger = logger.With().Str("output", name).Logger()
+
+	labels := map[string]string{
+		"submodule":  "broadcast_output",
+		"video_id":   name,
+		"codec_name": "-",
+		"codec_type": "-",
+	}
+
+	output := &broadcastOutput{
+		logger:     logger,
+		name:       name,
+		pipelineFn: pipelineFn,
+		shared:     shared,
+		url:        url,
+
+		stallTimeout: stallTimeout,
+
+		// metrics
+		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:        "pipelines_total",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of created pipelines.",
+			ConstLabels: labels,
+		}),
+		pipelinesActive: promauto.NewGauge(prometheus.GaugeOpts{
+			Name:        "pipelines_active",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of active pipelines.",
+			ConstLabels: labels,
+		}),
+		reconnectsCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:        "broadcast_reconnects_total",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of reconnects of a broadcast output after failure.",
+			ConstLabels: labels,
+		}),
+		sentCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:        "broadcast_sent_bytes_total",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of bytes sent by a broadcast output.",
+			ConstLabels: labels,
+		}),
+	}
+
+	output.supervisor = newPipelineSupervisor(logger, "broadcast/"+name, output.restartPipeline, statusFn)
+	output.diagnostics = newPipelineDiagnostics(logger, output.supervisor, labels)
+
+	return output
+}
+
+// metrics are unregistered, so that output with the same name can be added again
+func (output *broadcastOutput) unregisterMetrics() {
+	prometheus.Unregister(output.pipelinesCounter)
+	prometheus.Unregister(output.pipelinesActive)
+	prometheus.Unregister(output.reconnectsCounter)
+	prometheus.Unregister(output.sentCounter)
+	output.diagnostics.unregisterMetrics()
+}
+
+func (output *broadcastOutput) start() error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	err := output.createPipeline()
+	if err != nil && err != types.ErrCapturePipelineAlreadyExists {
+		return err
+	}
+
+	output.started = true
+	return nil
+}
+
+func (output *broadcastOutput) stop() {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	output.started = false
+	output.destroyPipeline()
+	output.supervisor.stopped()
+}
+
+// restart pipeline after failure, if output was not stopped meanwhile
+func (output *broadcastOutput) restartPipeline(_ bool) error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	if !output.started {
+		output.supervisor.stopped()
+		return nil
+	}
+
+	output.reconnectsCounter.Inc()
+
+	output.destroyPipeline()
+	return output.createPipeline()
+}
+
+func (output *broadcastOutput) setUrl(url string) error {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	if output.started {
+		return types.ErrBroadcastOutputStarted
+	}
+
+	output.url = url
+	return nil
+}
+
+func (output *broadcastOutput) Started() bool {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	return output.started
+}
+
+func (output *broadcastOutput) Url() string {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	return output.url
+}
+
+func (output *broadcastOutput) Status() types.BroadcastOutput {
+	output.mu.Lock()
+	defer output.mu.Unlock()
+
+	supervisor := output.supervisor.Status()
+
+	status := types.BroadcastOutput{
+		Name:     output.name,
+		URL:      output.url,
+		IsActive: output.started,
+		State:    supervisor.State,
+		Retries:  supervisor.Retries,
+	}
+
+	if supervisor.State == types.CapturePipelineRecovering {
+		status.Error = supervisor.Error
+	}
+
+	output.pipelineMu.Lock()
+	if output.pipeline != nil {
+		status.Uptime = time.Since(output.connectedAt).Seconds()
+		status.Bitrate = int(output.bitrate.Load() / 1000)
+	}
+	output.pipelineMu.Unlock()
+
+	return status
+}
+
+// push sample of the shared encoder to the output
+func (output *broadcastOutput) push(sample types.Sample) {
+	output.pipelineMu.Lock()
+	defer output.pipelineMu.Unlock()
+
+	if output.pipeline != nil && output.shared {
+		output.pipeline.Push(sample.Data)
+		output.pushed.Add(uint64(len(sample.Data)))
+	}
+}
+
+// monitor measures bitrate of the pipeline and reports it as failed,
+// when encoder pushes data but nothing is sent for stall timeout
+func (output *broadcastOutput) monitor(pipeline gst.Pipeline, stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var lastSent, lastPushed uint64
+	var stalledSince time.Time
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		output.pipelineMu.Lock()
+		if output.pipeline != pipeline {
+			output.pipelineMu.Unlock()
+			return
+		}
+		sent := pipeline.BytesCount()
+		output.pipelineMu.Unlock()
+
+		pushed := output.pushed.Load()
+
+		output.bitrate.Store(int64(sent-lastSent) * 8)
+		output.sentCounter.Add(float64(sent - lastSent))
+
+		if sent != lastSent || pushed == lastPushed {
+			stalledSince = time.Time{}
+		} else if stalledSince.IsZero() {
+			stalledSince = time.Now()
+		} else if output.stallTimeout > 0 && time.Since(stalledSince) >= output.stallTimeout {
+			output.supervisor.failed(fmt.Errorf("output stalled, no data sent for %s", output.stallTimeout))
+			return
+		}
+
+		lastSent, lastPushed = sent, pushed
+	}
+}
+
+func (output *broadcastOutput) createPipeline() error {
+	output.pipelineMu.Lock()
+	defer output.pipelineMu.Unlock()
+
+	if output.pipeline != nil {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	pipelineStr, err := output.pipelineFn(output.url)
+	if err != nil {
+		return err
+	}
+
+	output.logger.Info().
+		Str("url", output.url).
+		Str("src", pipelineStr).
+		Msgf("starting pipeline")
+
+	output.pipeline, err = gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		return err
+	}
+
+	if output.shared {
+		output.pipeline.AttachAppsrc("appsrc")
+	}
+
+	// custom pipelines might not have the meter
+	if output.pipeline.AttachBytesCounter(broadcastOutputMeter) {
+		output.monitorStop = make(chan struct{})
+		go output.monitor(output.pipeline, output.monitorStop)
+	}
+
+	output.pipeline.Play()
+	output.connectedAt = time.Now()
+
+	output.diagnostics.created(output.pipeline)
+	output.supervisor.running(false)
+
+	output.pipelinesCounter.Inc()
+	output.pipelinesActive.Set(1)
+
+	return nil
+}
+
+func (output *broadcastOutput) Diagnostics() types.CapturePipelineDiagnostics {
+	output.pipelineMu.Lock()
+	defer output.pipelineMu.Unlock()
+
+	return output.diagnostics.Diagnostics(output.pipeline)
+}
+
+func (output *broadcastOutput) destroyPipeline() {
+	output.pipelineMu.Lock()
+	defer output.pipelineMu.Unlock()
+
+	if output.pipeline == nil {
+		return
+	}
+
+	if output.monitorStop != nil {
+		close(output.monitorStop)
+		output.monitorStop = nil
+	}
+
+	output.pipeline.Destroy()
+	output.logger.Info().Msgf("destroying pipeline")
+	output.pipeline = nil
+	output.diagnostics.destroyed()
+	output.bitrate.Store(0)
+
+	output.pipelinesActive.Set(0)
+}
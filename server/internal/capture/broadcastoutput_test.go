@@ -0,0 +1,238 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBroadcastOutputPipeline(t *testing.T) {
+	recordings := t.TempDir()
+
+	tests := []struct {
+		name     string
+		url      string
+		contains []string
+		wantErr  bool
+	}{
+		{
+			name:     "rtmp",
+			url:      "rtmp://localhost/live/key",
+			contains: []string{"flvmux name=mux", "rtmpsink name=meter location='rtmp://localhost/live/key live=1'"},
+		},
+		{
+			name:     "srt",
+			url:      "srt://localhost:9000?mode=caller",
+			contains: []string{"srtsink name=meter uri=\"srt://localhost:9000?mode=caller\""},
+		},
+		{
+			name:     "rtmp with query",
+			url:      "rtmp://localhost/live/key?token=a b",
+			contains: []string{"location='rtmp://localhost/live/key?token=a+b live=1'"},
+		},
+		{
+			name:     "rtmp with quote in query is escaped",
+			url:      "rtmp://h/app?x=' ! filesink location=/etc/foo '",
+			contains: []string{"location='rtmp://h/app?x=%27+%21+filesink+location%3D%2Fetc%2Ffoo+%27 live=1'"},
+		},
+		{
+			name:    "rtmp with quote in path",
+			url:     "rtmp://h/app'!filesink",
+			wantErr: true,
+		},
+		{
+			name:    "rtmp with exclamation mark in path",
+			url:     "rtmp://h/app!filesink",
+			wantErr: true,
+		},
+		{
+			name:     "srt with quote in query is escaped",
+			url:      "srt://localhost:9000?streamid=\" ! filesink location=/etc/foo \"",
+			contains: []string{"uri=\"srt://localhost:9000?streamid=%22+%21+filesink+location%3D%2Fetc%2Ffoo+%22\""},
+		},
+		{
+			name:    "srt with quote in fragment",
+			url:     "srt://localhost:9000#a'b",
+			wantErr: true,
+		},
+		{
+			name:     "rtsp with quote in query is escaped",
+			url:      "rtsp://localhost:8554/live?x=\"",
+			contains: []string{"location=\"rtsp://localhost:8554/live?x=%22\""},
+		},
+		{
+			name:    "srt without port",
+			url:     "srt://localhost",
+			wantErr: true,
+		},
+		{
+			name:     "rtsp with default transport",
+			url:      "rtsp://localhost:8554/live",
+			contains: []string{"h264parse ! meter.", "aacparse ! meter.", "rtspclientsink name=meter location=\"rtsp://localhost:8554/live\" protocols=tcp"},
+		},
+		{
+			name:     "rtsp with udp transport",
+			url:      "rtsp://localhost:8554/live?transport=udp",
+			contains: []string{"rtspclientsink name=meter location=\"rtsp://localhost:8554/live\" protocols=udp"},
+		},
+		{
+			name:    "rtsp with unknown transport",
+			url:     "rtsp://localhost:8554/live?transport=http",
+			wantErr: true,
+		},
+		{
+			name:     "file relative to recordings",
+			url:      "file:stream.mkv",
+			contains: []string{"matroskamux name=mux", "filesink name=meter location=\"" + filepath.Join(recordings, "stream.mkv") + "\""},
+		},
+		{
+			name:     "absolute file inside recordings",
+			url:      "file://" + filepath.Join(recordings, "stream.ts"),
+			contains: []string{"filesink name=meter location=\"" + filepath.Join(recordings, "stream.ts") + "\""},
+		},
+		{
+			name:    "file with unsupported extension",
+			url:     "file:stream.txt",
+			wantErr: true,
+		},
+		{
+			name:    "unsupported scheme",
+			url:     "http://localhost/live",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline, err := broadcastOutputPipeline(tt.url, recordings)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("broadcastOutputPipeline() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err != nil {
+				return
+			}
+
+			if !strings.HasPrefix(pipeline, broadcastOutputSrc) {
+				t.Errorf("broadcastOutputPipeline() = %q, does not start with appsrc", pipeline)
+			}
+
+			for _, part := range tt.contains {
+				if !strings.Contains(pipeline, part) {
+					t.Errorf("broadcastOutputPipeline() = %q, does not contain %q", pipeline, part)
+				}
+			}
+		})
+	}
+}
+
+func TestBroadcastRtspLaunch(t *testing.T) {
+	// rtsp media factory expects pipeline in brackets with payloaders named pay0, pay1, ...
+	for _, part := range []string{"( ", "appsrc name=appsrc ", "name=pay0 ", "name=pay1 ", " )"} {
+		if !strings.Contains(broadcastRtspLaunch, part) {
+			t.Errorf("broadcastRtspLaunch = %q, does not contain %q", broadcastRtspLaunch, part)
+		}
+	}
+
+	// encoder output is remuxed, never encoded again
+	for _, encoder := range []string{"x264enc", "voaacenc", "ximagesrc", "pulsesrc"} {
+		if strings.Contains(broadcastRtspLaunch, encoder) {
+			t.Errorf("broadcastRtspLaunch = %q, contains %q", broadcastRtspLaunch, encoder)
+		}
+	}
+}
+
+func TestBroadcastRecordingPath(t *testing.T) {
+	root := t.TempDir()
+	recordings := filepath.Join(root, "recordings")
+	outside := filepath.Join(root, "outside")
+
+	for _, dir := range []string{recordings, filepath.Join(recordings, "sub"), outside} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink(outside, filepath.Join(recordings, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "relative file",
+			path: "stream.mkv",
+			want: filepath.Join(recordings, "stream.mkv"),
+		},
+		{
+			name: "relative file in subdirectory",
+			path: "sub/stream.mkv",
+			want: filepath.Join(recordings, "sub", "stream.mkv"),
+		},
+		{
+			name: "absolute file inside",
+			path: filepath.Join(recordings, "stream.mkv"),
+			want: filepath.Join(recordings, "stream.mkv"),
+		},
+		{
+			name:    "empty path",
+			path:    "",
+			wantErr: true,
+		},
+		{
+			name:    "recordings directory itself",
+			path:    recordings,
+			wantErr: true,
+		},
+		{
+			name:    "parent traversal",
+			path:    "../outside/stream.mkv",
+			wantErr: true,
+		},
+		{
+			name:    "traversal in absolute path",
+			path:    filepath.Join(recordings, "..", "outside", "stream.mkv"),
+			wantErr: true,
+		},
+		{
+			name:    "absolute file outside",
+			path:    "/etc/stream.mkv",
+			wantErr: true,
+		},
+		{
+			name:    "symlink pointing outside",
+			path:    "link/stream.mkv",
+			wantErr: true,
+		},
+		{
+			name:    "missing directory",
+			path:    "missing/stream.mkv",
+			wantErr: true,
+		},
+		{
+			name:    "quote in path",
+			path:    "stream\".mkv",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := broadcastRecordingPath(recordings, tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("broadcastRecordingPath() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("broadcastRecordingPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
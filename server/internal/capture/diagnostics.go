@@ -0,0 +1,208 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/types"
+)
+
+// pipelineDiagnostics reads bus messages of current pipeline, keeps its
+// diagnostics and metrics, and reports its failures to the supervisor.
+type pipelineDiagnostics struct {
+	logger     zerolog.Logger
+	supervisor *pipelineSupervisor
+
+	mu sync.Mutex
+	// incremented with every created and destroyed pipeline, so that
+	// messages of pipelines that are no longer current are dropped
+	generation  uint64
+	state       gst.State
+	errors      uint64
+	lastError   string
+	warnings    uint64
+	lastWarning string
+
+	// qos stats are cumulative per element, for current pipeline
+	processed map[string]uint64
+	dropped   map[string]uint64
+
+	// element messages are passed along, set before any pipeline is created
+	elementFn func(pipeline gst.Pipeline, msg gst.Message)
+
+	// metrics
+	stateGauge      prometheus.Gauge
+	errorsCounter   prometheus.Counter
+	warningsCounter prometheus.Counter
+	droppedCounter  prometheus.Counter
+}
+
+func newPipelineDiagnostics(logger zerolog.Logger, supervisor *pipelineSupervisor, labels map[string]string) *pipelineDiagnostics {
+	return &pipelineDiagnostics{
+		logger:     logger,
+		supervisor: supervisor,
+
+		processed: map[string]uint64{},
+		dropped:   map[string]uint64{},
+
+		// metrics
+		stateGauge: promauto.NewGauge(prometheus.GaugeOpts{
+			Name:        "pipeline_state",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Current GStreamer state of a pipeline (1 null, 2 ready, 3 paused, 4 playing).",
+			ConstLabels: labels,
+		}),
+		errorsCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:        "pipeline_errors_total",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of errors posted on the pipeline bus.",
+			ConstLabels: labels,
+		}),
+		warningsCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:        "pipeline_warnings_total",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of warnings posted on the pipeline bus.",
+			ConstLabels: labels,
+		}),
+		droppedCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:        "pipeline_dropped_frames_total",
+			Namespace:   "neko",
+			Subsystem:   "capture",
+			Help:        "Total number of frames dropped by pipeline elements.",
+			ConstLabels: labels,
+		}),
+	}
+}
+
+// metrics are unregistered, so that pipeline with the same labels can be created again
+func (d *pipelineDiagnostics) unregisterMetrics() {
+	prometheus.Unregister(d.stateGauge)
+	prometheus.Unregister(d.errorsCounter)
+	prometheus.Unregister(d.warningsCounter)
+	prometheus.Unregister(d.droppedCounter)
+}
+
+// new pipeline was created, its bus messages are watched until it is destroyed
+func (d *pipelineDiagnostics) created(pipeline gst.Pipeline) {
+	d.mu.Lock()
+	d.generation++
+	generation := d.generation
+	d.processed = map[string]uint64{}
+	d.dropped = map[string]uint64{}
+	d.mu.Unlock()
+
+	go func() {
+		for msg := range pipeline.Messages() {
+			if msg.Type == gst.MessageElement {
+				if d.elementFn != nil && d.current(generation) {
+					d.elementFn(pipeline, msg)
+				}
+				continue
+			}
+
+			d.handle(generation, msg)
+		}
+	}()
+}
+
+func (d *pipelineDiagnostics) destroyed() {
+	d.mu.Lock()
+	d.generation++
+	d.state = gst.StateNull
+	d.mu.Unlock()
+
+	d.stateGauge.Set(float64(gst.StateNull))
+}
+
+func (d *pipelineDiagnostics) current(generation uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.generation == generation
+}
+
+// messages are handled only if they come from the current pipeline
+func (d *pipelineDiagnostics) handle(generation uint64, msg gst.Message) {
+	d.mu.Lock()
+
+	if d.generation != generation {
+		d.mu.Unlock()
+		d.logger.Debug().Str("type", msg.Type.String()).Msg("dropping message of destroyed pipeline")
+		return
+	}
+
+	var failedErr error
+
+	switch msg.Type {
+	case gst.MessageError, gst.MessageEOS:
+		err := fmt.Errorf("%s from element %s: %s", msg.Type, msg.Source, msg.Text)
+
+		d.errors++
+		d.lastError = err.Error()
+		d.errorsCounter.Inc()
+
+		failedErr = err
+	case gst.MessageWarning:
+		d.warnings++
+		d.lastWarning = fmt.Sprintf("warning from element %s: %s", msg.Source, msg.Text)
+		d.warningsCounter.Inc()
+	case gst.MessageStateChanged:
+		d.state = msg.NewState
+		d.stateGauge.Set(float64(msg.NewState))
+	case gst.MessageQoS:
+		if last := d.dropped[msg.Source]; msg.Dropped > last {
+			d.droppedCounter.Add(float64(msg.Dropped - last))
+		}
+		d.processed[msg.Source] = msg.Processed
+		d.dropped[msg.Source] = msg.Dropped
+	case gst.MessageLatency:
+		d.logger.Debug().Str("element", msg.Source).Msg("pipeline latency changed")
+	}
+
+	d.mu.Unlock()
+
+	// supervisor restarts pipeline, that needs to lock diagnostics again
+	if failedErr != nil {
+		d.supervisor.failed(failedErr)
+	}
+}
+
+// pipeline is used only while its owner holds the pipeline lock
+func (d *pipelineDiagnostics) Diagnostics(pipeline gst.Pipeline) types.CapturePipelineDiagnostics {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	diagnostics := types.CapturePipelineDiagnostics{
+		State:       d.state.String(),
+		Errors:      d.errors,
+		LastError:   d.lastError,
+		Warnings:    d.warnings,
+		LastWarning: d.lastWarning,
+	}
+
+	for _, processed := range d.processed {
+		diagnostics.ProcessedFrames += processed
+	}
+	for _, dropped := range d.dropped {
+		diagnostics.DroppedFrames += dropped
+	}
+
+	if pipeline != nil {
+		if latency, ok := pipeline.QueryLatency(); ok {
+			diagnostics.Latency = float64(latency) / float64(time.Millisecond)
+		}
+	} else {
+		diagnostics.State = gst.StateNull.String()
+	}
+
+	return diagnostics
+}
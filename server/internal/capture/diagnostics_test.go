@@ -0,0 +1,104 @@
+package capture
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/types"
+)
+
+func TestPipelineDiagnosticsHandle(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  gst.Message
+		// message comes from pipeline, that was replaced in the meantime
+		stale      bool
+		wantState  gst.State
+		wantErrors uint64
+		wantStatus types.CapturePipelineState
+	}{
+		{
+			name:       "error of current pipeline",
+			msg:        gst.Message{Type: gst.MessageError, Source: "src", Text: "failed"},
+			wantState:  gst.StateVoidPending,
+			wantErrors: 1,
+			wantStatus: types.CapturePipelineRecovering,
+		},
+		{
+			name:       "error of destroyed pipeline",
+			msg:        gst.Message{Type: gst.MessageError, Source: "src", Text: "failed"},
+			stale:      true,
+			wantState:  gst.StateNull,
+			wantStatus: types.CapturePipelineRunning,
+		},
+		{
+			name:       "eos of destroyed pipeline",
+			msg:        gst.Message{Type: gst.MessageEOS, Source: "src"},
+			stale:      true,
+			wantState:  gst.StateNull,
+			wantStatus: types.CapturePipelineRunning,
+		},
+		{
+			name:       "state of current pipeline",
+			msg:        gst.Message{Type: gst.MessageStateChanged, NewState: gst.StatePlaying},
+			wantState:  gst.StatePlaying,
+			wantStatus: types.CapturePipelineRunning,
+		},
+		{
+			name:       "state of destroyed pipeline",
+			msg:        gst.Message{Type: gst.MessageStateChanged, NewState: gst.StatePlaying},
+			stale:      true,
+			wantState:  gst.StateNull,
+			wantStatus: types.CapturePipelineRunning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supervisor := newPipelineSupervisor(zerolog.Nop(), "test", func(bool) error { return nil }, nil)
+			defer supervisor.stopped()
+
+			// labels must match those of stream sinks, as metrics share their names
+			d := newPipelineDiagnostics(zerolog.Nop(), supervisor, map[string]string{
+				"submodule":  "streamsink",
+				"video_id":   "diagnostics-" + tt.name,
+				"codec_name": "test",
+				"codec_type": "video",
+			})
+			defer d.unregisterMetrics()
+
+			// pipeline was created and is running
+			d.mu.Lock()
+			d.generation++
+			generation := d.generation
+			d.mu.Unlock()
+			supervisor.running(false)
+
+			// pipeline was replaced by a new one
+			if tt.stale {
+				d.destroyed()
+				d.mu.Lock()
+				d.generation++
+				d.mu.Unlock()
+			}
+
+			d.handle(generation, tt.msg)
+
+			d.mu.Lock()
+			gotState, gotErrors := d.state, d.errors
+			d.mu.Unlock()
+
+			if gotState != tt.wantState {
+				t.Errorf("state = %v, want %v", gotState, tt.wantState)
+			}
+			if gotErrors != tt.wantErrors {
+				t.Errorf("errors = %v, want %v", gotErrors, tt.wantErrors)
+			}
+			if got := supervisor.Status().State; got != tt.wantStatus {
+				t.Errorf("supervisor state = %v, want %v", got, tt.wantStatus)
+			}
+		})
+	}
+}
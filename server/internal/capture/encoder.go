@@ -0,0 +1,130 @@
+package capture
+
+import (
+	"errors"
+	"fmt"
+
+	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/types"
+)
+
+type encoderProps struct {
+	// bitrate property and how many of its units are in one kbit/s
+	bitrate      string
+	bitrateScale int
+	// keyframe interval property, in frames
+	keyframe string
+}
+
+// known encoders, whose properties can be changed while playing
+var encoders = map[string]encoderProps{
+	"x264enc":      {bitrate: "bitrate", bitrateScale: 1, keyframe: "key-int-max"},
+	"openh264enc":  {bitrate: "bitrate", bitrateScale: 1000, keyframe: "gop-size"},
+	"nvh264enc":    {bitrate: "bitrate", bitrateScale: 1, keyframe: "gop-size"},
+	"vaapih264enc": {bitrate: "bitrate", bitrateScale: 1, keyframe: "keyframe-period"},
+	"vp8enc":       {bitrate: "target-bitrate", bitrateScale: 1000, keyframe: "keyframe-max-dist"},
+	"vp9enc":       {bitrate: "target-bitrate", bitrateScale: 1000, keyframe: "keyframe-max-dist"},
+	"vaapivp8enc":  {bitrate: "bitrate", bitrateScale: 1, keyframe: "keyframe-period"},
+	"vaapivp9enc":  {bitrate: "bitrate", bitrateScale: 1, keyframe: "keyframe-period"},
+	"rav1enc":      {bitrate: "bitrate", bitrateScale: 1000, keyframe: "max-key-frame-interval"},
+	"svtav1enc":    {bitrate: "target-bitrate", bitrateScale: 1, keyframe: "intra-period-length"},
+	"x265enc":      {bitrate: "bitrate", bitrateScale: 1, keyframe: "key-int-max"},
+	"nvh265enc":    {bitrate: "bitrate", bitrateScale: 1, keyframe: "gop-size"},
+	"vaapih265enc": {bitrate: "bitrate", bitrateScale: 1, keyframe: "keyframe-period"},
+}
+
+// validateEncoderParams checks that params are valid and can be applied to the encoder,
+// pipeline is checked only if it is running, so that nothing is applied partially.
+func validateEncoderParams(pipeline gst.Pipeline, encoder string, params types.EncoderParams) error {
+	if params.Bitrate != nil && *params.Bitrate <= 0 {
+		return errors.New("bitrate must be positive")
+	}
+	if params.Fps != nil && *params.Fps <= 0 {
+		return errors.New("fps must be positive")
+	}
+	if params.KeyframeInterval != nil && *params.KeyframeInterval <= 0 {
+		return errors.New("keyframe interval must be positive")
+	}
+
+	props, known := encoders[encoder]
+
+	if params.Bitrate != nil {
+		if !known {
+			return fmt.Errorf("%w: bitrate of encoder '%s'", types.ErrCaptureEncoderParamNotSupported, encoder)
+		}
+
+		if _, ok := pipelineProp(pipeline, props.bitrate); !ok {
+			return fmt.Errorf("%w: encoder element not found", types.ErrCaptureEncoderParamNotSupported)
+		}
+	}
+
+	if params.KeyframeInterval != nil {
+		if !known {
+			return fmt.Errorf("%w: keyframe interval of encoder '%s'", types.ErrCaptureEncoderParamNotSupported, encoder)
+		}
+
+		if _, ok := pipelineProp(pipeline, props.keyframe); !ok {
+			return fmt.Errorf("%w: encoder element not found", types.ErrCaptureEncoderParamNotSupported)
+		}
+	}
+
+	if params.Fps != nil && pipeline != nil && !pipeline.HasElement("framerate") {
+		return fmt.Errorf("%w: framerate element not found", types.ErrCaptureEncoderParamNotSupported)
+	}
+
+	return nil
+}
+
+// pipelineProp returns encoder property of a running pipeline, not running pipeline is not checked
+func pipelineProp(pipeline gst.Pipeline, prop string) (int, bool) {
+	if pipeline == nil {
+		return 0, true
+	}
+
+	return pipeline.GetPropInt("encoder", prop)
+}
+
+// encoderBitrate returns current bitrate of a running encoder in kbit/s
+func encoderBitrate(pipeline gst.Pipeline, encoder string) (int, bool) {
+	props, known := encoders[encoder]
+	if !known || pipeline == nil {
+		return 0, false
+	}
+
+	bitrate, ok := pipeline.GetPropInt("encoder", props.bitrate)
+	return bitrate / props.bitrateScale, ok
+}
+
+// setEncoderParams applies encoder params to named elements of a running pipeline:
+// encoder for bitrate and keyframe interval, framerate capsfilter for fps.
+func setEncoderParams(pipeline gst.Pipeline, encoder string, params types.EncoderParams) error {
+	props, known := encoders[encoder]
+
+	if params.Bitrate != nil {
+		if !known {
+			return fmt.Errorf("%w: bitrate of encoder '%s'", types.ErrCaptureEncoderParamNotSupported, encoder)
+		}
+
+		if !pipeline.SetPropInt("encoder", props.bitrate, *params.Bitrate*props.bitrateScale) {
+			return fmt.Errorf("%w: encoder element not found", types.ErrCaptureEncoderParamNotSupported)
+		}
+	}
+
+	if params.KeyframeInterval != nil {
+		if !known {
+			return fmt.Errorf("%w: keyframe interval of encoder '%s'", types.ErrCaptureEncoderParamNotSupported, encoder)
+		}
+
+		if !pipeline.SetPropInt("encoder", props.keyframe, *params.KeyframeInterval) {
+			return fmt.Errorf("%w: encoder element not found", types.ErrCaptureEncoderParamNotSupported)
+		}
+	}
+
+	if params.Fps != nil {
+		if !pipeline.SetCapsFramerate("framerate", *params.Fps, 1) {
+			return fmt.Errorf("%w: framerate element not found", types.ErrCaptureEncoderParamNotSupported)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package capture
+
+import (
+	"errors"
+	"testing"
+
+	"m1k1o/neko/pkg/types"
+)
+
+func TestValidateEncoderParams(t *testing.T) {
+	value := func(v int) *int {
+		return &v
+	}
+
+	tests := []struct {
+		name    string
+		encoder string
+		params  types.EncoderParams
+		wantErr error
+	}{
+		{
+			name:    "empty params",
+			encoder: "x264enc",
+			params:  types.EncoderParams{},
+		},
+		{
+			name:    "all params of known encoder",
+			encoder: "vp8enc",
+			params: types.EncoderParams{
+				Bitrate:          value(1000),
+				Fps:              value(30),
+				KeyframeInterval: value(60),
+			},
+		},
+		{
+			name:    "fps of unknown encoder",
+			encoder: "",
+			params:  types.EncoderParams{Fps: value(30)},
+		},
+		{
+			name:    "bitrate of unknown encoder",
+			encoder: "",
+			params:  types.EncoderParams{Bitrate: value(1000)},
+			wantErr: types.ErrCaptureEncoderParamNotSupported,
+		},
+		{
+			name:    "keyframe interval of unknown encoder",
+			encoder: "customenc",
+			params:  types.EncoderParams{KeyframeInterval: value(60)},
+			wantErr: types.ErrCaptureEncoderParamNotSupported,
+		},
+		{
+			name:    "invalid value is rejected before unsupported one",
+			encoder: "",
+			params: types.EncoderParams{
+				Bitrate: value(1000),
+				Fps:     value(0),
+			},
+			wantErr: errors.New("fps must be positive"),
+		},
+		{
+			name:    "negative bitrate",
+			encoder: "x264enc",
+			params:  types.EncoderParams{Bitrate: value(-1)},
+			wantErr: errors.New("bitrate must be positive"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEncoderParams(nil, tt.encoder, tt.params)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("validateEncoderParams() error = %v, want nil", err)
+				}
+				return
+			}
+
+			if err == nil || (!errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error()) {
+				t.Errorf("validateEncoderParams() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
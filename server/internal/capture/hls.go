@@ -0,0 +1,484 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"m1k1o/neko/pkg/gst"
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/codec"
+)
+
+// segmenter is stopped, when nobody requested playlist or segment for this long
+const hlsTimeout = 30 * time.Second
+
+// element message posted by hlssink2, after playlist with new segment is written
+const hlsSegmentAdded = "hls-segment-added"
+
+var hlsSegmentRegex = regexp.MustCompile(`^segment[0-9]+\.ts$`)
+
+// decoder of audio sink samples, audio is encoded to AAC for HLS, raw
+// samples have no header, so that their channels must be known
+func hlsAudioDecoder(audioCodec codec.RTPCodec, channels int) (string, bool) {
+	var caps, decoder string
+	switch audioCodec.Name {
+	case codec.Opus().Name:
+		return "audio/x-opus,channel-mapping-family=0 ! opusdec", true
+	case codec.PCMU().Name:
+		caps, decoder = "audio/x-mulaw", "mulawdec"
+	case codec.PCMA().Name:
+		caps, decoder = "audio/x-alaw", "alawdec"
+	default:
+		return "", false
+	}
+
+	if channels <= 0 {
+		return "", false
+	}
+
+	return fmt.Sprintf("%s,rate=%d,channels=%d ! %s", caps, audioCodec.Capability.ClockRate, channels, decoder), true
+}
+
+// hlsListener pushes samples of a stream sink to named appsrc of the segmenter
+type hlsListener struct {
+	manager *HlsManagerCtx
+	srcName string
+}
+
+func (l *hlsListener) WriteSample(sample types.Sample) {
+	l.manager.push(l.srcName, sample.Data)
+}
+
+type HlsManagerCtx struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+	wg     sync.WaitGroup
+
+	pipeline   gst.Pipeline
+	pipelineMu sync.Mutex
+	dir        string
+	// closed when first segment of current pipeline is written
+	ready chan struct{}
+
+	video         *StreamSelectorManagerCtx
+	videoID       string
+	videoStream   types.StreamSinkManager
+	videoListener *hlsListener
+	audio         *StreamSinkManagerCtx
+	audioDecoder  string
+	audioListener *hlsListener
+
+	// in seconds
+	segmentDuration int
+	playlistLength  int
+
+	tickerStop chan struct{}
+
+	enabled bool
+	started bool
+	expired int32
+
+	supervisor  *pipelineSupervisor
+	diagnostics *pipelineDiagnostics
+
+	// metrics
+	pipelinesCounter prometheus.Counter
+	pipelinesActive  prometheus.Gauge
+}
+
+// audioChannels of the audio sink are 0, when they are not known
+func hlsNew(enabled bool, video *StreamSelectorManagerCtx, videoID string, audio *StreamSinkManagerCtx, audioChannels int, segmentDuration int, playlistLength int, statusFn func(id string, status types.CapturePipelineStatus)) *HlsManagerCtx {
+	logger := log.With().
+		Str("module", "capture").
+		Str("submodule", "hls").
+		Logger()
+
+	// segments can only contain h264 video
+	if enabled && video.Codec().Name != codec.H264().Name {
+		logger.Warn().Str("codec", video.Codec().Name).Msg("hls requires h264 video codec, disabling")
+		enabled = false
+	}
+
+	audioDecoder, ok := hlsAudioDecoder(audio.Codec(), audioChannels)
+	if enabled && !ok {
+		logger.Warn().Str("codec", audio.Codec().Name).Msg("hls does not support audio codec, streaming video only")
+	}
+
+	manager := &HlsManagerCtx{
+		logger:          logger,
+		video:           video,
+		videoID:         videoID,
+		audio:           audio,
+		audioDecoder:    audioDecoder,
+		segmentDuration: segmentDuration,
+		playlistLength:  playlistLength,
+		tickerStop:      make(chan struct{}),
+		enabled:         enabled,
+		started:         false,
+
+		// metrics
+		pipelinesCounter: promauto.NewCounter(prometheus.CounterOpts{
+			Name:      "pipelines_total",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of created pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "hls",
+				"video_id":   "main",
+				"codec_name": "-",
+				"codec_type": "-",
+			},
+		}),
+		pipelinesActive: promauto.NewGauge(prometheus.GaugeOpts{
+			Name:      "pipelines_active",
+			Namespace: "neko",
+			Subsystem: "capture",
+			Help:      "Total number of active pipelines.",
+			ConstLabels: map[string]string{
+				"submodule":  "hls",
+				"video_id":   "main",
+				"codec_name": "-",
+				"codec_type": "-",
+			},
+		}),
+	}
+
+	manager.videoListener = &hlsListener{manager: manager, srcName: "videosrc"}
+	manager.audioListener = &hlsListener{manager: manager, srcName: "audiosrc"}
+
+	manager.supervisor = newPipelineSupervisor(logger, "hls", manager.restartPipeline, statusFn)
+	manager.diagnostics = newPipelineDiagnostics(logger, manager.supervisor, map[string]string{
+		"submodule":  "hls",
+		"video_id":   "main",
+		"codec_name": "-",
+		"codec_type": "-",
+	})
+	manager.diagnostics.elementFn = manager.elementMessage
+
+	// follow listener, when its video pipeline is removed
+	video.OnStreamMoved(func(from, to types.StreamSinkManager) {
+		manager.mu.Lock()
+		defer manager.mu.Unlock()
+
+		if manager.videoStream == from {
+			manager.videoStream = to
+		}
+	})
+
+	manager.wg.Add(1)
+
+	go func() {
+		defer manager.wg.Done()
+
+		ticker := time.NewTicker(hlsTimeout)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-manager.tickerStop:
+				return
+			case <-ticker.C:
+				if manager.Started() && !atomic.CompareAndSwapInt32(&manager.expired, 0, 1) {
+					manager.stop()
+				}
+			}
+		}
+	}()
+
+	return manager
+}
+
+func (manager *HlsManagerCtx) shutdown() {
+	manager.logger.Info().Msgf("shutdown")
+
+	manager.stop()
+
+	close(manager.tickerStop)
+	manager.wg.Wait()
+}
+
+func (manager *HlsManagerCtx) Enabled() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.enabled
+}
+
+func (manager *HlsManagerCtx) Started() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	return manager.started
+}
+
+func (manager *HlsManagerCtx) Playlist(ctx context.Context) ([]byte, error) {
+	atomic.StoreInt32(&manager.expired, 0)
+
+	if err := manager.start(); err != nil {
+		return nil, err
+	}
+
+	manager.pipelineMu.Lock()
+	dir, ready := manager.dir, manager.ready
+	manager.pipelineMu.Unlock()
+
+	if ready == nil {
+		return nil, errors.New("hls pipeline is not running")
+	}
+
+	// first segment must be written, before playlist exists
+	timeout := time.NewTimer(time.Duration(3*manager.segmentDuration)*time.Second + 2*time.Second)
+	defer timeout.Stop()
+
+	select {
+	case <-ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timeout.C:
+		return nil, errors.New("timeouted while waiting for playlist")
+	}
+
+	return os.ReadFile(filepath.Join(dir, types.HlsPlaylistName))
+}
+
+// pipeline is ready, when it announces its first segment
+func (manager *HlsManagerCtx) elementMessage(pipeline gst.Pipeline, msg gst.Message) {
+	if msg.Text != hlsSegmentAdded {
+		return
+	}
+
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline != pipeline || manager.ready == nil {
+		return
+	}
+
+	select {
+	case <-manager.ready:
+	default:
+		close(manager.ready)
+	}
+}
+
+func (manager *HlsManagerCtx) Segment(name string) (string, error) {
+	atomic.StoreInt32(&manager.expired, 0)
+
+	if !hlsSegmentRegex.MatchString(name) {
+		return "", os.ErrNotExist
+	}
+
+	manager.pipelineMu.Lock()
+	dir := manager.dir
+	manager.pipelineMu.Unlock()
+
+	if dir == "" {
+		return "", os.ErrNotExist
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func (manager *HlsManagerCtx) start() error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.enabled {
+		return errors.New("hls not enabled")
+	}
+
+	if manager.started {
+		return nil
+	}
+
+	err := manager.createPipeline()
+	if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return err
+	}
+
+	videoID := manager.videoID
+	if videoID == "" {
+		// last configured pipeline is usually the best one
+		ids := manager.video.IDs()
+		if len(ids) == 0 {
+			manager.destroyPipeline()
+			return errors.New("no video stream available")
+		}
+
+		videoID = ids[len(ids)-1]
+	}
+
+	stream, ok := manager.video.GetStream(types.StreamSelector{ID: videoID})
+	if !ok {
+		manager.destroyPipeline()
+		return fmt.Errorf("video stream '%s' not found", videoID)
+	}
+
+	if err := stream.AddListener(manager.videoListener); err != nil {
+		manager.destroyPipeline()
+		return err
+	}
+
+	manager.videoStream = stream
+
+	if manager.audioDecoder != "" {
+		if err := manager.audio.AddListener(manager.audioListener); err != nil {
+			manager.logger.Err(err).Msg("unable to add audio listener, streaming video only")
+		}
+	}
+
+	manager.started = true
+	return nil
+}
+
+func (manager *HlsManagerCtx) stop() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if manager.videoStream != nil {
+		if err := manager.videoStream.RemoveListener(manager.videoListener); err != nil {
+			manager.logger.Err(err).Msg("unable to remove video listener")
+		}
+		manager.videoStream = nil
+	}
+
+	if manager.started && manager.audioDecoder != "" {
+		if err := manager.audio.RemoveListener(manager.audioListener); err != nil {
+			manager.logger.Err(err).Msg("unable to remove audio listener")
+		}
+	}
+
+	manager.started = false
+	manager.destroyPipeline()
+	manager.supervisor.stopped()
+}
+
+// restart pipeline after failure, if hls was not stopped meanwhile
+func (manager *HlsManagerCtx) restartPipeline(_ bool) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.started {
+		manager.supervisor.stopped()
+		return nil
+	}
+
+	manager.destroyPipeline()
+	return manager.createPipeline()
+}
+
+func (manager *HlsManagerCtx) push(srcName string, data []byte) {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline != nil {
+		manager.pipeline.PushTo(srcName, data)
+	}
+}
+
+func (manager *HlsManagerCtx) createPipeline() error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline != nil {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	// every pipeline writes to its own directory, removed when destroyed
+	dir, err := os.MkdirTemp("", "neko-hls-")
+	if err != nil {
+		return err
+	}
+
+	pipelineStr := fmt.Sprintf(
+		"hlssink2 name=hls target-duration=%d playlist-length=%d max-files=%d "+
+			"location=\"%s\" playlist-location=\"%s\" send-keyframe-requests=false "+
+			"appsrc name=videosrc format=time is-live=true do-timestamp=true "+
+			"! video/x-h264,stream-format=byte-stream "+
+			"! h264parse "+
+			"! queue "+
+			"! hls.video ",
+		manager.segmentDuration, manager.playlistLength, manager.playlistLength+2,
+		filepath.Join(dir, "segment%05d.ts"), filepath.Join(dir, types.HlsPlaylistName),
+	)
+
+	if manager.audioDecoder != "" {
+		pipelineStr += fmt.Sprintf(
+			"appsrc name=audiosrc format=time is-live=true do-timestamp=true "+
+				"! %s "+
+				"! audioconvert "+
+				"! audioresample "+
+				"! voaacenc bitrate=128000 "+
+				"! aacparse "+
+				"! queue "+
+				"! hls.audio", manager.audioDecoder,
+		)
+	}
+
+	manager.logger.Info().
+		Str("dir", dir).
+		Str("src", pipelineStr).
+		Msgf("creating pipeline")
+
+	manager.pipeline, err = gst.CreatePipeline(pipelineStr)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+
+	manager.dir = dir
+	manager.ready = make(chan struct{})
+	manager.pipeline.Play()
+	manager.pipelinesCounter.Inc()
+	manager.pipelinesActive.Set(1)
+
+	manager.diagnostics.created(manager.pipeline)
+	manager.supervisor.running(false)
+	return nil
+}
+
+func (manager *HlsManagerCtx) Diagnostics() types.CapturePipelineDiagnostics {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	return manager.diagnostics.Diagnostics(manager.pipeline)
+}
+
+func (manager *HlsManagerCtx) destroyPipeline() {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return
+	}
+
+	manager.pipeline.Destroy()
+	manager.logger.Info().Msgf("destroying pipeline")
+	manager.pipeline = nil
+	manager.diagnostics.destroyed()
+
+	if err := os.RemoveAll(manager.dir); err != nil {
+		manager.logger.Err(err).Str("dir", manager.dir).Msg("unable to remove segments")
+	}
+	manager.dir = ""
+	manager.ready = nil
+
+	manager.pipelinesActive.Set(0)
+}
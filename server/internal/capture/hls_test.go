@@ -0,0 +1,58 @@
+package capture
+
+import (
+	"testing"
+
+	"m1k1o/neko/pkg/types/codec"
+)
+
+func TestHlsAudioDecoder(t *testing.T) {
+	tests := []struct {
+		name     string
+		codec    codec.RTPCodec
+		channels int
+		want     string
+		wantOk   bool
+	}{
+		{
+			name:     "opus does not need channels",
+			codec:    codec.Opus(),
+			channels: 0,
+			want:     "audio/x-opus,channel-mapping-family=0 ! opusdec",
+			wantOk:   true,
+		},
+		{
+			name:     "pcmu",
+			codec:    codec.PCMU(),
+			channels: 2,
+			want:     "audio/x-mulaw,rate=8000,channels=2 ! mulawdec",
+			wantOk:   true,
+		},
+		{
+			name:     "pcma mono",
+			codec:    codec.PCMA(),
+			channels: 1,
+			want:     "audio/x-alaw,rate=8000,channels=1 ! alawdec",
+			wantOk:   true,
+		},
+		{
+			name:     "pcmu with unknown channels",
+			codec:    codec.PCMU(),
+			channels: 0,
+		},
+		{
+			name:     "unsupported codec",
+			codec:    codec.G722(),
+			channels: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := hlsAudioDecoder(tt.codec, tt.channels)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("hlsAudioDecoder() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
+	"github.com/kataras/go-events"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"m1k1o/neko/internal/config"
+	"m1k1o/neko/pkg/gst"
 	"m1k1o/neko/pkg/types"
 	"m1k1o/neko/pkg/types/codec"
 )
@@ -17,11 +20,18 @@ type CaptureManagerCtx struct {
 	logger  zerolog.Logger
 	desktop types.DesktopManager
 	config  *config.Capture
+	emmiter events.EventEmmiter
+
+	// last screen size at which video pipelines were created,
+	// screen size is reverted to it when they fail to recreate
+	goodScreenSize   types.ScreenSize
+	goodScreenSizeMu sync.Mutex
 
 	// sinks
 	broadcast  *BroacastManagerCtx
 	screencast *ScreencastManagerCtx
-	audio      *StreamSinkManagerCtx
+	hls        *HlsManagerCtx
+	audio      *AudioSelectorManagerCtx
 	video      *StreamSelectorManagerCtx
 
 	// sources
@@ -29,81 +39,66 @@ type CaptureManagerCtx struct {
 	microphone *StreamSrcManagerCtx
 }
 
-func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCtx {
+func New(desktop types.DesktopManager, config *config.Capture) (*CaptureManagerCtx, error) {
 	logger := log.With().Str("module", "capture").Logger()
 
-	videos := map[string]types.StreamSinkManager{}
-	for video_id, cnf := range config.VideoPipelines {
-		pipelineConf := cnf
-
-		createPipeline := func() (string, error) {
-			if pipelineConf.GstPipeline != "" {
-				// replace {display} with valid display
-				return strings.Replace(pipelineConf.GstPipeline, "{display}", config.Display, 1), nil
-			}
-
-			screen := desktop.GetScreenSize()
-			pipeline, err := pipelineConf.GetPipeline(screen)
-			if err != nil {
-				return "", err
-			}
-
-			return fmt.Sprintf(
-				"ximagesrc display-name=%s show-pointer=%v use-damage=false "+
-					"%s ! appsink name=appsink", config.Display, pipelineConf.ShowPointer, pipeline,
-			), nil
-		}
-
-		// trigger function to catch evaluation errors at startup
-		pipeline, err := createPipeline()
-		if err != nil {
-			logger.Panic().Err(err).
-				Str("video_id", video_id).
-				Msg("failed to create video pipeline")
-		}
-
-		logger.Info().
-			Str("video_id", video_id).
-			Str("pipeline", pipeline).
-			Msg("syntax check for video stream pipeline passed")
+	emmiter := events.New()
+	statusFn := func(id string, status types.CapturePipelineStatus) {
+		emmiter.Emit("status_changed", id, status)
+	}
 
-		// append to videos
-		videos[video_id] = streamSinkNew(config.VideoCodec, createPipeline, video_id)
+	video, err := streamSelectorNew(config.VideoCodec, func(pipelineConf types.VideoConfig) (func() (string, error), error) {
+		return videoPipelineFn(desktop, config.Display, pipelineConf)
+	}, config.VideoPipelines, config.VideoIDs, func(id string, status types.CapturePipelineStatus) {
+		statusFn("video/"+id, status)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return &CaptureManagerCtx{
+	manager := &CaptureManagerCtx{
 		logger:  logger,
 		desktop: desktop,
 		config:  config,
+		emmiter: emmiter,
 
 		// sinks
-		broadcast: broadcastNew(func(url string) (string, error) {
+		broadcast: func() *BroacastManagerCtx {
 			if config.BroadcastPipeline != "" {
-				var pipeline = config.BroadcastPipeline
-				// replace {display} with valid display
-				pipeline = strings.Replace(pipeline, "{display}", config.Display, 1)
-				// replace {device} with valid device
-				pipeline = strings.Replace(pipeline, "{device}", config.AudioDevice, 1)
-				// replace {url} with valid URL
-				return strings.Replace(pipeline, "{url}", url, 1), nil
+				// custom pipeline encodes on its own for every output
+				return broadcastNew(nil, func(url string) (string, error) {
+					var pipeline = config.BroadcastPipeline
+					// replace {display} with valid display
+					pipeline = strings.Replace(pipeline, "{display}", config.Display, 1)
+					// replace {device} with valid device
+					pipeline = strings.Replace(pipeline, "{device}", config.AudioDevice, 1)
+					// replace {url} with valid URL
+					return strings.Replace(pipeline, "{url}", url, 1), nil
+				}, config.BroadcastUrl, config.BroadcastAutostart, config.BroadcastStallTimeout, statusFn)
 			}
 
-			return fmt.Sprintf(
-				"flvmux name=mux ! rtmpsink location='%s live=1' "+
-					"pulsesrc device=%s "+
-					"! audio/x-raw,channels=2 "+
-					"! audioconvert "+
-					"! queue "+
-					"! voaacenc bitrate=%d "+
-					"! mux. "+
-					"ximagesrc display-name=%s show-pointer=true use-damage=false "+
-					"! video/x-raw "+
-					"! videoconvert "+
-					"! queue "+
-					"! x264enc threads=4 bitrate=%d key-int-max=15 byte-stream=true tune=zerolatency speed-preset=%s "+
-					"! mux.", url, config.AudioDevice, config.BroadcastAudioBitrate*1000, config.Display, config.BroadcastVideoBitrate, config.BroadcastPreset,
-			), nil
-		}, config.BroadcastUrl, config.BroadcastAutostart),
+			return broadcastNew(func() (string, error) {
+				return fmt.Sprintf(
+					"mpegtsmux name=mux alignment=7 ! appsink name=appsink "+
+						"pulsesrc device=%s "+
+						"! audio/x-raw,channels=2 "+
+						"! audioconvert "+
+						"! queue "+
+						"! voaacenc bitrate=%d "+
+						"! aacparse "+
+						"! mux. "+
+						"ximagesrc display-name=%s show-pointer=true use-damage=false "+
+						"! video/x-raw "+
+						"! videoconvert "+
+						"! queue "+
+						"! x264enc threads=4 bitrate=%d key-int-max=15 byte-stream=true tune=zerolatency speed-preset=%s "+
+						"! h264parse "+
+						"! mux.", config.AudioDevice, config.BroadcastAudioBitrate*1000, config.Display, config.BroadcastVideoBitrate, config.BroadcastPreset,
+				), nil
+			}, func(url string) (string, error) {
+				return broadcastOutputPipeline(url, config.BroadcastRecordings)
+			}, config.BroadcastUrl, config.BroadcastAutostart, config.BroadcastStallTimeout, statusFn)
+		}(),
 		screencast: screencastNew(config.ScreencastEnabled, func() string {
 			if config.ScreencastPipeline != "" {
 				// replace {display} with valid display
@@ -118,24 +113,35 @@ func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCt
 					"! jpegenc quality=%s "+
 					"! appsink name=appsink", config.Display, config.ScreencastRate, config.ScreencastQuality,
 			)
-		}()),
+		}(), statusFn),
+
+		audio: audioSelectorNew(config.AudioCodec, func(pipelineConfs []types.AudioConfig, volume int) func() (string, error) {
+			return func() (string, error) {
+				// custom pipelines are never mixed
+				if len(pipelineConfs) == 1 && pipelineConfs[0].GstPipeline != "" {
+					// replace {device} with valid device
+					return strings.Replace(pipelineConfs[0].GstPipeline, "{device}", pipelineConfs[0].Device, 1), nil
+				}
 
-		audio: streamSinkNew(config.AudioCodec, func() (string, error) {
-			if config.AudioPipeline != "" {
-				// replace {device} with valid device
-				return strings.Replace(config.AudioPipeline, "{device}", config.AudioDevice, 1), nil
+				return audioPipeline(pipelineConfs, volume, config.AudioCodec.Pipeline), nil
+			}
+		}, func() map[string]types.AudioConfig {
+			configs := map[string]types.AudioConfig{}
+			for id, pipelineConf := range config.AudioPipelines {
+				if pipelineConf.Device == "" {
+					pipelineConf.Device = config.AudioDevice
+				}
+				configs[id] = pipelineConf
 			}
 
-			return fmt.Sprintf(
-				"pulsesrc device=%s "+
-					"! audio/x-raw,channels=2 "+
-					"! audioconvert "+
-					"! queue "+
-					"! %s "+
-					"! appsink name=appsink", config.AudioDevice, config.AudioCodec.Pipeline,
-			), nil
-		}, "audio"),
-		video: streamSelectorNew(config.VideoCodec, videos, config.VideoIDs),
+			// main audio is always captured from the configured device
+			configs[audioDefaultID] = types.AudioConfig{
+				Device:      config.AudioDevice,
+				GstPipeline: config.AudioPipeline,
+			}
+			return configs
+		}(), statusFn),
+		video: video,
 
 		// sources
 		webcam: streamSrcNew(config.WebcamEnabled, map[string]string{
@@ -186,54 +192,206 @@ func New(desktop types.DesktopManager, config *config.Capture) *CaptureManagerCt
 				fmt.Sprintf("! pulsesink device=%s", config.MicrophoneDevice),
 		}, "microphone"),
 	}
+
+	// hls segments are created from samples of video and audio sinks
+	manager.hls = hlsNew(config.HlsEnabled, manager.video, config.HlsVideoID, manager.audio.defaultStream(), manager.audio.defaultChannels(), config.HlsSegmentDuration, config.HlsPlaylistLength, statusFn)
+
+	return manager, nil
 }
 
-func (manager *CaptureManagerCtx) Start() {
-	if manager.broadcast.Started() {
-		if err := manager.broadcast.createPipeline(); err != nil {
-			manager.logger.Panic().Err(err).Msg("unable to create broadcast pipeline")
+// videoPipelineFn returns function creating video pipeline from its config, the
+// config is checked by evaluating its expressions and parsing resulting pipeline
+func videoPipelineFn(desktop types.DesktopManager, display string, pipelineConf types.VideoConfig) (func() (string, error), error) {
+	createPipelineFrom := func(conf types.VideoConfig) (string, error) {
+		if conf.GstPipeline != "" {
+			// replace {display} with valid display
+			return strings.Replace(conf.GstPipeline, "{display}", display, 1), nil
 		}
+
+		screen := desktop.GetScreenSize()
+
+		// only part of the screen is captured, positions are inclusive
+		area := ""
+		if conf.Output != "" {
+			output, ok := desktop.ScreenOutput(conf.Output)
+			if !ok {
+				return "", fmt.Errorf("%w: %s", types.ErrScreenOutputNotFound, conf.Output)
+			}
+
+			area = fmt.Sprintf("startx=%d starty=%d endx=%d endy=%d ",
+				output.X, output.Y, output.X+output.Width-1, output.Y+output.Height-1)
+			screen.Width, screen.Height = output.Width, output.Height
+		} else if conf.Region != nil && conf.Region.Window != "" {
+			// window is captured directly, so that it is followed when moved,
+			// when resized the pipeline fails and is created again, input is
+			// mapped to the same whole window geometry
+			window, err := desktop.FindWindow(conf.Region.Window)
+			if err != nil {
+				return "", fmt.Errorf("%w: %s", err, conf.Region.Window)
+			}
+
+			area = fmt.Sprintf("xid=%d ", window.ID)
+			screen.Width, screen.Height = window.Width, window.Height
+		} else if conf.Region != nil {
+			rect, err := desktop.ScreenRegionRect(*conf.Region)
+			if err != nil {
+				return "", err
+			}
+
+			area = fmt.Sprintf("startx=%d starty=%d endx=%d endy=%d ",
+				rect.Min.X, rect.Min.Y, rect.Max.X-1, rect.Max.Y-1)
+			screen.Width, screen.Height = rect.Dx(), rect.Dy()
+		}
+
+		pipeline, err := conf.GetPipeline(screen)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf(
+			"ximagesrc display-name=%s show-pointer=%v use-damage=false %s"+
+				"%s ! appsink name=appsink", display, conf.ShowPointer, area, pipeline,
+		), nil
+	}
+
+	if pipelineConf.Output != "" && pipelineConf.Region != nil {
+		return nil, errors.New("output and region can not be set together")
+	}
+
+	createPipeline := func() (string, error) {
+		return createPipelineFrom(pipelineConf)
+	}
+
+	// trigger function to catch evaluation errors early
+	pipeline, err := createPipeline()
+
+	// followed window does not need to be opened yet, pipeline is checked without it
+	if errors.Is(err, types.ErrWindowNotFound) {
+		conf := pipelineConf
+		conf.Region = nil
+		pipeline, err = createPipelineFrom(conf)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := gst.CheckPipeline(pipeline); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("module", "capture").
+		Str("pipeline", pipeline).
+		Msg("syntax check for video stream pipeline passed")
+
+	return createPipeline, nil
+}
+
+func (manager *CaptureManagerCtx) Start() {
+	manager.goodScreenSize = manager.desktop.GetScreenSize()
+
+	manager.broadcast.recreatePipelines()
+
+	if manager.config.BroadcastRtspEnabled {
+		manager.startRtspServer()
 	}
 
 	manager.desktop.OnBeforeScreenSizeChange(func() {
 		manager.video.destroyPipelines()
 
-		if manager.broadcast.Started() {
-			manager.broadcast.destroyPipeline()
-		}
+		manager.broadcast.destroyPipelines()
+
+		// clients need to reconnect, shared pipeline is created again
+		manager.broadcast.disconnectRtspClients()
 
 		if manager.screencast.Started() {
 			manager.screencast.destroyPipeline()
 		}
+
+		if manager.hls.Started() {
+			manager.hls.destroyPipeline()
+		}
 	})
 
+	// pipelines that fail to recreate are restarted by their supervisors
 	manager.desktop.OnAfterScreenSizeChange(func() {
-		err := manager.video.recreatePipelines()
-		if err != nil {
-			manager.logger.Panic().Err(err).Msg("unable to recreate video pipelines")
+		if err := manager.video.recreatePipelines(); err != nil {
+			manager.revertScreenSize(err)
+		} else {
+			manager.goodScreenSizeMu.Lock()
+			manager.goodScreenSize = manager.desktop.GetScreenSize()
+			manager.goodScreenSizeMu.Unlock()
 		}
 
-		if manager.broadcast.Started() {
-			err := manager.broadcast.createPipeline()
+		manager.broadcast.recreatePipelines()
+
+		if manager.screencast.Started() {
+			err := manager.screencast.createPipeline()
 			if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
-				manager.logger.Panic().Err(err).Msg("unable to recreate broadcast pipeline")
+				manager.screencast.supervisor.createFailed(err)
 			}
 		}
 
-		if manager.screencast.Started() {
-			err := manager.screencast.createPipeline()
+		if manager.hls.Started() {
+			err := manager.hls.createPipeline()
 			if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
-				manager.logger.Panic().Err(err).Msg("unable to recreate screencast pipeline")
+				manager.hls.supervisor.createFailed(err)
 			}
 		}
 	})
 }
 
+// video pipelines could not be created at the current resolution, so that the screen
+// is changed back to the last resolution they were working at
+func (manager *CaptureManagerCtx) revertScreenSize(err error) {
+	manager.goodScreenSizeMu.Lock()
+	screenSize := manager.goodScreenSize
+	manager.goodScreenSizeMu.Unlock()
+
+	if manager.desktop.GetScreenSize() == screenSize {
+		return
+	}
+
+	manager.logger.Warn().Err(err).
+		Str("screen_size", screenSize.String()).
+		Msg("video pipelines failed at new screen size, falling back to previous one")
+
+	// screen size is being changed right now, so it must be changed back afterwards
+	go func() {
+		size, err := manager.desktop.SetScreenSize(screenSize)
+		if err != nil {
+			manager.logger.Err(err).Msg("unable to fall back to previous screen size")
+			return
+		}
+
+		manager.emmiter.Emit("screen_size_reverted", size)
+	}()
+}
+
+// built-in RTSP server reuses broadcast encoder, that runs only while some clients are connected
+func (manager *CaptureManagerCtx) startRtspServer() {
+	config := manager.config
+
+	err := manager.broadcast.startRtspServer(config.BroadcastRtspPort, config.BroadcastRtspPath, config.BroadcastRtspUsername, config.BroadcastRtspPassword)
+	if err != nil {
+		manager.logger.Err(err).Msg("unable to start rtsp server")
+		return
+	}
+
+	manager.logger.Info().
+		Int("port", config.BroadcastRtspPort).
+		Str("path", config.BroadcastRtspPath).
+		Bool("auth", config.BroadcastRtspUsername != "").
+		Msg("rtsp server started")
+}
+
 func (manager *CaptureManagerCtx) Shutdown() error {
 	manager.logger.Info().Msgf("shutdown")
 
 	manager.broadcast.shutdown()
 	manager.screencast.shutdown()
+	manager.hls.shutdown()
 
 	manager.audio.shutdown()
 	manager.video.shutdown()
@@ -244,6 +402,60 @@ func (manager *CaptureManagerCtx) Shutdown() error {
 	return nil
 }
 
+func (manager *CaptureManagerCtx) Status() map[string]types.CapturePipelineStatus {
+	status := map[string]types.CapturePipelineStatus{
+		"screencast": manager.screencast.supervisor.Status(),
+		"hls":        manager.hls.supervisor.Status(),
+	}
+
+	for id, audioStatus := range manager.audio.status() {
+		status[id] = audioStatus
+	}
+
+	for id, broadcastStatus := range manager.broadcast.status() {
+		status[id] = broadcastStatus
+	}
+
+	for id, videoStatus := range manager.video.status() {
+		status["video/"+id] = videoStatus
+	}
+
+	return status
+}
+
+func (manager *CaptureManagerCtx) OnStatusChanged(listener func(id string, status types.CapturePipelineStatus)) {
+	manager.emmiter.On("status_changed", func(payload ...any) {
+		listener(payload[0].(string), payload[1].(types.CapturePipelineStatus))
+	})
+}
+
+func (manager *CaptureManagerCtx) OnScreenSizeReverted(listener func(size types.ScreenSize)) {
+	manager.emmiter.On("screen_size_reverted", func(payload ...any) {
+		listener(payload[0].(types.ScreenSize))
+	})
+}
+
+func (manager *CaptureManagerCtx) Diagnostics() map[string]types.CapturePipelineDiagnostics {
+	diagnostics := map[string]types.CapturePipelineDiagnostics{
+		"screencast": manager.screencast.Diagnostics(),
+		"hls":        manager.hls.Diagnostics(),
+	}
+
+	for id, audioDiagnostics := range manager.audio.diagnostics() {
+		diagnostics[id] = audioDiagnostics
+	}
+
+	for id, broadcastDiagnostics := range manager.broadcast.Diagnostics() {
+		diagnostics[id] = broadcastDiagnostics
+	}
+
+	for id, videoDiagnostics := range manager.video.diagnostics() {
+		diagnostics["video/"+id] = videoDiagnostics
+	}
+
+	return diagnostics
+}
+
 func (manager *CaptureManagerCtx) Broadcast() types.BroadcastManager {
 	return manager.broadcast
 }
@@ -252,7 +464,15 @@ func (manager *CaptureManagerCtx) Screencast() types.ScreencastManager {
 	return manager.screencast
 }
 
+func (manager *CaptureManagerCtx) Hls() types.HlsManager {
+	return manager.hls
+}
+
 func (manager *CaptureManagerCtx) Audio() types.StreamSinkManager {
+	return manager.audio.defaultStream()
+}
+
+func (manager *CaptureManagerCtx) Audios() types.AudioSelectorManager {
 	return manager.audio
 }
 
@@ -31,17 +31,24 @@ type ScreencastManagerCtx struct {
 	imageMu    sync.Mutex
 	tickerStop chan struct{}
 
+	// streaming clients receiving every new image
+	subscribers   map[chan []byte]struct{}
+	subscribersMu sync.Mutex
+
 	enabled bool
 	started bool
 	expired int32
 
+	supervisor  *pipelineSupervisor
+	diagnostics *pipelineDiagnostics
+
 	// metrics
 	imagesCounter    prometheus.Counter
 	pipelinesCounter prometheus.Counter
 	pipelinesActive  prometheus.Gauge
 }
 
-func screencastNew(enabled bool, pipelineStr string) *ScreencastManagerCtx {
+func screencastNew(enabled bool, pipelineStr string, statusFn func(id string, status types.CapturePipelineStatus)) *ScreencastManagerCtx {
 	logger := log.With().
 		Str("module", "capture").
 		Str("submodule", "screencast").
@@ -51,6 +58,7 @@ func screencastNew(enabled bool, pipelineStr string) *ScreencastManagerCtx {
 		logger:      logger,
 		pipelineStr: pipelineStr,
 		tickerStop:  make(chan struct{}),
+		subscribers: map[chan []byte]struct{}{},
 		enabled:     enabled,
 		started:     false,
 
@@ -87,6 +95,14 @@ func screencastNew(enabled bool, pipelineStr string) *ScreencastManagerCtx {
 		}),
 	}
 
+	manager.supervisor = newPipelineSupervisor(logger, "screencast", manager.restartPipeline, statusFn)
+	manager.diagnostics = newPipelineDiagnostics(logger, manager.supervisor, map[string]string{
+		"submodule":  "screencast",
+		"video_id":   "main",
+		"codec_name": "-",
+		"codec_type": "-",
+	})
+
 	manager.wg.Add(1)
 
 	go func() {
@@ -100,6 +116,12 @@ func screencastNew(enabled bool, pipelineStr string) *ScreencastManagerCtx {
 			case <-manager.tickerStop:
 				return
 			case <-ticker.C:
+				// pipeline is kept running while there are streaming clients
+				if manager.subscribersCount() > 0 {
+					atomic.StoreInt32(&manager.expired, 0)
+					continue
+				}
+
 				if manager.Started() && !atomic.CompareAndSwapInt32(&manager.expired, 0, 1) {
 					manager.stop()
 				}
@@ -115,6 +137,14 @@ func (manager *ScreencastManagerCtx) shutdown() {
 
 	manager.destroyPipeline()
 
+	// end all streams
+	manager.subscribersMu.Lock()
+	for ch := range manager.subscribers {
+		delete(manager.subscribers, ch)
+		close(ch)
+	}
+	manager.subscribersMu.Unlock()
+
 	close(manager.tickerStop)
 	manager.wg.Wait()
 }
@@ -151,6 +181,48 @@ func (manager *ScreencastManagerCtx) Image() ([]byte, error) {
 	return manager.image.Data, nil
 }
 
+// returned channel receives the latest image followed by every new one,
+// images are skipped for slow receivers; it is closed on unsubscribe
+func (manager *ScreencastManagerCtx) Subscribe() (<-chan []byte, func(), error) {
+	atomic.StoreInt32(&manager.expired, 0)
+
+	err := manager.start()
+	if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
+		return nil, nil, err
+	}
+
+	ch := make(chan []byte, 1)
+
+	manager.subscribersMu.Lock()
+	manager.subscribers[ch] = struct{}{}
+
+	manager.imageMu.Lock()
+	if manager.image.Data != nil {
+		ch <- manager.image.Data
+	}
+	manager.imageMu.Unlock()
+	manager.subscribersMu.Unlock()
+
+	unsubscribe := func() {
+		manager.subscribersMu.Lock()
+		defer manager.subscribersMu.Unlock()
+
+		if _, ok := manager.subscribers[ch]; ok {
+			delete(manager.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	return ch, unsubscribe, nil
+}
+
+func (manager *ScreencastManagerCtx) subscribersCount() int {
+	manager.subscribersMu.Lock()
+	defer manager.subscribersMu.Unlock()
+
+	return len(manager.subscribers)
+}
+
 func (manager *ScreencastManagerCtx) start() error {
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
@@ -174,6 +246,21 @@ func (manager *ScreencastManagerCtx) stop() {
 
 	manager.started = false
 	manager.destroyPipeline()
+	manager.supervisor.stopped()
+}
+
+// restart pipeline after failure, if screencast was not stopped meanwhile
+func (manager *ScreencastManagerCtx) restartPipeline(_ bool) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.started {
+		manager.supervisor.stopped()
+		return nil
+	}
+
+	manager.destroyPipeline()
+	return manager.createPipeline()
 }
 
 func (manager *ScreencastManagerCtx) createPipeline() error {
@@ -200,6 +287,8 @@ func (manager *ScreencastManagerCtx) createPipeline() error {
 	manager.pipelinesCounter.Inc()
 	manager.pipelinesActive.Set(1)
 
+	manager.diagnostics.created(manager.pipeline)
+
 	// get first image
 	select {
 	case image, ok := <-manager.pipeline.Sample():
@@ -230,6 +319,7 @@ func (manager *ScreencastManagerCtx) createPipeline() error {
 		}
 	}()
 
+	manager.supervisor.running(false)
 	return nil
 }
 
@@ -239,6 +329,26 @@ func (manager *ScreencastManagerCtx) setImage(image types.Sample) {
 	manager.imageMu.Unlock()
 
 	manager.imagesCounter.Inc()
+
+	manager.subscribersMu.Lock()
+	defer manager.subscribersMu.Unlock()
+
+	for ch := range manager.subscribers {
+		// replace image that was not received yet
+		select {
+		case <-ch:
+		default:
+		}
+
+		ch <- image.Data
+	}
+}
+
+func (manager *ScreencastManagerCtx) Diagnostics() types.CapturePipelineDiagnostics {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	return manager.diagnostics.Diagnostics(manager.pipeline)
 }
 
 func (manager *ScreencastManagerCtx) destroyPipeline() {
@@ -252,6 +362,7 @@ func (manager *ScreencastManagerCtx) destroyPipeline() {
 	manager.pipeline.Destroy()
 	manager.logger.Info().Msgf("destroying pipeline")
 	manager.pipeline = nil
+	manager.diagnostics.destroyed()
 
 	manager.pipelinesActive.Set(0)
 }
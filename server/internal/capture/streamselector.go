@@ -3,7 +3,9 @@ package capture
 import (
 	"errors"
 	"sort"
+	"sync"
 
+	"github.com/kataras/go-events"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
@@ -12,33 +14,83 @@ import (
 )
 
 type StreamSelectorManagerCtx struct {
-	logger    zerolog.Logger
-	codec     codec.RTPCodec
-	streams   map[string]types.StreamSinkManager
+	logger     zerolog.Logger
+	codec      codec.RTPCodec
+	emmiter    events.EventEmmiter
+	pipelineFn func(config types.VideoConfig) (func() (string, error), error)
+	statusFn   func(id string, status types.CapturePipelineStatus)
+
+	mu        sync.RWMutex
+	configs   map[string]types.VideoConfig
+	streams   map[string]*StreamSinkManagerCtx
 	streamIDs []string
 }
 
-func streamSelectorNew(codec codec.RTPCodec, streams map[string]types.StreamSinkManager, streamIDs []string) *StreamSelectorManagerCtx {
+func streamSelectorNew(codec codec.RTPCodec, pipelineFn func(config types.VideoConfig) (func() (string, error), error), configs map[string]types.VideoConfig, streamIDs []string, statusFn func(id string, status types.CapturePipelineStatus)) (*StreamSelectorManagerCtx, error) {
 	logger := log.With().
 		Str("module", "capture").
 		Str("submodule", "stream-selector").
 		Logger()
 
-	return &StreamSelectorManagerCtx{
-		logger:    logger,
-		codec:     codec,
-		streams:   streams,
-		streamIDs: streamIDs,
+	manager := &StreamSelectorManagerCtx{
+		logger:     logger,
+		codec:      codec,
+		emmiter:    events.New(),
+		pipelineFn: pipelineFn,
+		statusFn:   statusFn,
+
+		configs:   map[string]types.VideoConfig{},
+		streams:   map[string]*StreamSinkManagerCtx{},
+		streamIDs: []string{},
+	}
+
+	for id, config := range configs {
+		fn, err := pipelineFn(config)
+		if err != nil {
+			// invalid pipeline is skipped, so that it can be fixed at runtime
+			logger.Error().Err(err).
+				Str("video_id", id).
+				Msg("failed to create video pipeline")
+			continue
+		}
+
+		manager.configs[id] = config
+		manager.streams[id] = streamSinkNew(codec, fn, config.GstEncoder, id, statusFn)
+	}
+
+	// keep order of valid streams
+	for _, id := range streamIDs {
+		if _, ok := manager.streams[id]; ok {
+			manager.streamIDs = append(manager.streamIDs, id)
+		}
+	}
+
+	// at least one stream must be available, so that peers can be served
+	if len(manager.streams) == 0 || len(manager.streamIDs) == 0 {
+		for _, stream := range manager.streams {
+			stream.unregisterMetrics()
+		}
+		return nil, errors.New("no valid video pipeline available")
 	}
+
+	return manager, nil
 }
 
 func (manager *StreamSelectorManagerCtx) shutdown() {
 	manager.logger.Info().Msgf("shutdown")
 
-	manager.destroyPipelines()
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, stream := range manager.streams {
+		stream.shutdown()
+	}
 }
 
 func (manager *StreamSelectorManagerCtx) destroyPipelines() {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
 	for _, stream := range manager.streams {
 		if stream.Started() {
 			stream.DestroyPipeline()
@@ -46,27 +98,228 @@ func (manager *StreamSelectorManagerCtx) destroyPipelines() {
 	}
 }
 
+// failed pipelines are restarted by their supervisor, first error is returned
 func (manager *StreamSelectorManagerCtx) recreatePipelines() error {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	var firstErr error
 	for _, stream := range manager.streams {
 		if stream.Started() {
 			err := stream.CreatePipeline()
 			if err != nil && !errors.Is(err, types.ErrCapturePipelineAlreadyExists) {
-				return err
+				stream.supervisor.createFailed(err)
+				if firstErr == nil {
+					firstErr = err
+				}
 			}
 		}
 	}
-	return nil
+
+	return firstErr
+}
+
+func (manager *StreamSelectorManagerCtx) diagnostics() map[string]types.CapturePipelineDiagnostics {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	diagnostics := make(map[string]types.CapturePipelineDiagnostics, len(manager.streams))
+	for id, stream := range manager.streams {
+		diagnostics[id] = stream.Diagnostics()
+	}
+	return diagnostics
+}
+
+func (manager *StreamSelectorManagerCtx) status() map[string]types.CapturePipelineStatus {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	status := make(map[string]types.CapturePipelineStatus, len(manager.streams))
+	for id, stream := range manager.streams {
+		status[id] = stream.supervisor.Status()
+	}
+	return status
 }
 
 func (manager *StreamSelectorManagerCtx) IDs() []string {
-	return manager.streamIDs
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	streamIDs := make([]string, len(manager.streamIDs))
+	copy(streamIDs, manager.streamIDs)
+	return streamIDs
 }
 
 func (manager *StreamSelectorManagerCtx) Codec() codec.RTPCodec {
 	return manager.codec
 }
 
+func (manager *StreamSelectorManagerCtx) ScreenOutput(id string) string {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	return manager.configs[id].Output
+}
+
+func (manager *StreamSelectorManagerCtx) ScreenRegion(id string) types.ScreenRegion {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	if region := manager.configs[id].Region; region != nil {
+		return *region
+	}
+
+	return types.ScreenRegion{}
+}
+
+//
+// runtime pipelines management
+//
+
+func (manager *StreamSelectorManagerCtx) Pipelines() map[string]types.VideoConfig {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	configs := make(map[string]types.VideoConfig, len(manager.configs))
+	for id, config := range manager.configs {
+		configs[id] = config
+	}
+	return configs
+}
+
+// new pipeline is ordered by its bitrate among other pipelines, or appended to the end
+func (manager *StreamSelectorManagerCtx) AddPipeline(id string, config types.VideoConfig) error {
+	if id == "" {
+		return errors.New("pipeline id cannot be empty")
+	}
+
+	fn, err := manager.pipelineFn(config)
+	if err != nil {
+		return err
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if _, ok := manager.streams[id]; ok {
+		return types.ErrCapturePipelineAlreadyExists
+	}
+
+	manager.configs[id] = config
+	manager.streams[id] = streamSinkNew(manager.codec, fn, config.GstEncoder, id, manager.statusFn)
+	manager.insertStreamID(id, config.Bitrate)
+
+	manager.logger.Info().Str("video_id", id).Msg("video pipeline added")
+	return nil
+}
+
+// listeners stay on the same stream, running pipeline is recreated
+func (manager *StreamSelectorManagerCtx) UpdatePipeline(id string, config types.VideoConfig) error {
+	fn, err := manager.pipelineFn(config)
+	if err != nil {
+		return err
+	}
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	stream, ok := manager.streams[id]
+	if !ok {
+		return types.ErrCapturePipelineNotFound
+	}
+
+	if err := stream.setPipelineFn(fn, config.GstEncoder); err != nil {
+		return err
+	}
+
+	// changed bitrate moves stream among other streams
+	if config.Bitrate > 0 && config.Bitrate != manager.configs[id].Bitrate {
+		manager.removeStreamID(id)
+		manager.insertStreamID(id, config.Bitrate)
+	}
+
+	manager.configs[id] = config
+
+	manager.logger.Info().Str("video_id", id).Msg("video pipeline updated")
+	return nil
+}
+
+// listeners of removed pipeline are moved to the stream with nearest bitrate
+func (manager *StreamSelectorManagerCtx) RemovePipeline(id string) error {
+	manager.mu.Lock()
+
+	stream, ok := manager.streams[id]
+	if !ok {
+		manager.mu.Unlock()
+		return types.ErrCapturePipelineNotFound
+	}
+
+	if len(manager.streams) == 1 {
+		manager.mu.Unlock()
+		return types.ErrCapturePipelineLast
+	}
+
+	delete(manager.configs, id)
+	delete(manager.streams, id)
+	manager.removeStreamID(id)
+
+	target := manager.nearestBitrate(stream.Bitrate())
+	manager.mu.Unlock()
+
+	if err := stream.removeTo(target); err != nil {
+		manager.logger.Err(err).
+			Str("video_id", id).
+			Str("target_id", target.ID()).
+			Msg("failed to move listeners")
+	}
+
+	// let listeners update reference to their stream
+	manager.emmiter.Emit("stream_moved", stream, target)
+
+	stream.shutdown()
+	stream.unregisterMetrics()
+
+	manager.logger.Info().
+		Str("video_id", id).
+		Str("target_id", target.ID()).
+		Msg("video pipeline removed")
+	return nil
+}
+
+// stream is ordered by its bitrate among other streams, or appended to the end
+func (manager *StreamSelectorManagerCtx) insertStreamID(id string, bitrate int) {
+	index := len(manager.streamIDs)
+	if bitrate > 0 {
+		for i, streamID := range manager.streamIDs {
+			if manager.configs[streamID].Bitrate > bitrate {
+				index = i
+				break
+			}
+		}
+	}
+
+	manager.streamIDs = append(manager.streamIDs[:index], append([]string{id}, manager.streamIDs[index:]...)...)
+}
+
+func (manager *StreamSelectorManagerCtx) removeStreamID(id string) {
+	for i, streamID := range manager.streamIDs {
+		if streamID == id {
+			manager.streamIDs = append(manager.streamIDs[:i], manager.streamIDs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (manager *StreamSelectorManagerCtx) OnStreamMoved(listener func(from, to types.StreamSinkManager)) {
+	manager.emmiter.On("stream_moved", func(payload ...any) {
+		listener(payload[0].(types.StreamSinkManager), payload[1].(types.StreamSinkManager))
+	})
+}
+
 func (manager *StreamSelectorManagerCtx) GetStream(selector types.StreamSelector) (types.StreamSinkManager, bool) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
 	// select stream by ID
 	if selector.ID != "" {
 		// select lower stream
@@ -104,14 +357,21 @@ func (manager *StreamSelectorManagerCtx) GetStream(selector types.StreamSelector
 
 		// select exact stream
 		stream, ok := manager.streams[selector.ID]
-		return stream, ok
+		if !ok {
+			return nil, false
+		}
+		return stream, true
 	}
 
 	// select stream by bitrate
 	if selector.Bitrate != 0 {
 		// select stream by nearest bitrate
 		if selector.Type == types.StreamSelectorTypeNearest {
-			return manager.nearestBitrate(selector.Bitrate), true
+			stream := manager.nearestBitrate(selector.Bitrate)
+			if stream == nil {
+				return nil, false
+			}
+			return stream, true
 		}
 
 		// select lower stream
@@ -158,7 +418,7 @@ func (manager *StreamSelectorManagerCtx) GetStream(selector types.StreamSelector
 }
 
 // TODO: This is a very naive implementation, we should use a binary search instead.
-func (manager *StreamSelectorManagerCtx) nearestBitrate(bitrate uint64) types.StreamSinkManager {
+func (manager *StreamSelectorManagerCtx) nearestBitrate(bitrate uint64) *StreamSinkManagerCtx {
 	type streamDiff struct {
 		id          string
 		bitrateDiff int
@@ -194,7 +454,14 @@ func (manager *StreamSelectorManagerCtx) nearestBitrate(bitrate uint64) types.St
 	// no streams available
 	if len(diffs) == 0 {
 		// return first (lowest) stream
-		return manager.streams[manager.streamIDs[0]]
+		if len(manager.streamIDs) > 0 {
+			return manager.streams[manager.streamIDs[0]]
+		}
+		// or any stream, that is not ordered
+		for _, stream := range manager.streams {
+			return stream
+		}
+		return nil
 	}
 
 	sort.Slice(diffs, func(i, j int) bool {
@@ -0,0 +1,173 @@
+package capture
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/codec"
+)
+
+func testPipelineFn(config types.VideoConfig) (func() (string, error), error) {
+	return func() (string, error) {
+		return "videotestsrc ! appsink name=appsink", nil
+	}, nil
+}
+
+func TestStreamSelectorNew(t *testing.T) {
+	// pipelines with zero bitrate are invalid
+	pipelineFn := func(config types.VideoConfig) (func() (string, error), error) {
+		if config.Bitrate == 0 {
+			return nil, errors.New("invalid pipeline")
+		}
+		return testPipelineFn(config)
+	}
+
+	tests := []struct {
+		name      string
+		configs   map[string]types.VideoConfig
+		streamIDs []string
+		want      []string
+		wantErr   bool
+	}{
+		{
+			name: "invalid pipeline is skipped",
+			configs: map[string]types.VideoConfig{
+				"valid":   {Bitrate: 1},
+				"invalid": {},
+			},
+			streamIDs: []string{"valid", "invalid"},
+			want:      []string{"valid"},
+		},
+		{
+			name: "no valid pipeline",
+			configs: map[string]types.VideoConfig{
+				"invalid": {},
+			},
+			streamIDs: []string{"invalid"},
+			wantErr:   true,
+		},
+		{
+			name: "no valid pipeline ordered",
+			configs: map[string]types.VideoConfig{
+				"valid": {Bitrate: 1},
+			},
+			streamIDs: []string{"missing"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := streamSelectorNew(codec.VP8(), pipelineFn, tt.configs, tt.streamIDs, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("streamSelectorNew() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// metrics are unregistered, so that ids can be reused
+			defer func() {
+				for _, stream := range manager.streams {
+					stream.unregisterMetrics()
+				}
+			}()
+
+			if got := manager.IDs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamSelectorOrder(t *testing.T) {
+	type step struct {
+		// add pipeline if it does not exist, otherwise update it
+		id      string
+		bitrate int
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+		want  []string
+	}{
+		{
+			name: "added by bitrate",
+			steps: []step{
+				{"add-high", 3000},
+				{"add-low", 1000},
+				{"add-mid", 2000},
+			},
+			want: []string{"init", "add-low", "add-mid", "add-high"},
+		},
+		{
+			name: "unknown bitrate is appended",
+			steps: []step{
+				{"append-low", 1000},
+				{"append-unknown", 0},
+			},
+			want: []string{"init", "append-low", "append-unknown"},
+		},
+		{
+			name: "updated bitrate is reordered",
+			steps: []step{
+				{"update-a", 1000},
+				{"update-b", 2000},
+				{"update-a", 3000},
+			},
+			want: []string{"init", "update-b", "update-a"},
+		},
+		{
+			name: "updated unknown bitrate keeps position",
+			steps: []step{
+				{"keep-a", 1000},
+				{"keep-b", 2000},
+				{"keep-a", 0},
+			},
+			want: []string{"init", "keep-a", "keep-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// initial stream has the lowest bitrate
+			initID := "init"
+			configs := map[string]types.VideoConfig{
+				initID: {Bitrate: 1},
+			}
+
+			manager, err := streamSelectorNew(codec.VP8(), testPipelineFn, configs, []string{initID}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// metrics are unregistered, so that ids can be reused
+			defer func() {
+				for _, stream := range manager.streams {
+					stream.unregisterMetrics()
+				}
+			}()
+
+			for j, s := range tt.steps {
+				config := types.VideoConfig{Bitrate: s.bitrate}
+
+				var err error
+				if _, ok := manager.Pipelines()[s.id]; ok {
+					err = manager.UpdatePipeline(s.id, config)
+				} else {
+					err = manager.AddPipeline(s.id, config)
+				}
+
+				if err != nil {
+					t.Fatalf("step %d: unexpected error %v", j, err)
+				}
+			}
+
+			if got := manager.IDs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("IDs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
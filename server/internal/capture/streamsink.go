@@ -2,6 +2,7 @@ package capture
 
 import (
 	"errors"
+	"fmt"
 	"reflect"
 	"sync"
 	"time"
@@ -37,9 +38,28 @@ type StreamSinkManagerCtx struct {
 	pipelineMu sync.Mutex
 	pipelineFn func() (string, error)
 
+	// encoder element name and its params changed at runtime
+	encoder       string
+	encoderParams types.EncoderParams
+	// bitrate set by estimator of a single listener, not kept when pipeline or listeners change,
+	// together with bitrate that was used before, so that it can be restored
+	estimatedBitrate *int
+	estimatedBase    int
+
+	// restarts failed pipeline, falls back to the last pipeline that emitted samples
+	supervisor  *pipelineSupervisor
+	diagnostics *pipelineDiagnostics
+	lastGood    string
+	lastGoodMu  sync.Mutex
+
 	listeners   map[uintptr]types.SampleListener
 	listenersKf map[uintptr]types.SampleListener // keyframe lobby
 	listenersMu sync.Mutex
+	// stream was removed, listeners cannot be added anymore
+	removed bool
+
+	// called when pipeline was stopped after its last listener left
+	stoppedFn func()
 
 	// metrics
 	currentListeners prometheus.Gauge
@@ -48,7 +68,7 @@ type StreamSinkManagerCtx struct {
 	pipelinesActive  prometheus.Gauge
 }
 
-func streamSinkNew(codec codec.RTPCodec, pipelineFn func() (string, error), id string) *StreamSinkManagerCtx {
+func streamSinkNew(codec codec.RTPCodec, pipelineFn func() (string, error), encoder string, id string, statusFn func(id string, status types.CapturePipelineStatus)) *StreamSinkManagerCtx {
 	logger := log.With().
 		Str("module", "capture").
 		Str("submodule", "stream-sink").
@@ -66,6 +86,7 @@ func streamSinkNew(codec codec.RTPCodec, pipelineFn func() (string, error), id s
 		logger:     logger,
 		codec:      codec,
 		pipelineFn: pipelineFn,
+		encoder:    encoder,
 
 		listeners:   map[uintptr]types.SampleListener{},
 		listenersKf: map[uintptr]types.SampleListener{},
@@ -119,6 +140,14 @@ func streamSinkNew(codec codec.RTPCodec, pipelineFn func() (string, error), id s
 		}),
 	}
 
+	manager.supervisor = newPipelineSupervisor(logger, id, manager.restartPipeline, statusFn)
+	manager.diagnostics = newPipelineDiagnostics(logger, manager.supervisor, map[string]string{
+		"submodule":  "streamsink",
+		"video_id":   id,
+		"codec_name": codec.Name,
+		"codec_type": codec.Type.String(),
+	})
+
 	return manager
 }
 
@@ -138,6 +167,54 @@ func (manager *StreamSinkManagerCtx) shutdown() {
 	manager.wg.Wait()
 }
 
+// restart pipeline after failure, if it still has listeners
+func (manager *StreamSinkManagerCtx) restartPipeline(fallback bool) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	if !manager.Started() {
+		manager.supervisor.stopped()
+		return nil
+	}
+
+	manager.destroyPipeline()
+	return manager.createPipeline(fallback)
+}
+
+// metrics are unregistered, so that stream with the same id can be created again
+func (manager *StreamSinkManagerCtx) unregisterMetrics() {
+	prometheus.Unregister(manager.currentListeners)
+	prometheus.Unregister(manager.totalBytes)
+	prometheus.Unregister(manager.pipelinesCounter)
+	prometheus.Unregister(manager.pipelinesActive)
+	manager.diagnostics.unregisterMetrics()
+}
+
+// replace pipeline while keeping its listeners, running pipeline is recreated
+func (manager *StreamSinkManagerCtx) setPipelineFn(pipelineFn func() (string, error), encoder string) error {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	manager.pipelineMu.Lock()
+	running := manager.pipeline != nil
+	manager.pipelineFn = pipelineFn
+	manager.encoder = encoder
+	// params changed at runtime might not apply to the new encoder
+	manager.encoderParams = types.EncoderParams{}
+	manager.pipelineMu.Unlock()
+
+	manager.lastGoodMu.Lock()
+	manager.lastGood = ""
+	manager.lastGoodMu.Unlock()
+
+	if !running {
+		return nil
+	}
+
+	manager.DestroyPipeline()
+	return manager.CreatePipeline()
+}
+
 func (manager *StreamSinkManagerCtx) ID() string {
 	return manager.id
 }
@@ -167,6 +244,10 @@ func (manager *StreamSinkManagerCtx) stop() {
 	if len(manager.listeners)+len(manager.listenersKf) == 0 {
 		manager.DestroyPipeline()
 		manager.logger.Info().Msgf("last listener, stopping")
+
+		if manager.stoppedFn != nil {
+			manager.stoppedFn()
+		}
 	}
 }
 
@@ -188,6 +269,7 @@ func (manager *StreamSinkManagerCtx) addListener(listener types.SampleListener)
 
 	manager.logger.Debug().Interface("ptr", ptr).Msgf("adding listener")
 	manager.currentListeners.Set(float64(manager.ListenersCount()))
+	manager.resetEstimatedBitrate()
 
 	// if we will be waiting for a keyframe, emit one now
 	if manager.pipeline != nil && emitKeyframe {
@@ -205,6 +287,7 @@ func (manager *StreamSinkManagerCtx) removeListener(listener types.SampleListene
 
 	manager.logger.Debug().Interface("ptr", ptr).Msgf("removing listener")
 	manager.currentListeners.Set(float64(manager.ListenersCount()))
+	manager.resetEstimatedBitrate()
 }
 
 func (manager *StreamSinkManagerCtx) AddListener(listener types.SampleListener) error {
@@ -215,6 +298,10 @@ func (manager *StreamSinkManagerCtx) AddListener(listener types.SampleListener)
 		return errors.New("listener cannot be nil")
 	}
 
+	if manager.removed {
+		return types.ErrCapturePipelineNotFound
+	}
+
 	// start if stopped
 	if err := manager.start(); err != nil {
 		return err
@@ -273,6 +360,10 @@ func (manager *StreamSinkManagerCtx) MoveListenerTo(listener types.SampleListene
 	// unlock global mutex
 	moveSinkListenerMu.Unlock()
 
+	if targetStream.removed {
+		return types.ErrCapturePipelineNotFound
+	}
+
 	// start if stopped
 	if err := targetStream.start(); err != nil {
 		return err
@@ -288,6 +379,56 @@ func (manager *StreamSinkManagerCtx) MoveListenerTo(listener types.SampleListene
 	return nil
 }
 
+// all listeners are moved to target stream while both streams are locked,
+// stream is marked as removed so that no listener can be added afterwards
+func (manager *StreamSinkManagerCtx) removeTo(targetStream *StreamSinkManagerCtx) error {
+	moveSinkListenerMu.Lock()
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	targetStream.mu.Lock()
+	defer targetStream.mu.Unlock()
+
+	moveSinkListenerMu.Unlock()
+
+	manager.removed = true
+
+	listeners := manager.listenersList()
+	if len(listeners) == 0 {
+		return nil
+	}
+
+	// start if stopped
+	if err := targetStream.start(); err != nil {
+		return err
+	}
+
+	for _, listener := range listeners {
+		manager.removeListener(listener)
+		targetStream.addListener(listener)
+	}
+
+	// stop if started
+	manager.stop()
+
+	return nil
+}
+
+func (manager *StreamSinkManagerCtx) listenersList() []types.SampleListener {
+	manager.listenersMu.Lock()
+	defer manager.listenersMu.Unlock()
+
+	listeners := make([]types.SampleListener, 0, len(manager.listeners)+len(manager.listenersKf))
+	for _, listener := range manager.listeners {
+		listeners = append(listeners, listener)
+	}
+	for _, listener := range manager.listenersKf {
+		listeners = append(listeners, listener)
+	}
+	return listeners
+}
+
 func (manager *StreamSinkManagerCtx) ListenersCount() int {
 	manager.listenersMu.Lock()
 	defer manager.listenersMu.Unlock()
@@ -300,6 +441,10 @@ func (manager *StreamSinkManagerCtx) Started() bool {
 }
 
 func (manager *StreamSinkManagerCtx) CreatePipeline() error {
+	return manager.createPipeline(false)
+}
+
+func (manager *StreamSinkManagerCtx) createPipeline(fallback bool) error {
 	manager.pipelineMu.Lock()
 	defer manager.pipelineMu.Unlock()
 
@@ -307,9 +452,23 @@ func (manager *StreamSinkManagerCtx) CreatePipeline() error {
 		return types.ErrCapturePipelineAlreadyExists
 	}
 
-	pipelineStr, err := manager.pipelineFn()
-	if err != nil {
-		return err
+	manager.lastGoodMu.Lock()
+	lastGood := manager.lastGood
+	manager.lastGoodMu.Unlock()
+
+	var pipelineStr string
+	var err error
+
+	// use known-good pipeline only if it exists
+	fallback = fallback && lastGood != ""
+	if fallback {
+		pipelineStr = lastGood
+		manager.logger.Warn().Msg("using last known-good pipeline")
+	} else {
+		pipelineStr, err = manager.pipelineFn()
+		if err != nil {
+			return err
+		}
 	}
 
 	manager.logger.Info().
@@ -325,6 +484,11 @@ func (manager *StreamSinkManagerCtx) CreatePipeline() error {
 	manager.pipeline.AttachAppsink("appsink")
 	manager.pipeline.Play()
 
+	// restore params changed at runtime
+	if err := setEncoderParams(manager.pipeline, manager.encoder, manager.encoderParams); err != nil {
+		manager.logger.Warn().Err(err).Msg("failed to restore encoder params")
+	}
+
 	manager.wg.Add(1)
 	pipeline := manager.pipeline
 
@@ -332,6 +496,7 @@ func (manager *StreamSinkManagerCtx) CreatePipeline() error {
 		manager.logger.Debug().Msg("started emitting samples")
 		defer manager.wg.Done()
 
+		first := true
 		for {
 			sample, ok := <-pipeline.Sample()
 			if !ok {
@@ -339,10 +504,21 @@ func (manager *StreamSinkManagerCtx) CreatePipeline() error {
 				return
 			}
 
+			// pipeline that emitted samples is known to work
+			if first {
+				manager.lastGoodMu.Lock()
+				manager.lastGood = pipelineStr
+				manager.lastGoodMu.Unlock()
+				first = false
+			}
+
 			manager.onSample(sample)
 		}
 	}()
 
+	manager.diagnostics.created(pipeline)
+	manager.supervisor.running(fallback)
+
 	manager.pipelinesCounter.Inc()
 	manager.pipelinesActive.Set(1)
 
@@ -394,7 +570,129 @@ func (manager *StreamSinkManagerCtx) onSample(sample types.Sample) {
 	}
 }
 
+func (manager *StreamSinkManagerCtx) SetEncoderParams(params types.EncoderParams) error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	// nothing is applied unless all params can be applied
+	if err := validateEncoderParams(manager.pipeline, manager.encoder, params); err != nil {
+		return err
+	}
+
+	// configured bitrate replaces estimated one
+	if params.Bitrate != nil {
+		manager.estimatedBitrate = nil
+	}
+
+	if manager.pipeline != nil {
+		if err := setEncoderParams(manager.pipeline, manager.encoder, params); err != nil {
+			return err
+		}
+	}
+
+	// merge with previously set params
+	if params.Bitrate != nil {
+		manager.encoderParams.Bitrate = params.Bitrate
+	}
+	if params.Fps != nil {
+		manager.encoderParams.Fps = params.Fps
+	}
+	if params.KeyframeInterval != nil {
+		manager.encoderParams.KeyframeInterval = params.KeyframeInterval
+	}
+
+	manager.logger.Info().
+		Interface("params", manager.encoderParams).
+		Msg("encoder params changed")
+
+	return nil
+}
+
+// SetEstimatedBitrate changes bitrate of running encoder without changing its configured params.
+func (manager *StreamSinkManagerCtx) SetEstimatedBitrate(bitrate int) error {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.pipeline == nil {
+		return types.ErrCapturePipelineNotRunning
+	}
+
+	params := types.EncoderParams{Bitrate: &bitrate}
+	if err := validateEncoderParams(manager.pipeline, manager.encoder, params); err != nil {
+		return err
+	}
+
+	// remember bitrate to restore, when estimation is no longer applicable
+	if manager.estimatedBitrate == nil {
+		base, ok := encoderBitrate(manager.pipeline, manager.encoder)
+		if !ok {
+			return fmt.Errorf("%w: unable to read encoder bitrate", types.ErrCaptureEncoderParamNotSupported)
+		}
+		manager.estimatedBase = base
+	}
+
+	if err := setEncoderParams(manager.pipeline, manager.encoder, params); err != nil {
+		return err
+	}
+
+	manager.estimatedBitrate = &bitrate
+	return nil
+}
+
+// EstimatedBitrate returns bitrate set by estimator, if it is still applied.
+func (manager *StreamSinkManagerCtx) EstimatedBitrate() (int, bool) {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.estimatedBitrate == nil {
+		return 0, false
+	}
+
+	return *manager.estimatedBitrate, true
+}
+
+func (manager *StreamSinkManagerCtx) resetEstimatedBitrate() {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	if manager.estimatedBitrate == nil {
+		return
+	}
+
+	manager.estimatedBitrate = nil
+
+	bitrate := manager.estimatedBase
+	if manager.encoderParams.Bitrate != nil {
+		bitrate = *manager.encoderParams.Bitrate
+	}
+
+	if manager.pipeline != nil {
+		if err := setEncoderParams(manager.pipeline, manager.encoder, types.EncoderParams{Bitrate: &bitrate}); err != nil {
+			manager.logger.Warn().Err(err).Msg("failed to restore encoder bitrate")
+		}
+	}
+}
+
+func (manager *StreamSinkManagerCtx) EncoderParams() types.EncoderParams {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	return manager.encoderParams
+}
+
+func (manager *StreamSinkManagerCtx) Diagnostics() types.CapturePipelineDiagnostics {
+	manager.pipelineMu.Lock()
+	defer manager.pipelineMu.Unlock()
+
+	return manager.diagnostics.Diagnostics(manager.pipeline)
+}
+
 func (manager *StreamSinkManagerCtx) DestroyPipeline() {
+	manager.destroyPipeline()
+	manager.supervisor.stopped()
+}
+
+func (manager *StreamSinkManagerCtx) destroyPipeline() {
 	manager.pipelineMu.Lock()
 	defer manager.pipelineMu.Unlock()
 
@@ -405,6 +703,8 @@ func (manager *StreamSinkManagerCtx) DestroyPipeline() {
 	manager.pipeline.Destroy()
 	manager.logger.Info().Msgf("destroying pipeline")
 	manager.pipeline = nil
+	manager.estimatedBitrate = nil
+	manager.diagnostics.destroyed()
 
 	manager.pipelinesActive.Set(0)
 
@@ -18,6 +18,7 @@ type StreamSrcManagerCtx struct {
 	logger        zerolog.Logger
 	enabled       bool
 	codecPipeline map[string]string // codec -> pipeline
+	codecs        []codec.RTPCodec
 
 	codec       codec.RTPCodec
 	pipeline    gst.Pipeline
@@ -39,6 +40,7 @@ func streamSrcNew(enabled bool, codecPipeline map[string]string, video_id string
 	pushedData := map[string]prometheus.Summary{}
 	pipelinesCounter := map[string]prometheus.Counter{}
 	pipelinesActive := map[string]prometheus.Gauge{}
+	codecs := []codec.RTPCodec{}
 
 	for codecName, pipeline := range codecPipeline {
 		codec, ok := codec.ParseStr(codecName)
@@ -49,6 +51,8 @@ func streamSrcNew(enabled bool, codecPipeline map[string]string, video_id string
 				Msg("unknown codec name")
 		}
 
+		codecs = append(codecs, codec)
+
 		pushedData[codecName] = promauto.NewSummary(prometheus.SummaryOpts{
 			Name:      "streamsrc_data_bytes",
 			Namespace: "neko",
@@ -90,6 +94,7 @@ func streamSrcNew(enabled bool, codecPipeline map[string]string, video_id string
 		logger:        logger,
 		enabled:       enabled,
 		codecPipeline: codecPipeline,
+		codecs:        codecs,
 
 		// metrics
 		pushedData:       pushedData,
@@ -111,6 +116,14 @@ func (manager *StreamSrcManagerCtx) Codec() codec.RTPCodec {
 	return manager.codec
 }
 
+func (manager *StreamSrcManagerCtx) Codecs() []codec.RTPCodec {
+	if !manager.enabled {
+		return nil
+	}
+
+	return manager.codecs
+}
+
 func (manager *StreamSrcManagerCtx) Start(codec codec.RTPCodec) error {
 	manager.pipelineMu.Lock()
 	defer manager.pipelineMu.Unlock()
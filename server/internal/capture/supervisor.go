@@ -0,0 +1,162 @@
+package capture
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/types"
+)
+
+const (
+	// delay before first restart, doubled with every following restart
+	supervisorBackoffMin = 500 * time.Millisecond
+	supervisorBackoffMax = 30 * time.Second
+
+	// after this many failed restarts, known-good pipeline is used
+	supervisorFallbackAfter = 3
+
+	// pipeline running for this long is considered healthy again
+	supervisorHealthyAfter = 30 * time.Second
+)
+
+// pipelineSupervisor restarts failed pipeline with exponential backoff,
+// instead of bringing down the whole process.
+type pipelineSupervisor struct {
+	logger zerolog.Logger
+	mu     sync.Mutex
+	id     string
+
+	status    types.CapturePipelineStatus
+	startedAt time.Time
+	timer     *time.Timer
+
+	// recreates pipeline, fallback means that known-good pipeline should be used
+	restartFn func(fallback bool) error
+	statusFn  func(id string, status types.CapturePipelineStatus)
+}
+
+func newPipelineSupervisor(logger zerolog.Logger, id string, restartFn func(fallback bool) error, statusFn func(id string, status types.CapturePipelineStatus)) *pipelineSupervisor {
+	return &pipelineSupervisor{
+		logger: logger.With().Str("supervisor", id).Logger(),
+		id:     id,
+		status: types.CapturePipelineStatus{
+			State: types.CapturePipelineStopped,
+		},
+		restartFn: restartFn,
+		statusFn:  statusFn,
+	}
+}
+
+func (s *pipelineSupervisor) Status() types.CapturePipelineStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+func (s *pipelineSupervisor) setStatus(status types.CapturePipelineStatus) {
+	if s.status == status {
+		return
+	}
+
+	s.status = status
+	if s.statusFn != nil {
+		s.statusFn(s.id, status)
+	}
+}
+
+// pipeline was created and started playing
+func (s *pipelineSupervisor) running(fallback bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.startedAt = time.Now()
+	s.setStatus(types.CapturePipelineStatus{
+		State:    types.CapturePipelineRunning,
+		Error:    s.status.Error,
+		Retries:  s.status.Retries,
+		Fallback: fallback,
+	})
+}
+
+// pipeline was intentionally destroyed, pending restart is canceled
+func (s *pipelineSupervisor) stopped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+
+	s.setStatus(types.CapturePipelineStatus{
+		State: types.CapturePipelineStopped,
+	})
+}
+
+// pipeline reported an error while running, restart is scheduled
+// unless pipeline was stopped in the meantime
+func (s *pipelineSupervisor) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.status.State == types.CapturePipelineStopped {
+		s.logger.Debug().Err(err).Msg("pipeline failed after it was stopped, ignoring")
+		return
+	}
+
+	s.scheduleRestart(err)
+}
+
+// pipeline could not be created, restart is scheduled even if it was not running yet
+func (s *pipelineSupervisor) createFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scheduleRestart(err)
+}
+
+func (s *pipelineSupervisor) scheduleRestart(err error) {
+	// restart is already pending
+	if s.timer != nil {
+		return
+	}
+
+	retries := s.status.Retries
+	if s.status.State == types.CapturePipelineRunning && time.Since(s.startedAt) > supervisorHealthyAfter {
+		retries = 0
+	}
+
+	backoff := supervisorBackoffMin << retries
+	if backoff > supervisorBackoffMax || backoff <= 0 {
+		backoff = supervisorBackoffMax
+	}
+
+	retries++
+	fallback := retries > supervisorFallbackAfter
+
+	s.logger.Warn().Err(err).
+		Int("retries", retries).
+		Bool("fallback", fallback).
+		Dur("backoff", backoff).
+		Msg("pipeline failed, scheduling restart")
+
+	s.setStatus(types.CapturePipelineStatus{
+		State:    types.CapturePipelineRecovering,
+		Error:    err.Error(),
+		Retries:  retries,
+		Fallback: s.status.Fallback,
+	})
+
+	s.timer = time.AfterFunc(backoff, func() {
+		s.mu.Lock()
+		s.timer = nil
+		s.mu.Unlock()
+
+		if err := s.restartFn(fallback); err != nil {
+			s.createFailed(err)
+		}
+	})
+}
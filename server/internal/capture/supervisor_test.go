@@ -0,0 +1,76 @@
+package capture
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/types"
+)
+
+func TestPipelineSupervisorFailed(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepares supervisor before failure is reported
+		prepare   func(s *pipelineSupervisor)
+		create    bool
+		wantState types.CapturePipelineState
+		wantTimer bool
+	}{
+		{
+			name:      "running pipeline is restarted",
+			prepare:   func(s *pipelineSupervisor) { s.running(false) },
+			wantState: types.CapturePipelineRecovering,
+			wantTimer: true,
+		},
+		{
+			name: "stopped pipeline is not restarted",
+			prepare: func(s *pipelineSupervisor) {
+				s.running(false)
+				s.stopped()
+			},
+			wantState: types.CapturePipelineStopped,
+		},
+		{
+			name:      "pipeline that was never started is not restarted",
+			prepare:   func(s *pipelineSupervisor) {},
+			wantState: types.CapturePipelineStopped,
+		},
+		{
+			name:      "pipeline that could not be created is restarted",
+			prepare:   func(s *pipelineSupervisor) {},
+			create:    true,
+			wantState: types.CapturePipelineRecovering,
+			wantTimer: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newPipelineSupervisor(zerolog.Nop(), "test", func(bool) error { return nil }, nil)
+			defer s.stopped()
+
+			tt.prepare(s)
+
+			err := errors.New("pipeline failed")
+			if tt.create {
+				s.createFailed(err)
+			} else {
+				s.failed(err)
+			}
+
+			if got := s.Status().State; got != tt.wantState {
+				t.Errorf("Status().State = %v, want %v", got, tt.wantState)
+			}
+
+			s.mu.Lock()
+			gotTimer := s.timer != nil
+			s.mu.Unlock()
+
+			if gotTimer != tt.wantTimer {
+				t.Errorf("restart scheduled = %v, want %v", gotTimer, tt.wantTimer)
+			}
+		})
+	}
+}
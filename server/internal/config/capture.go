@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pion/webrtc/v3"
 	"github.com/rs/zerolog/log"
@@ -35,6 +36,8 @@ type Capture struct {
 	AudioDevice   string
 	AudioCodec    codec.RTPCodec
 	AudioPipeline string
+	// additional named audio streams
+	AudioPipelines map[string]types.AudioConfig
 
 	BroadcastAudioBitrate int
 	BroadcastVideoBitrate int
@@ -42,12 +45,25 @@ type Capture struct {
 	BroadcastPipeline     string
 	BroadcastUrl          string
 	BroadcastAutostart    bool
+	BroadcastStallTimeout time.Duration
+	BroadcastRecordings   string
+
+	BroadcastRtspEnabled  bool
+	BroadcastRtspPort     int
+	BroadcastRtspPath     string
+	BroadcastRtspUsername string
+	BroadcastRtspPassword string
 
 	ScreencastEnabled  bool
 	ScreencastRate     string
 	ScreencastQuality  string
 	ScreencastPipeline string
 
+	HlsEnabled         bool
+	HlsVideoID         string
+	HlsSegmentDuration int
+	HlsPlaylistLength  int
+
 	WebcamEnabled bool
 	WebcamDevice  string
 	WebcamWidth   int
@@ -74,6 +90,11 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().String("capture.audio.pipelines", "{}", "additional named audio streams config in JSON, e.g. microphone loopback from audio_input.monitor")
+	if err := viper.BindPFlag("capture.audio.pipelines", cmd.PersistentFlags().Lookup("capture.audio.pipelines")); err != nil {
+		return err
+	}
+
 	// videos
 	cmd.PersistentFlags().String("capture.video.display", "", "X display to capture")
 	if err := viper.BindPFlag("capture.video.display", cmd.PersistentFlags().Lookup("capture.video.display")); err != nil {
@@ -116,7 +137,7 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
-	cmd.PersistentFlags().String("capture.broadcast.url", "", "initial URL for broadcasting, setting this value will automatically start broadcasting")
+	cmd.PersistentFlags().String("capture.broadcast.url", "", "initial URL for broadcasting (rtmp, rtmps, srt, rtsp or file), setting this value will automatically start broadcasting")
 	if err := viper.BindPFlag("capture.broadcast.url", cmd.PersistentFlags().Lookup("capture.broadcast.url")); err != nil {
 		return err
 	}
@@ -126,6 +147,41 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().String("capture.broadcast.recordings", "", "directory, that file broadcast outputs are written to, file outputs are disabled if empty")
+	if err := viper.BindPFlag("capture.broadcast.recordings", cmd.PersistentFlags().Lookup("capture.broadcast.recordings")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.broadcast.stall_timeout", 10, "restart broadcast output, when no data is sent for this many seconds, 0 to disable")
+	if err := viper.BindPFlag("capture.broadcast.stall_timeout", cmd.PersistentFlags().Lookup("capture.broadcast.stall_timeout")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Bool("capture.broadcast.rtsp.enabled", false, "enable built-in RTSP server, that viewers can pull the broadcast from, not available with custom broadcast pipeline")
+	if err := viper.BindPFlag("capture.broadcast.rtsp.enabled", cmd.PersistentFlags().Lookup("capture.broadcast.rtsp.enabled")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.broadcast.rtsp.port", 8554, "port of built-in RTSP server")
+	if err := viper.BindPFlag("capture.broadcast.rtsp.port", cmd.PersistentFlags().Lookup("capture.broadcast.rtsp.port")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.broadcast.rtsp.path", "/neko", "path of the stream served by built-in RTSP server")
+	if err := viper.BindPFlag("capture.broadcast.rtsp.path", cmd.PersistentFlags().Lookup("capture.broadcast.rtsp.path")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.broadcast.rtsp.username", "", "username required by built-in RTSP server, authentication is disabled if empty")
+	if err := viper.BindPFlag("capture.broadcast.rtsp.username", cmd.PersistentFlags().Lookup("capture.broadcast.rtsp.username")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.broadcast.rtsp.password", "", "password required by built-in RTSP server")
+	if err := viper.BindPFlag("capture.broadcast.rtsp.password", cmd.PersistentFlags().Lookup("capture.broadcast.rtsp.password")); err != nil {
+		return err
+	}
+
 	// screencast
 	cmd.PersistentFlags().Bool("capture.screencast.enabled", false, "enable screencast")
 	if err := viper.BindPFlag("capture.screencast.enabled", cmd.PersistentFlags().Lookup("capture.screencast.enabled")); err != nil {
@@ -147,6 +203,27 @@ func (Capture) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	// hls
+	cmd.PersistentFlags().Bool("capture.hls.enabled", false, "enable HLS stream, video codec must be h264")
+	if err := viper.BindPFlag("capture.hls.enabled", cmd.PersistentFlags().Lookup("capture.hls.enabled")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("capture.hls.video_id", "", "video pipeline used for HLS stream, last one if empty")
+	if err := viper.BindPFlag("capture.hls.video_id", cmd.PersistentFlags().Lookup("capture.hls.video_id")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.hls.segment_duration", 2, "target duration of HLS segments in seconds, keyframe interval of the video pipeline should not exceed it")
+	if err := viper.BindPFlag("capture.hls.segment_duration", cmd.PersistentFlags().Lookup("capture.hls.segment_duration")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("capture.hls.playlist_length", 5, "number of segments in HLS playlist, older segments are removed")
+	if err := viper.BindPFlag("capture.hls.playlist_length", cmd.PersistentFlags().Lookup("capture.hls.playlist_length")); err != nil {
+		return err
+	}
+
 	// webcam
 	cmd.PersistentFlags().Bool("capture.webcam.enabled", false, "enable webcam stream")
 	if err := viper.BindPFlag("capture.webcam.enabled", cmd.PersistentFlags().Lookup("capture.webcam.enabled")); err != nil {
@@ -375,6 +452,25 @@ func (s *Capture) Set() {
 	s.AudioDevice = viper.GetString("capture.audio.device")
 	s.AudioPipeline = viper.GetString("capture.audio.pipeline")
 
+	if err := viper.UnmarshalKey("capture.audio.pipelines", &s.AudioPipelines, viper.DecodeHook(
+		utils.JsonStringAutoDecode(s.AudioPipelines),
+	)); err != nil {
+		log.Warn().Err(err).Msgf("unable to parse audio pipelines")
+	}
+
+	if _, ok := s.AudioPipelines["main"]; ok {
+		log.Warn().Msgf("audio pipeline main is reserved for capture.audio.device, ignoring")
+		delete(s.AudioPipelines, "main")
+	}
+
+	// + separates ids of mixed audio streams
+	for id := range s.AudioPipelines {
+		if strings.Contains(id, "+") {
+			log.Warn().Str("id", id).Msgf("audio pipeline id must not contain +, ignoring")
+			delete(s.AudioPipelines, id)
+		}
+	}
+
 	audioCodec := viper.GetString("capture.audio.codec")
 	s.AudioCodec, ok = codec.ParseStr(audioCodec)
 	if !ok || !s.AudioCodec.IsAudio() {
@@ -389,6 +485,19 @@ func (s *Capture) Set() {
 	s.BroadcastPipeline = viper.GetString("capture.broadcast.pipeline")
 	s.BroadcastUrl = viper.GetString("capture.broadcast.url")
 	s.BroadcastAutostart = viper.GetBool("capture.broadcast.autostart")
+	s.BroadcastStallTimeout = time.Duration(viper.GetInt("capture.broadcast.stall_timeout")) * time.Second
+	s.BroadcastRecordings = viper.GetString("capture.broadcast.recordings")
+
+	s.BroadcastRtspEnabled = viper.GetBool("capture.broadcast.rtsp.enabled")
+	s.BroadcastRtspPort = viper.GetInt("capture.broadcast.rtsp.port")
+	s.BroadcastRtspPath = viper.GetString("capture.broadcast.rtsp.path")
+	s.BroadcastRtspUsername = viper.GetString("capture.broadcast.rtsp.username")
+	s.BroadcastRtspPassword = viper.GetString("capture.broadcast.rtsp.password")
+
+	// rtsp mount points must be absolute
+	if !strings.HasPrefix(s.BroadcastRtspPath, "/") {
+		s.BroadcastRtspPath = "/" + s.BroadcastRtspPath
+	}
 
 	// screencast
 	s.ScreencastEnabled = viper.GetBool("capture.screencast.enabled")
@@ -396,6 +505,19 @@ func (s *Capture) Set() {
 	s.ScreencastQuality = viper.GetString("capture.screencast.quality")
 	s.ScreencastPipeline = viper.GetString("capture.screencast.pipeline")
 
+	// hls
+	s.HlsEnabled = viper.GetBool("capture.hls.enabled")
+	s.HlsVideoID = viper.GetString("capture.hls.video_id")
+	s.HlsSegmentDuration = viper.GetInt("capture.hls.segment_duration")
+	s.HlsPlaylistLength = viper.GetInt("capture.hls.playlist_length")
+
+	if s.HlsSegmentDuration < 1 {
+		s.HlsSegmentDuration = 1
+	}
+	if s.HlsPlaylistLength < 1 {
+		s.HlsPlaylistLength = 1
+	}
+
 	// webcam
 	s.WebcamEnabled = viper.GetBool("capture.webcam.enabled")
 	s.WebcamDevice = viper.GetString("capture.webcam.device")
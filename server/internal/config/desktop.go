@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 
@@ -10,19 +11,28 @@ import (
 	"github.com/spf13/viper"
 
 	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
 )
 
 type Desktop struct {
 	Display string
 
 	ScreenSize types.ScreenSize
+	// bounds of screen sizes that can be requested
+	ScreenSizeMin types.ScreenSize
+	ScreenSizeMax types.ScreenSize
 
 	UseInputDriver bool
 	InputSocket    string
 
 	Unminimize        bool
 	UploadDrop        bool
+	DownloadDrag      bool
+	DownloadDragDir   string
 	FileChooserDialog bool
+	FileChooserPortal bool
+
+	Apps map[string]types.AppConfig
 }
 
 func (Desktop) Init(cmd *cobra.Command) error {
@@ -36,6 +46,16 @@ func (Desktop) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().String("desktop.screen_min", "320x240@10", "smallest screen size and framerate that can be set")
+	if err := viper.BindPFlag("desktop.screen_min", cmd.PersistentFlags().Lookup("desktop.screen_min")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("desktop.screen_max", "3840x2160@60", "largest screen size and framerate that can be set, larger modes are rejected even if they are configured in xorg.conf")
+	if err := viper.BindPFlag("desktop.screen_max", cmd.PersistentFlags().Lookup("desktop.screen_max")); err != nil {
+		return err
+	}
+
 	cmd.PersistentFlags().Bool("desktop.input.enabled", true, "whether custom xf86 input driver should be used to handle touchscreen")
 	if err := viper.BindPFlag("desktop.input.enabled", cmd.PersistentFlags().Lookup("desktop.input.enabled")); err != nil {
 		return err
@@ -56,11 +76,31 @@ func (Desktop) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().Bool("desktop.download_drag", false, "whether files dragged out to the screen edge are offered as download")
+	if err := viper.BindPFlag("desktop.download_drag", cmd.PersistentFlags().Lookup("desktop.download_drag")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("desktop.download_drag_dir", "/home/neko/Downloads", "directory that files dragged out must be in, defaults to file transfer directory")
+	if err := viper.BindPFlag("desktop.download_drag_dir", cmd.PersistentFlags().Lookup("desktop.download_drag_dir")); err != nil {
+		return err
+	}
+
 	cmd.PersistentFlags().Bool("desktop.file_chooser_dialog", false, "whether to handle file chooser dialog externally")
 	if err := viper.BindPFlag("desktop.file_chooser_dialog", cmd.PersistentFlags().Lookup("desktop.file_chooser_dialog")); err != nil {
 		return err
 	}
 
+	cmd.PersistentFlags().Bool("desktop.file_chooser_portal", true, "whether to provide xdg desktop portal file chooser on session bus, xdotool is used as a fallback")
+	if err := viper.BindPFlag("desktop.file_chooser_portal", cmd.PersistentFlags().Lookup("desktop.file_chooser_portal")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().String("desktop.apps", "{}", "applications that can be launched in JSON, e.g. {\"firefox\":{\"command\":[\"firefox\"],\"autostart\":true,\"restart\":\"always\"}}")
+	if err := viper.BindPFlag("desktop.apps", cmd.PersistentFlags().Lookup("desktop.apps")); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -102,11 +142,101 @@ func (s *Desktop) Set() {
 		}
 	}
 
+	screenSizeMin := types.ScreenSize{Width: 320, Height: 240, Rate: 10}
+	screenSizeMax := types.ScreenSize{Width: 3840, Height: 2160, Rate: 60}
+
+	s.ScreenSizeMin = screenSizeMin
+	if size, ok := parseScreenSize(viper.GetString("desktop.screen_min")); ok {
+		s.ScreenSizeMin = size
+	} else {
+		log.Warn().Str("screen_min", viper.GetString("desktop.screen_min")).Msg("unable to parse smallest screen size, using default")
+	}
+
+	s.ScreenSizeMax = screenSizeMax
+	if size, ok := parseScreenSize(viper.GetString("desktop.screen_max")); ok {
+		s.ScreenSizeMax = size
+	} else {
+		log.Warn().Str("screen_max", viper.GetString("desktop.screen_max")).Msg("unable to parse largest screen size, using default")
+	}
+
+	// inverted range would reject every screen size
+	if s.ScreenSizeMin.Width > s.ScreenSizeMax.Width ||
+		s.ScreenSizeMin.Height > s.ScreenSizeMax.Height ||
+		s.ScreenSizeMin.Rate > s.ScreenSizeMax.Rate {
+		log.Warn().
+			Str("screen_min", s.ScreenSizeMin.String()).
+			Str("screen_max", s.ScreenSizeMax.String()).
+			Msg("smallest screen size is larger than largest screen size, using defaults")
+		s.ScreenSizeMin = screenSizeMin
+		s.ScreenSizeMax = screenSizeMax
+	}
+
 	s.UseInputDriver = viper.GetBool("desktop.input.enabled")
 	s.InputSocket = viper.GetString("desktop.input.socket")
 	s.Unminimize = viper.GetBool("desktop.unminimize")
 	s.UploadDrop = viper.GetBool("desktop.upload_drop")
+	s.DownloadDrag = viper.GetBool("desktop.download_drag")
+
+	// only files from file transfer directory are offered, unless set otherwise
+	downloadDragDir := viper.GetString("desktop.download_drag_dir")
+	if !viper.IsSet("desktop.download_drag_dir") {
+		if viper.IsSet("file_transfer_path") {
+			downloadDragDir = viper.GetString("file_transfer_path")
+		} else if viper.IsSet("filetransfer.dir") {
+			downloadDragDir = viper.GetString("filetransfer.dir")
+		}
+	}
+	s.DownloadDragDir = filepath.Clean(downloadDragDir)
+
 	s.FileChooserDialog = viper.GetBool("desktop.file_chooser_dialog")
+	s.FileChooserPortal = viper.GetBool("desktop.file_chooser_portal")
+
+	if err := viper.UnmarshalKey("desktop.apps", &s.Apps, viper.DecodeHook(
+		utils.JsonStringAutoDecode(s.Apps),
+	)); err != nil {
+		log.Warn().Err(err).Msgf("unable to parse desktop apps")
+	}
+
+	for name, app := range s.Apps {
+		if len(app.Command) == 0 {
+			log.Warn().Str("app", name).Msgf("app has no command, ignoring")
+			delete(s.Apps, name)
+			continue
+		}
+
+		switch app.Restart {
+		case "":
+			app.Restart = types.AppRestartOnFailure
+		case types.AppRestartNever, types.AppRestartOnFailure, types.AppRestartAlways:
+		default:
+			log.Warn().Str("app", name).Str("restart", app.Restart).Msgf("unknown restart policy, using on-failure")
+			app.Restart = types.AppRestartOnFailure
+		}
+
+		s.Apps[name] = app
+	}
+}
+
+// parses screen size in format WIDTHxHEIGHT@RATE
+func parseScreenSize(value string) (types.ScreenSize, bool) {
+	r := regexp.MustCompile(`^([0-9]{1,4})x([0-9]{1,4})@([0-9]{1,3})$`)
+	res := r.FindStringSubmatch(value)
+	if len(res) == 0 {
+		return types.ScreenSize{}, false
+	}
+
+	width, err1 := strconv.ParseInt(res[1], 10, 64)
+	height, err2 := strconv.ParseInt(res[2], 10, 64)
+	rate, err3 := strconv.ParseInt(res[3], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return types.ScreenSize{}, false
+	}
+
+	return types.ScreenSize{
+		Width:  int(width),
+		Height: int(height),
+		Rate:   int16(rate),
+	}, true
 }
 
 func (s *Desktop) SetV2() {
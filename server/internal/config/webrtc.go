@@ -37,6 +37,12 @@ type WebRTCEstimator struct {
 	UpgradeBackoff time.Duration
 	// how bigger the difference between estimated and stream bitrate must be to trigger upgrade/downgrade
 	DiffThreshold float64
+
+	// change encoder bitrate instead of switching streams, when peer is the only stream listener
+	Continuous bool
+	// bitrate bounds for continuous mode
+	ContinuousMinBitrate int
+	ContinuousMaxBitrate int
 }
 
 type WebRTC struct {
@@ -164,6 +170,21 @@ func (WebRTC) Init(cmd *cobra.Command) error {
 		return err
 	}
 
+	cmd.PersistentFlags().Bool("webrtc.estimator.continuous", false, "change encoder bitrate continuously instead of switching streams, when peer is the only stream listener")
+	if err := viper.BindPFlag("webrtc.estimator.continuous", cmd.PersistentFlags().Lookup("webrtc.estimator.continuous")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("webrtc.estimator.continuous_min_bitrate", 300_000, "minimal encoder bitrate in continuous mode")
+	if err := viper.BindPFlag("webrtc.estimator.continuous_min_bitrate", cmd.PersistentFlags().Lookup("webrtc.estimator.continuous_min_bitrate")); err != nil {
+		return err
+	}
+
+	cmd.PersistentFlags().Int("webrtc.estimator.continuous_max_bitrate", 10_000_000, "maximal encoder bitrate in continuous mode")
+	if err := viper.BindPFlag("webrtc.estimator.continuous_max_bitrate", cmd.PersistentFlags().Lookup("webrtc.estimator.continuous_max_bitrate")); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -315,6 +336,9 @@ func (s *WebRTC) Set() {
 	s.Estimator.DowngradeBackoff = viper.GetDuration("webrtc.estimator.downgrade_backoff")
 	s.Estimator.UpgradeBackoff = viper.GetDuration("webrtc.estimator.upgrade_backoff")
 	s.Estimator.DiffThreshold = viper.GetFloat64("webrtc.estimator.diff_threshold")
+	s.Estimator.Continuous = viper.GetBool("webrtc.estimator.continuous")
+	s.Estimator.ContinuousMinBitrate = viper.GetInt("webrtc.estimator.continuous_min_bitrate")
+	s.Estimator.ContinuousMaxBitrate = viper.GetInt("webrtc.estimator.continuous_max_bitrate")
 }
 
 func (s *WebRTC) SetV2() {
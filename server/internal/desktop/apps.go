@@ -0,0 +1,380 @@
+package desktop
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/types"
+)
+
+const (
+	// time given to app to exit gracefully, before it is killed
+	appStopTimeout = 10 * time.Second
+	// delay before restart, doubled when app keeps crashing
+	appRestartDelay    = 1 * time.Second
+	appRestartDelayMax = 1 * time.Minute
+	// app running at least this long is considered healthy
+	appHealthyUptime = 10 * time.Second
+	// longer lines of output are split into multiple logs
+	appLogLineMax = 64 * 1024
+	// output is read for this long after app exited, as its descendants might keep it open
+	appLogDrainTimeout = 1 * time.Second
+)
+
+type appProcess struct {
+	logger zerolog.Logger
+	name   string
+	config types.AppConfig
+	env    []string
+	logFn  func(name, stream, line string)
+
+	mu       sync.Mutex
+	cmd      *exec.Cmd
+	done     chan struct{}
+	started  time.Time
+	exitCode *int
+	restarts int
+	// set when app is stopped on request, so that it is not restarted
+	stopped      bool
+	restartDelay time.Duration
+	restartTimer *time.Timer
+}
+
+func appProcessNew(logger zerolog.Logger, name string, config types.AppConfig, display string, logFn func(name, stream, line string)) *appProcess {
+	env := append(os.Environ(), "DISPLAY="+display)
+	for key, value := range config.Env {
+		env = append(env, key+"="+value)
+	}
+
+	return &appProcess{
+		logger: logger.With().Str("app", name).Logger(),
+		name:   name,
+		config: config,
+		env:    env,
+		logFn:  logFn,
+
+		stopped:      true,
+		restartDelay: appRestartDelay,
+	}
+}
+
+func (app *appProcess) Status() types.AppStatus {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	status := types.AppStatus{
+		Name:     app.name,
+		Running:  app.cmd != nil,
+		ExitCode: app.exitCode,
+		Restarts: app.restarts,
+		Restart:  app.config.Restart,
+	}
+
+	if app.cmd != nil {
+		status.PID = app.cmd.Process.Pid
+		status.Uptime = time.Since(app.started).Seconds()
+	}
+
+	return status
+}
+
+func (app *appProcess) start() error {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	app.stopped = false
+	if app.restartTimer != nil {
+		app.restartTimer.Stop()
+		app.restartTimer = nil
+	}
+
+	return app.spawn()
+}
+
+func (app *appProcess) spawn() error {
+	if app.cmd != nil {
+		return types.ErrAppAlreadyRunning
+	}
+
+	cmd := exec.Command(app.config.Command[0], app.config.Command[1:]...)
+	cmd.Env = app.env
+	// own process group, so that whole app can be stopped
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	// pipes are not closed by waiting for the process, so that it can be
+	// waited for while descendants that inherited them are still running
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	stderr, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdout.Close()
+		stdoutWriter.Close()
+		return err
+	}
+
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+
+	err = cmd.Start()
+	// write ends are kept open only by the app
+	stdoutWriter.Close()
+	stderrWriter.Close()
+
+	if err != nil {
+		stdout.Close()
+		stderr.Close()
+		return err
+	}
+
+	app.logger.Info().Int("pid", cmd.Process.Pid).Msg("app started")
+
+	app.cmd = cmd
+	app.done = make(chan struct{})
+	app.started = time.Now()
+	app.exitCode = nil
+
+	go app.wait(cmd, app.done, stdout, stderr)
+	return nil
+}
+
+// output is always read until it is closed, so that the app never blocks on writing it
+func (app *appProcess) readLogs(stream string, reader io.Reader) {
+	buffer := bufio.NewReaderSize(reader, appLogLineMax)
+
+	for {
+		line, err := buffer.ReadSlice('\n')
+		if len(line) > 0 {
+			app.logFn(app.name, stream, strings.TrimRight(string(line), "\r\n"))
+		}
+
+		// buffer is full when line is too long, its rest is read as next log
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			return
+		}
+	}
+}
+
+func (app *appProcess) wait(cmd *exec.Cmd, done chan struct{}, stdout, stderr *os.File) {
+	defer close(done)
+
+	// output is read while waiting for the process
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		app.readLogs("stdout", stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		app.readLogs("stderr", stderr)
+	}()
+
+	err := cmd.Wait()
+	app.exited(err, cmd.ProcessState.ExitCode())
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	// descendants of the app might keep its output open, it is not read anymore
+	select {
+	case <-drained:
+	case <-time.After(appLogDrainTimeout):
+		app.logger.Debug().Msg("app output is still open after exit, closing it")
+	}
+
+	stdout.Close()
+	stderr.Close()
+	<-drained
+}
+
+// exit is recorded and restart is scheduled according to restart policy
+func (app *appProcess) exited(err error, exitCode int) {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+
+	uptime := time.Since(app.started)
+	app.cmd = nil
+	app.exitCode = &exitCode
+
+	app.logger.Info().Err(err).Int("exit_code", exitCode).Dur("uptime", uptime).Msg("app exited")
+
+	if app.stopped {
+		return
+	}
+
+	switch app.config.Restart {
+	case types.AppRestartAlways:
+	case types.AppRestartOnFailure:
+		if exitCode == 0 {
+			return
+		}
+	default:
+		return
+	}
+
+	// crashing app is restarted with increasing delay
+	if uptime >= appHealthyUptime {
+		app.restartDelay = appRestartDelay
+	}
+
+	delay := app.restartDelay
+	app.restartDelay = min(app.restartDelay*2, appRestartDelayMax)
+
+	app.logger.Info().Dur("delay", delay).Msg("restarting app")
+	app.restartTimer = time.AfterFunc(delay, func() {
+		app.mu.Lock()
+		defer app.mu.Unlock()
+
+		if app.stopped || app.cmd != nil {
+			return
+		}
+
+		app.restarts++
+		if err := app.spawn(); err != nil {
+			app.logger.Err(err).Msg("unable to restart app")
+		}
+	})
+}
+
+func (app *appProcess) stop() error {
+	app.mu.Lock()
+	app.stopped = true
+	if app.restartTimer != nil {
+		app.restartTimer.Stop()
+		app.restartTimer = nil
+	}
+
+	cmd, done := app.cmd, app.done
+	app.mu.Unlock()
+
+	if cmd == nil {
+		return types.ErrAppNotRunning
+	}
+
+	// negative pid signals whole process group
+	pgid := -cmd.Process.Pid
+	if err := syscall.Kill(pgid, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(appStopTimeout):
+		app.logger.Warn().Msg("app did not exit in time, killing")
+	}
+
+	if err := syscall.Kill(pgid, syscall.SIGKILL); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+
+	<-done
+	return nil
+}
+
+//
+// desktop manager
+//
+
+func (manager *DesktopManagerCtx) startApps() {
+	for _, app := range manager.apps {
+		if !app.config.Autostart {
+			continue
+		}
+
+		if err := app.start(); err != nil {
+			app.logger.Err(err).Msg("unable to start app")
+		}
+	}
+}
+
+func (manager *DesktopManagerCtx) stopApps() {
+	var wg sync.WaitGroup
+	for _, app := range manager.apps {
+		wg.Add(1)
+		go func(app *appProcess) {
+			defer wg.Done()
+
+			err := app.stop()
+			if err != nil && !errors.Is(err, types.ErrAppNotRunning) {
+				app.logger.Err(err).Msg("unable to stop app")
+			}
+		}(app)
+	}
+	wg.Wait()
+}
+
+func (manager *DesktopManagerCtx) Apps() []types.AppStatus {
+	apps := make([]types.AppStatus, 0, len(manager.apps))
+	for _, app := range manager.apps {
+		apps = append(apps, app.Status())
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].Name < apps[j].Name
+	})
+
+	return apps
+}
+
+func (manager *DesktopManagerCtx) App(name string) (types.AppStatus, error) {
+	app, ok := manager.apps[name]
+	if !ok {
+		return types.AppStatus{}, types.ErrAppNotFound
+	}
+
+	return app.Status(), nil
+}
+
+func (manager *DesktopManagerCtx) AppStart(name string) error {
+	app, ok := manager.apps[name]
+	if !ok {
+		return types.ErrAppNotFound
+	}
+
+	return app.start()
+}
+
+func (manager *DesktopManagerCtx) AppStop(name string) error {
+	app, ok := manager.apps[name]
+	if !ok {
+		return types.ErrAppNotFound
+	}
+
+	return app.stop()
+}
+
+func (manager *DesktopManagerCtx) AppRestart(name string) error {
+	app, ok := manager.apps[name]
+	if !ok {
+		return types.ErrAppNotFound
+	}
+
+	if err := app.stop(); err != nil && !errors.Is(err, types.ErrAppNotRunning) {
+		return err
+	}
+
+	return app.start()
+}
+
+func (manager *DesktopManagerCtx) OnAppLog(listener func(name, stream, line string)) {
+	manager.emmiter.On("app_log", func(payload ...any) {
+		listener(payload[0].(string), payload[1].(string), payload[2].(string))
+	})
+}
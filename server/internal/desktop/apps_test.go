@@ -0,0 +1,122 @@
+package desktop
+
+import (
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"m1k1o/neko/pkg/types"
+)
+
+func TestAppReadLogs(t *testing.T) {
+	long := strings.Repeat("x", appLogLineMax)
+
+	tests := []struct {
+		name   string
+		output string
+		want   []string
+	}{
+		{
+			name:   "lines",
+			output: "first\nsecond\r\n\nlast",
+			want:   []string{"first", "second", "", "last"},
+		},
+		{
+			name:   "long line is split",
+			output: long + "rest\nnext\n",
+			want:   []string{long, "rest", "next"},
+		},
+		{
+			name:   "no output",
+			output: "",
+			want:   []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			app := &appProcess{
+				name: "test",
+				logFn: func(name, stream, line string) {
+					got = append(got, line)
+				},
+			}
+
+			app.readLogs("stdout", strings.NewReader(tt.output))
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readLogs() logged %d lines, want %d", len(got), len(tt.want))
+			}
+		})
+	}
+}
+
+func TestAppWaitExit(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		wantCode int
+	}{
+		{
+			name:     "exit",
+			command:  "echo out; echo err >&2; exit 1",
+			wantCode: 1,
+		},
+		{
+			name:     "exit with descendant keeping output open",
+			command:  "sleep 60 & exit 1",
+			wantCode: 1,
+		},
+		{
+			name:     "exit with detached descendant",
+			command:  "sleep 60 & echo started; exit 0",
+			wantCode: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := appProcessNew(zerolog.Nop(), "test", types.AppConfig{
+				Command: []string{"sh", "-c", tt.command},
+			}, ":0", func(name, stream, line string) {})
+
+			if err := app.start(); err != nil {
+				t.Fatal(err)
+			}
+
+			app.mu.Lock()
+			pid, done := app.cmd.Process.Pid, app.done
+			app.mu.Unlock()
+
+			// descendants are in the same process group
+			defer syscall.Kill(-pid, syscall.SIGKILL)
+
+			deadline := time.Now().Add(5 * time.Second)
+			for app.Status().ExitCode == nil {
+				if time.Now().After(deadline) {
+					t.Fatal("app exit was not detected")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			status := app.Status()
+			if status.Running {
+				t.Errorf("Status().Running = true, want false")
+			}
+			if *status.ExitCode != tt.wantCode {
+				t.Errorf("Status().ExitCode = %d, want %d", *status.ExitCode, tt.wantCode)
+			}
+
+			select {
+			case <-done:
+			case <-time.After(appLogDrainTimeout + 5*time.Second):
+				t.Errorf("app output was not closed after exit")
+			}
+		})
+	}
+}
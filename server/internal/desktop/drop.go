@@ -66,3 +66,11 @@ func (manager *DesktopManagerCtx) DropFiles(x int, y int, files []string) bool {
 func (manager *DesktopManagerCtx) IsUploadDropEnabled() bool {
 	return manager.config.UploadDrop
 }
+
+func (manager *DesktopManagerCtx) IsDownloadDragEnabled() bool {
+	return manager.config.DownloadDrag
+}
+
+func (manager *DesktopManagerCtx) DownloadDragDir() string {
+	return manager.config.DownloadDragDir
+}
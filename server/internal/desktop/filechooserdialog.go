@@ -2,12 +2,16 @@ package desktop
 
 import (
 	"errors"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 
+	"m1k1o/neko/pkg/xdgportal"
 	"m1k1o/neko/pkg/xorg"
 )
 
-// name of the window that is being controlled
+// name of the window that is being controlled, when portal is not used
 const fileChooserDialogName = "Open File"
 
 // short sleep value between fake user interactions
@@ -16,11 +20,126 @@ const fileChooserDialogShortSleep = "0.2"
 // long sleep value between fake user interactions
 const fileChooserDialogLongSleep = "0.4"
 
+func (manager *DesktopManagerCtx) startFileChooserPortal() {
+	if err := xdgportal.Connect(); err != nil {
+		manager.logger.Warn().Err(err).Msg("unable to provide file chooser portal, falling back to xdotool")
+		return
+	}
+
+	manager.logger.Info().Str("name", xdgportal.BusName).Msg("providing file chooser portal")
+
+	xdgportal.Emmiter.On("file-chooser-opened", func(payload ...any) {
+		request := payload[0].(xdgportal.FileChooserRequest)
+
+		manager.fileChooserRequestMu.Lock()
+		if manager.fileChooserRequest != nil {
+			manager.fileChooserRequestMu.Unlock()
+
+			// only one dialog can be handled at a time
+			manager.logger.Warn().Str("app_id", request.AppID).Msg("file chooser dialog is already opened, cancelling")
+			if err := xdgportal.Cancel(request.Handle); err != nil {
+				manager.logger.Err(err).Msg("unable to cancel file chooser request")
+			}
+			return
+		}
+		manager.fileChooserRequest = &request
+		manager.fileChooserRequestMu.Unlock()
+
+		manager.logger.Info().
+			Str("app_id", request.AppID).
+			Str("title", request.Title).
+			Bool("multiple", request.Multiple).
+			Bool("directory", request.Directory).
+			Msg("file chooser dialog opened through portal")
+
+		manager.emmiter.Emit("file_chooser_dialog_opened")
+	})
+
+	// closed by the application
+	xdgportal.Emmiter.On("file-chooser-closed", func(payload ...any) {
+		if manager.takeFileChooserRequest(payload[0].(string)) != nil {
+			manager.emmiter.Emit("file_chooser_dialog_closed")
+		}
+	})
+
+	manager.wg.Add(1)
+	go func() {
+		defer manager.wg.Done()
+
+		if err := xdgportal.EventLoop(manager.shutdown); err != nil {
+			manager.logger.Err(err).Msg("file chooser portal stopped")
+		}
+	}()
+}
+
+// removes the opened portal request, when handle is set it must match
+func (manager *DesktopManagerCtx) takeFileChooserRequest(handle string) *xdgportal.FileChooserRequest {
+	manager.fileChooserRequestMu.Lock()
+	defer manager.fileChooserRequestMu.Unlock()
+
+	request := manager.fileChooserRequest
+	if request == nil || (handle != "" && request.Handle != handle) {
+		return nil
+	}
+
+	manager.fileChooserRequest = nil
+	return request
+}
+
+// uris of files in the directory, that are selected in the dialog
+func fileChooserUris(dir string, request *xdgportal.FileChooserRequest) ([]string, error) {
+	fileUri := func(path string) string {
+		return (&url.URL{Scheme: "file", Path: path}).String()
+	}
+
+	// directory itself is selected, when application asks for one
+	if request.Directory {
+		return []string{fileUri(dir)}, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	uris := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		uris = append(uris, fileUri(filepath.Join(dir, entry.Name())))
+		if !request.Multiple {
+			break
+		}
+	}
+
+	if len(uris) == 0 {
+		return nil, errors.New("no files to select in dialog")
+	}
+
+	return uris, nil
+}
+
 func (manager *DesktopManagerCtx) HandleFileChooserDialog(uri string) error {
+	if request := manager.takeFileChooserRequest(""); request != nil {
+		defer manager.emmiter.Emit("file_chooser_dialog_closed")
+
+		uris, err := fileChooserUris(uri, request)
+		if err != nil {
+			if err := xdgportal.Cancel(request.Handle); err != nil {
+				manager.logger.Err(err).Msg("unable to cancel file chooser request")
+			}
+			return err
+		}
+
+		return xdgportal.Respond(request.Handle, uris)
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
 
-	// TODO: Use native API.
+	// fallback for applications not using the portal
 	err1 := exec.Command(
 		"xdotool",
 		"search", "--name", fileChooserDialogName, "windowfocus",
@@ -42,7 +161,6 @@ func (manager *DesktopManagerCtx) HandleFileChooserDialog(uri string) error {
 		return err1
 	}
 
-	// TODO: Use native API.
 	err2 := exec.Command(
 		"xdotool",
 		"search", "--name", fileChooserDialogName,
@@ -57,12 +175,21 @@ func (manager *DesktopManagerCtx) HandleFileChooserDialog(uri string) error {
 }
 
 func (manager *DesktopManagerCtx) CloseFileChooserDialog() {
+	if request := manager.takeFileChooserRequest(""); request != nil {
+		if err := xdgportal.Cancel(request.Handle); err != nil {
+			manager.logger.Err(err).Msg("unable to cancel file chooser request")
+		}
+
+		manager.logger.Info().Msg("file chooser dialog is closed")
+		manager.emmiter.Emit("file_chooser_dialog_closed")
+		return
+	}
+
 	for i := 0; i < 5; i++ {
 		mu.Lock()
 
 		manager.logger.Debug().Msg("attempting to close file chooser dialog")
 
-		// TODO: Use native API.
 		err := exec.Command(
 			"xdotool",
 			"search", "--name", fileChooserDialogName, "windowfocus",
@@ -89,10 +216,17 @@ func (manager *DesktopManagerCtx) IsFileChooserDialogEnabled() bool {
 }
 
 func (manager *DesktopManagerCtx) IsFileChooserDialogOpened() bool {
+	manager.fileChooserRequestMu.Lock()
+	opened := manager.fileChooserRequest != nil
+	manager.fileChooserRequestMu.Unlock()
+
+	if opened {
+		return true
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
 
-	// TODO: Use native API.
 	err := exec.Command(
 		"xdotool",
 		"search", "--name", fileChooserDialogName,
@@ -1,7 +1,9 @@
 package desktop
 
 import (
+	"image"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kataras/go-events"
@@ -10,6 +12,7 @@ import (
 
 	"m1k1o/neko/internal/config"
 	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/xdgportal"
 	"m1k1o/neko/pkg/xevent"
 	"m1k1o/neko/pkg/xinput"
 	"m1k1o/neko/pkg/xorg"
@@ -25,6 +28,20 @@ type DesktopManagerCtx struct {
 	config     *config.Desktop
 	screenSize types.ScreenSize // cached screen size
 	input      xinput.Driver
+	apps       map[string]*appProcess
+
+	// output or region that input coordinates are relative to, and its last known position
+	inputOutput   string
+	inputRegion   types.ScreenRegion
+	inputRect     *image.Rectangle
+	inputRegionMu sync.Mutex
+
+	// input of sessions is ignored while locked
+	inputLocked atomic.Bool
+
+	// file chooser opened through the portal, xdotool is used when nil
+	fileChooserRequest   *xdgportal.FileChooserRequest
+	fileChooserRequestMu sync.Mutex
 }
 
 func New(config *config.Desktop) *DesktopManagerCtx {
@@ -35,14 +52,23 @@ func New(config *config.Desktop) *DesktopManagerCtx {
 		input = xinput.NewDummy()
 	}
 
-	return &DesktopManagerCtx{
+	manager := &DesktopManagerCtx{
 		logger:     log.With().Str("module", "desktop").Logger(),
 		shutdown:   make(chan struct{}),
 		emmiter:    events.New(),
 		config:     config,
 		screenSize: config.ScreenSize,
 		input:      input,
+		apps:       map[string]*appProcess{},
 	}
+
+	for name, app := range config.Apps {
+		manager.apps[name] = appProcessNew(manager.logger, name, app, config.Display, func(name, stream, line string) {
+			manager.emmiter.Emit("app_log", name, stream, line)
+		})
+	}
+
+	return manager
 }
 
 func (manager *DesktopManagerCtx) Start() {
@@ -77,6 +103,7 @@ func (manager *DesktopManagerCtx) Start() {
 	// set up event listeners
 	xevent.Unminimize = manager.config.Unminimize
 	xevent.FileChooserDialog = manager.config.FileChooserDialog
+	xevent.DragOut = manager.config.DownloadDrag
 	go xevent.EventLoop(manager.config.Display)
 
 	// in case it was opened
@@ -84,6 +111,10 @@ func (manager *DesktopManagerCtx) Start() {
 		go manager.CloseFileChooserDialog()
 	}
 
+	if manager.config.FileChooserDialog && manager.config.FileChooserPortal {
+		manager.startFileChooserPortal()
+	}
+
 	manager.OnEventError(func(error_code uint8, message string, request_code uint8, minor_code uint8) {
 		manager.logger.Warn().
 			Uint8("error_code", error_code).
@@ -93,6 +124,8 @@ func (manager *DesktopManagerCtx) Start() {
 			Msg("X event error occured")
 	})
 
+	manager.startApps()
+
 	manager.wg.Add(1)
 
 	go func() {
@@ -113,6 +146,24 @@ func (manager *DesktopManagerCtx) Start() {
 			}
 		}
 	}()
+
+	manager.wg.Add(1)
+
+	go func() {
+		defer manager.wg.Done()
+
+		ticker := time.NewTicker(inputWindowRefresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-manager.shutdown:
+				return
+			case <-ticker.C:
+				manager.refreshInputWindow()
+			}
+		}
+	}()
 }
 
 func (manager *DesktopManagerCtx) OnBeforeScreenSizeChange(listener func()) {
@@ -133,6 +184,8 @@ func (manager *DesktopManagerCtx) Shutdown() error {
 	close(manager.shutdown)
 	manager.wg.Wait()
 
+	manager.stopApps()
+
 	xorg.DisplayClose()
 	return nil
 }
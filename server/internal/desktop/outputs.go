@@ -0,0 +1,191 @@
+package desktop
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/xorg"
+)
+
+// followed window can move, its position is refreshed this often
+const inputWindowRefresh = 250 * time.Millisecond
+
+func (manager *DesktopManagerCtx) ScreenOutputs() []types.ScreenOutput {
+	return xorg.GetScreenOutputs()
+}
+
+func (manager *DesktopManagerCtx) ScreenOutput(name string) (types.ScreenOutput, bool) {
+	for _, output := range xorg.GetScreenOutputs() {
+		if output.Name == name {
+			return output, true
+		}
+	}
+
+	return types.ScreenOutput{}, false
+}
+
+func (manager *DesktopManagerCtx) ScreenRegionRect(region types.ScreenRegion) (image.Rectangle, error) {
+	size := manager.GetScreenSize()
+	screen := image.Rect(0, 0, size.Width, size.Height)
+
+	switch {
+	case region.IsZero():
+		return screen, nil
+	case region.Window != "":
+		window, err := manager.FindWindow(region.Window)
+		if err != nil {
+			return image.Rectangle{}, err
+		}
+
+		// whole window is captured, even when it is partially outside of the screen
+		rect := image.Rect(window.X, window.Y, window.X+window.Width, window.Y+window.Height)
+		if !rect.Overlaps(screen) {
+			return image.Rectangle{}, fmt.Errorf("%w: window %q is outside of the screen", types.ErrScreenRegionInvalid, region.Window)
+		}
+
+		return rect, nil
+	}
+
+	rect := image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+	if region.Width <= 0 || region.Height <= 0 || !rect.In(screen) {
+		return image.Rectangle{}, fmt.Errorf("%w: %v is not inside of the screen %v", types.ErrScreenRegionInvalid, rect, screen)
+	}
+
+	return rect, nil
+}
+
+func (manager *DesktopManagerCtx) SetInputOutput(name string) error {
+	var rect *image.Rectangle
+	if name != "" {
+		output, ok := manager.ScreenOutput(name)
+		if !ok {
+			return types.ErrScreenOutputNotFound
+		}
+
+		found := image.Rect(output.X, output.Y, output.X+output.Width, output.Y+output.Height)
+		rect = &found
+	}
+
+	manager.inputRegionMu.Lock()
+	defer manager.inputRegionMu.Unlock()
+
+	if manager.inputOutput != name || !manager.inputRegion.IsZero() {
+		manager.logger.Info().Str("output", name).Msg("setting input output")
+	}
+
+	manager.inputOutput = name
+	manager.inputRegion = types.ScreenRegion{}
+	manager.inputRect = rect
+	return nil
+}
+
+func (manager *DesktopManagerCtx) InputOutput() string {
+	manager.inputRegionMu.Lock()
+	defer manager.inputRegionMu.Unlock()
+
+	return manager.inputOutput
+}
+
+func (manager *DesktopManagerCtx) SetInputRegion(region types.ScreenRegion) error {
+	var rect *image.Rectangle
+	if !region.IsZero() {
+		found, err := manager.ScreenRegionRect(region)
+		if err != nil {
+			return err
+		}
+		rect = &found
+	}
+
+	manager.inputRegionMu.Lock()
+	defer manager.inputRegionMu.Unlock()
+
+	if manager.inputRegion != region || manager.inputOutput != "" {
+		manager.logger.Info().Interface("region", region).Msg("setting input region")
+	}
+
+	manager.inputOutput = ""
+	manager.inputRegion = region
+	manager.inputRect = rect
+	return nil
+}
+
+func (manager *DesktopManagerCtx) InputRegion() types.ScreenRegion {
+	manager.inputRegionMu.Lock()
+	defer manager.inputRegionMu.Unlock()
+
+	return manager.inputRegion
+}
+
+// output or region geometry could have changed together with the screen size
+func (manager *DesktopManagerCtx) refreshInputRegion() {
+	manager.inputRegionMu.Lock()
+	output, region := manager.inputOutput, manager.inputRegion
+	manager.inputRegionMu.Unlock()
+
+	var err error
+	switch {
+	case output != "":
+		err = manager.SetInputOutput(output)
+	case !region.IsZero():
+		err = manager.SetInputRegion(region)
+	default:
+		return
+	}
+
+	if err != nil {
+		manager.logger.Warn().Err(err).
+			Str("output", output).
+			Interface("region", region).
+			Msg("input output or region is no longer available, using whole screen")
+
+		manager.inputRegionMu.Lock()
+		manager.inputOutput = ""
+		manager.inputRegion = types.ScreenRegion{}
+		manager.inputRect = nil
+		manager.inputRegionMu.Unlock()
+	}
+}
+
+// followed window keeps its last known position while it cannot be found,
+// windows are listed without holding the lock, so that input is not blocked
+func (manager *DesktopManagerCtx) refreshInputWindow() {
+	region := manager.InputRegion()
+	if region.Window == "" {
+		return
+	}
+
+	rect, err := manager.ScreenRegionRect(region)
+	if err != nil {
+		return
+	}
+
+	manager.inputRegionMu.Lock()
+	defer manager.inputRegionMu.Unlock()
+
+	// region could have been changed in the meantime
+	if manager.inputRegion == region {
+		manager.inputRect = &rect
+	}
+}
+
+func (manager *DesktopManagerCtx) inputArea() *image.Rectangle {
+	manager.inputRegionMu.Lock()
+	defer manager.inputRegionMu.Unlock()
+
+	return manager.inputRect
+}
+
+// position relative to the input output or region is translated to the screen position
+func (manager *DesktopManagerCtx) InputToScreen(x, y int) (int, int) {
+	area := manager.inputArea()
+	if area == nil {
+		return x, y
+	}
+
+	// cursor can not leave the region
+	x = min(max(x, 0), area.Dx()-1)
+	y = min(max(y, 0), area.Dy()-1)
+	return area.Min.X + x, area.Min.Y + y
+}
@@ -0,0 +1,114 @@
+package desktop
+
+import (
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/utils"
+	"m1k1o/neko/pkg/xorg"
+)
+
+func (manager *DesktopManagerCtx) Windows() []types.Window {
+	active := xorg.GetActiveWindow()
+
+	windows := []types.Window{}
+	for _, id := range xorg.GetWindows() {
+		// window could have been closed in the meantime
+		window, ok := xorg.GetWindow(id)
+		if !ok {
+			continue
+		}
+
+		window.Focused = id == active
+		windows = append(windows, window)
+	}
+
+	return windows
+}
+
+// only windows managed by the window manager can be accessed
+func (manager *DesktopManagerCtx) managedWindow(id uint32) error {
+	if ok, _ := utils.ArrayIn(id, xorg.GetWindows()); !ok {
+		return types.ErrWindowNotFound
+	}
+
+	return nil
+}
+
+func (manager *DesktopManagerCtx) Window(id uint32) (types.Window, error) {
+	if err := manager.managedWindow(id); err != nil {
+		return types.Window{}, err
+	}
+
+	window, ok := xorg.GetWindow(id)
+	if !ok {
+		return types.Window{}, types.ErrWindowNotFound
+	}
+
+	window.Focused = id == xorg.GetActiveWindow()
+	return window, nil
+}
+
+func (manager *DesktopManagerCtx) FindWindow(match string) (types.Window, error) {
+	window, ok := findWindow(manager.Windows(), match)
+	if !ok {
+		return types.Window{}, types.ErrWindowNotFound
+	}
+
+	return window, nil
+}
+
+// first window with matching class or title, focused one is preferred
+func findWindow(windows []types.Window, match string) (types.Window, bool) {
+	var found *types.Window
+	for _, window := range windows {
+		if window.Class != match && window.Title != match {
+			continue
+		}
+
+		if found == nil || window.Focused {
+			w := window
+			found = &w
+		}
+	}
+
+	if found == nil {
+		return types.Window{}, false
+	}
+
+	return *found, true
+}
+
+func (manager *DesktopManagerCtx) FocusWindow(id uint32) error {
+	if err := manager.managedWindow(id); err != nil {
+		return err
+	}
+
+	xorg.FocusWindow(id)
+	return nil
+}
+
+func (manager *DesktopManagerCtx) MoveResizeWindow(id uint32, x, y, width, height int) error {
+	if err := manager.managedWindow(id); err != nil {
+		return err
+	}
+
+	xorg.MoveResizeWindow(id, x, y, width, height)
+	return nil
+}
+
+func (manager *DesktopManagerCtx) MaximizeWindow(id uint32, maximize bool) error {
+	if err := manager.managedWindow(id); err != nil {
+		return err
+	}
+
+	xorg.MaximizeWindow(id, maximize)
+	return nil
+}
+
+func (manager *DesktopManagerCtx) CloseWindow(id uint32) error {
+	if err := manager.managedWindow(id); err != nil {
+		return err
+	}
+
+	xorg.CloseWindow(id)
+	return nil
+}
@@ -0,0 +1,61 @@
+package desktop
+
+import (
+	"testing"
+
+	"m1k1o/neko/pkg/types"
+)
+
+func TestFindWindow(t *testing.T) {
+	windows := []types.Window{
+		{ID: 1, Title: "Terminal", Class: "xterm"},
+		{ID: 2, Title: "Mozilla Firefox", Class: "firefox"},
+		{ID: 3, Title: "Private Browsing", Class: "firefox", Focused: true},
+		{ID: 4, Title: "xterm", Class: "terminal"},
+	}
+
+	tests := []struct {
+		name   string
+		match  string
+		wantID uint32
+		wantOk bool
+	}{
+		{
+			name:   "by title",
+			match:  "Terminal",
+			wantID: 1,
+			wantOk: true,
+		},
+		{
+			name:   "focused window is preferred",
+			match:  "firefox",
+			wantID: 3,
+			wantOk: true,
+		},
+		{
+			name:   "first match without focus",
+			match:  "xterm",
+			wantID: 1,
+			wantOk: true,
+		},
+		{
+			name:   "not found",
+			match:  "chromium",
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			window, ok := findWindow(windows, tt.match)
+			if ok != tt.wantOk {
+				t.Errorf("findWindow() ok = %v, want %v", ok, tt.wantOk)
+				return
+			}
+
+			if ok && window.ID != tt.wantID {
+				t.Errorf("findWindow() = window %d, want %d", window.ID, tt.wantID)
+			}
+		})
+	}
+}
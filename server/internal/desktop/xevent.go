@@ -1,9 +1,15 @@
 package desktop
 
 import (
+	"net/url"
+
 	"m1k1o/neko/pkg/xevent"
 )
 
+func (manager *DesktopManagerCtx) GetScreenDamage() uint64 {
+	return xevent.DamageCounter()
+}
+
 func (manager *DesktopManagerCtx) OnCursorChanged(listener func(serial uint64)) {
 	xevent.Emmiter.On("cursor-changed", func(payload ...any) {
 		listener(payload[0].(uint64))
@@ -20,12 +26,36 @@ func (manager *DesktopManagerCtx) OnFileChooserDialogOpened(listener func()) {
 	xevent.Emmiter.On("file-chooser-dialog-opened", func(payload ...any) {
 		listener()
 	})
+	manager.emmiter.On("file_chooser_dialog_opened", func(payload ...any) {
+		listener()
+	})
 }
 
 func (manager *DesktopManagerCtx) OnFileChooserDialogClosed(listener func()) {
 	xevent.Emmiter.On("file-chooser-dialog-closed", func(payload ...any) {
 		listener()
 	})
+	manager.emmiter.On("file_chooser_dialog_closed", func(payload ...any) {
+		listener()
+	})
+}
+
+func (manager *DesktopManagerCtx) OnFilesDragged(listener func(files []string)) {
+	xevent.Emmiter.On("drag-out", func(payload ...any) {
+		files := []string{}
+		for _, uri := range payload[0].([]string) {
+			// only local files can be offered
+			u, err := url.Parse(uri)
+			if err != nil || u.Scheme != "file" || u.Path == "" {
+				continue
+			}
+			files = append(files, u.Path)
+		}
+
+		if len(files) > 0 {
+			listener(files)
+		}
+	})
 }
 
 func (manager *DesktopManagerCtx) OnEventError(listener func(error_code uint8, message string, request_code uint8, minor_code uint8)) {
@@ -33,3 +63,21 @@ func (manager *DesktopManagerCtx) OnEventError(listener func(error_code uint8, m
 		listener(payload[0].(uint8), payload[1].(string), payload[2].(uint8), payload[3].(uint8))
 	})
 }
+
+func (manager *DesktopManagerCtx) OnWindowOpened(listener func(id uint32)) {
+	xevent.Emmiter.On("window-opened", func(payload ...any) {
+		listener(payload[0].(uint32))
+	})
+}
+
+func (manager *DesktopManagerCtx) OnWindowClosed(listener func(id uint32)) {
+	xevent.Emmiter.On("window-closed", func(payload ...any) {
+		listener(payload[0].(uint32))
+	})
+}
+
+func (manager *DesktopManagerCtx) OnWindowFocused(listener func(id uint32)) {
+	xevent.Emmiter.On("window-focused", func(payload ...any) {
+		listener(payload[0].(uint32))
+	})
+}
@@ -3,6 +3,7 @@ package desktop
 import "m1k1o/neko/pkg/xinput"
 
 func (manager *DesktopManagerCtx) inputRelToAbs(x, y int) (int, int) {
+	x, y = manager.InputToScreen(x, y)
 	return (x * xinput.AbsX) / manager.screenSize.Width, (y * xinput.AbsY) / manager.screenSize.Height
 }
 
@@ -1,9 +1,9 @@
 package desktop
 
 import (
+	"fmt"
 	"image"
-	"os/exec"
-	"regexp"
+	"strings"
 	"time"
 
 	"m1k1o/neko/pkg/types"
@@ -11,6 +11,7 @@ import (
 )
 
 func (manager *DesktopManagerCtx) Move(x, y int) {
+	x, y = manager.InputToScreen(x, y)
 	xorg.Move(x, y)
 }
 
@@ -62,16 +63,40 @@ func (manager *DesktopManagerCtx) KeyPress(codes ...uint32) error {
 	return nil
 }
 
+func (manager *DesktopManagerCtx) TypeText(text string) error {
+	return xorg.TypeText(text)
+}
+
 func (manager *DesktopManagerCtx) ResetKeys() {
 	xorg.ResetKeys()
 }
 
+func (manager *DesktopManagerCtx) LockInput() bool {
+	return manager.inputLocked.CompareAndSwap(false, true)
+}
+
+func (manager *DesktopManagerCtx) UnlockInput() {
+	manager.inputLocked.Store(false)
+}
+
+func (manager *DesktopManagerCtx) InputLocked() bool {
+	return manager.inputLocked.Load()
+}
+
 func (manager *DesktopManagerCtx) ScreenConfigurations() []types.ScreenSize {
+	sizeMin, sizeMax := manager.ScreenSizeBounds()
+
 	var configs []types.ScreenSize
 	for _, size := range xorg.ScreenConfigurations {
+		// filter out sizes that cannot be set
+		if size.Width < sizeMin.Width || size.Width > sizeMax.Width ||
+			size.Height < sizeMin.Height || size.Height > sizeMax.Height {
+			continue
+		}
+
 		for _, fps := range size.Rates {
 			// filter out all irrelevant rates
-			if fps > 60 || (fps > 30 && fps%10 != 0) {
+			if fps > 60 || (fps > 30 && fps%10 != 0) || fps < sizeMin.Rate || fps > sizeMax.Rate {
 				continue
 			}
 
@@ -85,7 +110,26 @@ func (manager *DesktopManagerCtx) ScreenConfigurations() []types.ScreenSize {
 	return configs
 }
 
+func (manager *DesktopManagerCtx) ScreenSizeBounds() (types.ScreenSize, types.ScreenSize) {
+	return manager.config.ScreenSizeMin, manager.config.ScreenSizeMax
+}
+
 func (manager *DesktopManagerCtx) SetScreenSize(screenSize types.ScreenSize) (types.ScreenSize, error) {
+	sizeMin, sizeMax := manager.ScreenSizeBounds()
+
+	// unspecified rate is the highest allowed, up to 60
+	if screenSize.Rate == 0 {
+		screenSize.Rate = min(60, sizeMax.Rate)
+	}
+
+	// modes outside of bounds would be created on demand, so they must be rejected here
+	if screenSize.Width < sizeMin.Width || screenSize.Width > sizeMax.Width ||
+		screenSize.Height < sizeMin.Height || screenSize.Height > sizeMax.Height ||
+		screenSize.Rate < sizeMin.Rate || screenSize.Rate > sizeMax.Rate {
+		return screenSize, fmt.Errorf("%w: %s is not between %s and %s",
+			types.ErrScreenSizeOutOfBounds, screenSize, sizeMin, sizeMax)
+	}
+
 	mu.Lock()
 	manager.emmiter.Emit("before_screen_size_change")
 
@@ -98,6 +142,7 @@ func (manager *DesktopManagerCtx) SetScreenSize(screenSize types.ScreenSize) (ty
 	if err == nil {
 		// cache the new screen size
 		manager.screenSize = screenSize
+		manager.refreshInputRegion()
 	}
 
 	return screenSize, err
@@ -108,35 +153,47 @@ func (manager *DesktopManagerCtx) GetScreenSize() types.ScreenSize {
 }
 
 func (manager *DesktopManagerCtx) SetKeyboardMap(kbd types.KeyboardMap) error {
-	// TOOD: Use native API.
-	cmd := exec.Command("setxkbmap", "-layout", kbd.Layout, "-variant", kbd.Variant)
-	_, err := cmd.Output()
-	return err
+	if kbd.Layout != "" {
+		if err := xorg.SetKeyboardLayout(kbd.Layout, kbd.Variant); err != nil {
+			return err
+		}
+	}
+
+	if kbd.Group != nil {
+		layout, _, err := xorg.GetKeyboardLayout()
+		if err != nil {
+			return err
+		}
+
+		// group must exist in current layout
+		groups := len(strings.Split(layout, ","))
+		if *kbd.Group < 0 || *kbd.Group >= groups {
+			return fmt.Errorf("keyboard group %d out of range, layout has %d groups", *kbd.Group, groups)
+		}
+
+		xorg.SetKeyboardGroup(*kbd.Group)
+	}
+
+	return nil
 }
 
 func (manager *DesktopManagerCtx) GetKeyboardMap() (*types.KeyboardMap, error) {
-	// TOOD: Use native API.
-	cmd := exec.Command("setxkbmap", "-query")
-	res, err := cmd.Output()
+	layout, variant, err := xorg.GetKeyboardLayout()
 	if err != nil {
 		return nil, err
 	}
 
-	kbd := types.KeyboardMap{}
+	group := xorg.GetKeyboardGroup()
 
-	re := regexp.MustCompile(`layout:\s+(.*)\n`)
-	arr := re.FindStringSubmatch(string(res))
-	if len(arr) > 1 {
-		kbd.Layout = arr[1]
-	}
-
-	re = regexp.MustCompile(`variant:\s+(.*)\n`)
-	arr = re.FindStringSubmatch(string(res))
-	if len(arr) > 1 {
-		kbd.Variant = arr[1]
-	}
+	return &types.KeyboardMap{
+		Layout:  layout,
+		Variant: variant,
+		Group:   &group,
+	}, nil
+}
 
-	return &kbd, nil
+func (manager *DesktopManagerCtx) KeyboardLayouts() ([]types.KeyboardLayout, error) {
+	return xorg.GetKeyboardLayouts()
 }
 
 func (manager *DesktopManagerCtx) SetKeyboardModifiers(mod types.KeyboardModifiers) {
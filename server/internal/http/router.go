@@ -41,7 +41,7 @@ func WithCORS(allowOrigin func(origin string) bool) RouterOption {
 			},
 			AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-			ExposedHeaders:   []string{"Link"},
+			ExposedHeaders:   []string{"Link", "Location"},
 			AllowCredentials: true,
 			MaxAge:           300, // Maximum value not ignored by any of major browsers
 		}))
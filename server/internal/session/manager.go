@@ -327,6 +327,28 @@ func (manager *SessionManagerCtx) AdminBroadcast(event string, payload any, excl
 	}
 }
 
+// sent to admins and sessions that can host, e.g. window titles
+func (manager *SessionManagerCtx) HostsBroadcast(event string, payload any, exclude ...string) {
+	for _, session := range manager.List() {
+		if !session.State().IsConnected {
+			continue
+		}
+
+		profile := session.Profile()
+		if !profile.IsAdmin && (!profile.CanHost || session.PrivateModeEnabled()) {
+			continue
+		}
+
+		if len(exclude) > 0 {
+			if in, _ := utils.ArrayIn(session.ID(), exclude); in {
+				continue
+			}
+		}
+
+		session.Send(event, payload)
+	}
+}
+
 func (manager *SessionManagerCtx) InactiveCursorsBroadcast(event string, payload any, exclude ...string) {
 	for _, session := range manager.List() {
 		if !session.State().IsConnected || !session.Profile().CanSeeInactiveCursors {
@@ -435,6 +457,15 @@ func (manager *SessionManagerCtx) updateSettings(session types.Session, new, old
 		}
 	}
 
+	// if room audio mute changed
+	if old.AudioMuted != new.AudioMuted {
+		for _, s := range manager.List() {
+			if webrtcPeer := s.GetWebRTCPeer(); webrtcPeer != nil {
+				webrtcPeer.SetAudioMuted(s.IsAudioMuted())
+			}
+		}
+	}
+
 	// if control protection changed and controls are not locked
 	if old.ControlProtection != new.ControlProtection && new.ControlProtection && !new.LockedControls {
 		// if there is no admin, lock controls
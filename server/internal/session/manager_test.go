@@ -0,0 +1,91 @@
+package session
+
+import (
+	"fmt"
+	"testing"
+
+	"m1k1o/neko/internal/config"
+	"m1k1o/neko/pkg/types"
+)
+
+// peer that counts received events
+type testPeer struct {
+	events int
+}
+
+func (p *testPeer) Send(event string, payload any) { p.events++ }
+func (p *testPeer) Ping() error                    { return nil }
+func (p *testPeer) Destroy(reason string)          {}
+
+func TestHostsBroadcast(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   types.MemberProfile
+		connected bool
+		private   bool
+		want      int
+	}{
+		{
+			name:      "admin",
+			profile:   types.MemberProfile{IsAdmin: true},
+			connected: true,
+			want:      1,
+		},
+		{
+			name:      "admin in private mode",
+			profile:   types.MemberProfile{IsAdmin: true},
+			connected: true,
+			private:   true,
+			want:      1,
+		},
+		{
+			name:      "can host",
+			profile:   types.MemberProfile{CanHost: true},
+			connected: true,
+			want:      1,
+		},
+		{
+			name:      "can host in private mode",
+			profile:   types.MemberProfile{CanHost: true},
+			connected: true,
+			private:   true,
+			want:      0,
+		},
+		{
+			name:      "can only watch",
+			profile:   types.MemberProfile{CanWatch: true},
+			connected: true,
+			want:      0,
+		},
+		{
+			name:    "not connected",
+			profile: types.MemberProfile{IsAdmin: true},
+			want:    0,
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := New(&config.Session{})
+			manager.UpdateSettingsFunc(nil, func(settings *types.Settings) bool {
+				settings.PrivateMode = tt.private
+				return true
+			})
+
+			session, _, err := manager.Create(fmt.Sprintf("id-%d", i), tt.profile)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+
+			peer := &testPeer{}
+			if tt.connected {
+				session.ConnectWebSocketPeer(peer)
+			}
+
+			manager.HostsBroadcast("test", nil)
+			if peer.events != tt.want {
+				t.Errorf("HostsBroadcast() sent %d events, want %d", peer.events, tt.want)
+			}
+		})
+	}
+}
@@ -95,6 +95,27 @@ func (session *SessionCtx) PrivateModeEnabled() bool {
 	return session.manager.Settings().PrivateMode && !session.profile.IsAdmin
 }
 
+// muted by an admin or for the whole room
+func (session *SessionCtx) IsAudioMuted() bool {
+	return session.state.AudioMuted || session.manager.Settings().AudioMuted
+}
+
+func (session *SessionCtx) SetAudioMuted(muted bool) {
+	if session.state.AudioMuted == muted {
+		return
+	}
+
+	session.logger.Info().Bool("muted", muted).Msg("set audio muted")
+
+	session.state.AudioMuted = muted
+	session.manager.emmiter.Emit("state_changed", session)
+
+	// update webrtc audio muted state
+	if webrtcPeer := session.GetWebRTCPeer(); webrtcPeer != nil {
+		webrtcPeer.SetAudioMuted(session.IsAudioMuted())
+	}
+}
+
 func (session *SessionCtx) SetCursor(cursor types.Cursor) {
 	if session.manager.Settings().InactiveCursors && session.profile.SendsInactiveCursor {
 		session.manager.SetCursor(cursor, session)
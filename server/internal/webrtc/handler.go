@@ -3,8 +3,10 @@ package webrtc
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"math"
 	"time"
+	"unicode/utf8"
 
 	"m1k1o/neko/internal/webrtc/payload"
 	"m1k1o/neko/pkg/types"
@@ -13,12 +15,102 @@ import (
 	"github.com/rs/zerolog"
 )
 
+// maximum size of clipboard content received in chunks
+const maxClipboardSize = 8 * 1024 * 1024
+
+// dataChannelState holds input state, that spans across multiple messages
+type dataChannelState struct {
+	// remainders of high resolution scroll
+	scrollX int32
+	scrollY int32
+
+	// clipboard content received so far
+	clipboard     []byte
+	clipboardNext uint16
+
+	// peer owning this data channel
+	peer *WebRTCPeerCtx
+	// whether client answers server pings, its own rtt reports are then ignored
+	serverPing bool
+}
+
+func readVersion(buffer *bytes.Buffer, supported uint8) error {
+	version := &payload.Version{}
+	if err := binary.Read(buffer, binary.BigEndian, version); err != nil {
+		return err
+	}
+
+	if version.Version == 0 || version.Version > supported {
+		return fmt.Errorf("unsupported message version %d, supported up to %d", version.Version, supported)
+	}
+
+	return nil
+}
+
+// readBody returns message body limited by its declared length
+func readBody(buffer *bytes.Buffer, header *payload.Header) (*bytes.Buffer, error) {
+	length := int(header.Length)
+	if length > buffer.Len() {
+		return nil, fmt.Errorf("declared length %d exceeds received %d bytes", length, buffer.Len())
+	}
+
+	return bytes.NewBuffer(buffer.Next(length)), nil
+}
+
+// clipboardChunks splits clipboard text into chunks sent in separate messages
+func clipboardChunks(text []byte) [][]byte {
+	chunks := [][]byte{}
+	for len(text) > payload.CLIPBOARD_CHUNK_SIZE {
+		chunks = append(chunks, text[:payload.CLIPBOARD_CHUNK_SIZE])
+		text = text[payload.CLIPBOARD_CHUNK_SIZE:]
+	}
+
+	// empty text is sent as a single empty chunk
+	return append(chunks, text)
+}
+
+func sendServerPing(dataChannel *webrtc.DataChannel) error {
+	header := payload.Header{
+		Event:  payload.OP_SERVER_PING,
+		Length: 12,
+	}
+
+	version := payload.Version{
+		Version: payload.SERVER_PING_VERSION,
+	}
+
+	serverTs := uint64(time.Now().UnixMicro())
+	ping := payload.ServerPing{
+		ServerTs1: uint32(serverTs / math.MaxUint32),
+		ServerTs2: uint32(serverTs % math.MaxUint32),
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err := binary.Write(buffer, binary.BigEndian, header); err != nil {
+		return err
+	}
+
+	if err := binary.Write(buffer, binary.BigEndian, version); err != nil {
+		return err
+	}
+
+	if err := binary.Write(buffer, binary.BigEndian, ping); err != nil {
+		return err
+	}
+
+	return dataChannel.Send(buffer.Bytes())
+}
+
 func (manager *WebRTCManagerCtx) handle(
 	logger zerolog.Logger, data []byte,
 	dataChannel *webrtc.DataChannel,
 	session types.Session,
+	state *dataChannelState,
 ) error {
 	isHost := session.IsHost()
+	// input is ignored while it is locked, e.g. by input sequence
+	inputLocked := manager.desktop.InputLocked()
 
 	//
 	// parse header
@@ -43,10 +135,10 @@ func (manager *WebRTCManagerCtx) handle(
 		}
 
 		x, y := int(payload.X), int(payload.Y)
-		if isHost {
+		if isHost && !inputLocked {
 			// handle active cursor movement
 			manager.desktop.Move(x, y)
-			manager.curPosition.Set(x, y)
+			manager.curPosition.Set(manager.desktop.InputToScreen(x, y))
 		} else {
 			// handle inactive cursor movement
 			session.SetCursor(types.Cursor{
@@ -88,7 +180,52 @@ func (manager *WebRTCManagerCtx) handle(
 			return err
 		}
 
-		return dataChannel.Send(buffer.Bytes())
+		if err := dataChannel.Send(buffer.Bytes()); err != nil {
+			return err
+		}
+
+		// measure round trip time from server side as well
+		if state.peer != nil && state.peer.SupportsDataChannel("server_ping", payload.SERVER_PING_VERSION) {
+			return sendServerPing(dataChannel)
+		}
+
+		return nil
+	} else if header.Event == payload.OP_SERVER_PONG {
+		if err := readVersion(buffer, payload.SERVER_PING_VERSION); err != nil {
+			return err
+		}
+
+		ping := &payload.ServerPing{}
+		if err := binary.Read(buffer, binary.BigEndian, ping); err != nil {
+			return err
+		}
+
+		rtt := time.Since(time.UnixMicro(int64(ping.ServerTs())))
+		if rtt > 0 {
+			state.serverPing = true
+			manager.metrics.getBySession(session).ObserveRtt(rtt)
+		}
+
+		return nil
+	} else if header.Event == payload.OP_LATENCY {
+		if err := readVersion(buffer, payload.LATENCY_VERSION); err != nil {
+			return err
+		}
+
+		latency := &payload.Latency{}
+		if err := binary.Read(buffer, binary.BigEndian, latency); err != nil {
+			return err
+		}
+
+		metrics := manager.metrics.getBySession(session)
+		if latency.Rtt > 0 && !state.serverPing {
+			metrics.ObserveRtt(time.Duration(latency.Rtt) * time.Microsecond)
+		}
+		if latency.InputToDisplay > 0 {
+			metrics.ObserveInputToDisplay(time.Duration(latency.InputToDisplay) * time.Microsecond)
+		}
+
+		return nil
 	}
 
 	// continue only if session is host
@@ -96,6 +233,11 @@ func (manager *WebRTCManagerCtx) handle(
 		return nil
 	}
 
+	// only clipboard can be set while input is locked
+	if inputLocked && header.Event != payload.OP_CLIPBOARD {
+		return nil
+	}
+
 	switch header.Event {
 	case payload.OP_SCROLL:
 		// TODO: remove this once the client is fixed
@@ -200,6 +342,144 @@ func (manager *WebRTCManagerCtx) handle(
 		} else {
 			logger.Trace().Uint32("touchId", payload.TouchId).Msg("touch end")
 		}
+	case payload.OP_KEYBOARD_MODIFIERS:
+		if err := readVersion(buffer, payload.KEYBOARD_MODIFIERS_VERSION); err != nil {
+			return err
+		}
+
+		payload := &payload.KeyboardModifiers{}
+		if err := binary.Read(buffer, binary.BigEndian, payload); err != nil {
+			return err
+		}
+
+		modifiers := types.KeyboardModifiers{}
+		for i, mod := range []**bool{
+			&modifiers.Shift,
+			&modifiers.CapsLock,
+			&modifiers.Control,
+			&modifiers.Alt,
+			&modifiers.NumLock,
+			&modifiers.Meta,
+			&modifiers.Super,
+			&modifiers.AltGr,
+		} {
+			bit := uint8(1) << i
+			if payload.Mask&bit != 0 {
+				active := payload.State&bit != 0
+				*mod = &active
+			}
+		}
+
+		manager.desktop.SetKeyboardModifiers(modifiers)
+		logger.Trace().
+			Uint8("mask", payload.Mask).
+			Uint8("state", payload.State).
+			Msg("keyboard modifiers")
+	case payload.OP_TEXT:
+		buffer, err := readBody(buffer, header)
+		if err != nil {
+			return err
+		}
+
+		if err := readVersion(buffer, payload.TEXT_VERSION); err != nil {
+			return err
+		}
+
+		text := buffer.Bytes()
+		if !utf8.Valid(text) {
+			return fmt.Errorf("text is not valid utf-8")
+		}
+
+		if err := manager.desktop.TypeText(string(text)); err != nil {
+			logger.Warn().Err(err).Msg("text typing failed")
+		}
+
+		logger.Trace().Int("length", len(text)).Msg("text")
+	case payload.OP_CLIPBOARD:
+		buffer, err := readBody(buffer, header)
+		if err != nil {
+			return err
+		}
+
+		if err := readVersion(buffer, payload.CLIPBOARD_VERSION); err != nil {
+			return err
+		}
+
+		if !session.Profile().CanAccessClipboard {
+			return fmt.Errorf("cannot access clipboard")
+		}
+
+		payload := &payload.Clipboard{}
+		if err := binary.Read(buffer, binary.BigEndian, payload); err != nil {
+			return err
+		}
+
+		// first chunk resets clipboard content
+		if payload.Index == 0 {
+			state.clipboard = nil
+			state.clipboardNext = 0
+		}
+
+		if payload.Index != state.clipboardNext || payload.Index >= payload.Count {
+			state.clipboard = nil
+			state.clipboardNext = 0
+			return fmt.Errorf("unexpected clipboard chunk %d of %d", payload.Index, payload.Count)
+		}
+
+		if len(state.clipboard)+buffer.Len() > maxClipboardSize {
+			state.clipboard = nil
+			state.clipboardNext = 0
+			return fmt.Errorf("clipboard content exceeds %d bytes", maxClipboardSize)
+		}
+
+		state.clipboard = append(state.clipboard, buffer.Bytes()...)
+		state.clipboardNext++
+
+		// wait for remaining chunks
+		if state.clipboardNext < payload.Count {
+			return nil
+		}
+
+		text := state.clipboard
+		state.clipboard = nil
+		state.clipboardNext = 0
+
+		if err := manager.desktop.ClipboardSetText(types.ClipboardText{
+			Text: string(text),
+		}); err != nil {
+			logger.Warn().Err(err).Msg("clipboard set failed")
+		} else {
+			logger.Trace().Int("length", len(text)).Msg("clipboard set")
+		}
+	case payload.OP_SCROLL_HIRES:
+		if err := readVersion(buffer, payload.SCROLL_HIRES_VERSION); err != nil {
+			return err
+		}
+
+		step := int32(payload.SCROLL_HIRES_STEP)
+		payload := &payload.ScrollHiRes{}
+		if err := binary.Read(buffer, binary.BigEndian, payload); err != nil {
+			return err
+		}
+
+		// accumulate deltas and scroll only by whole steps
+		state.scrollX += payload.DeltaX
+		state.scrollY += payload.DeltaY
+
+		stepsX := state.scrollX / step
+		stepsY := state.scrollY / step
+		state.scrollX -= stepsX * step
+		state.scrollY -= stepsY * step
+
+		if stepsX != 0 || stepsY != 0 {
+			manager.desktop.Scroll(int(stepsX), int(stepsY), payload.ControlKey)
+		}
+
+		logger.Trace().
+			Int32("deltaX", payload.DeltaX).
+			Int32("deltaY", payload.DeltaY).
+			Bool("controlKey", payload.ControlKey).
+			Msg("scroll hires")
 	}
 
 	return nil
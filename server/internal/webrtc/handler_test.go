@@ -0,0 +1,107 @@
+package webrtc
+
+import (
+	"bytes"
+	"testing"
+
+	"m1k1o/neko/internal/webrtc/payload"
+)
+
+func TestReadBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		length  uint16
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:   "exact length",
+			data:   []byte{1, 'a', 'b'},
+			length: 3,
+			want:   []byte{1, 'a', 'b'},
+		},
+		{
+			name:   "trailing data is ignored",
+			data:   []byte{1, 'a', 'b', 'c'},
+			length: 2,
+			want:   []byte{1, 'a'},
+		},
+		{
+			name:   "empty body",
+			data:   []byte{},
+			length: 0,
+			want:   []byte{},
+		},
+		{
+			name:    "length exceeds data",
+			data:    []byte{1, 'a'},
+			length:  3,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := &payload.Header{Length: tt.length}
+			body, err := readBody(bytes.NewBuffer(tt.data), header)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("readBody() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err == nil && !bytes.Equal(body.Bytes(), tt.want) {
+				t.Errorf("readBody() = %v, want %v", body.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestClipboardChunks(t *testing.T) {
+	size := payload.CLIPBOARD_CHUNK_SIZE
+
+	tests := []struct {
+		name   string
+		length int
+		want   []int
+	}{
+		{
+			name:   "empty text",
+			length: 0,
+			want:   []int{0},
+		},
+		{
+			name:   "single chunk",
+			length: 10,
+			want:   []int{10},
+		},
+		{
+			name:   "exactly one chunk",
+			length: size,
+			want:   []int{size},
+		},
+		{
+			name:   "multiple chunks",
+			length: 2*size + 1,
+			want:   []int{size, size, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := bytes.Repeat([]byte{'x'}, tt.length)
+			chunks := clipboardChunks(text)
+
+			if len(chunks) != len(tt.want) {
+				t.Errorf("clipboardChunks() returned %d chunks, want %d", len(chunks), len(tt.want))
+				return
+			}
+
+			for i, chunk := range chunks {
+				if len(chunk) != tt.want[i] {
+					t.Errorf("clipboardChunks() chunk %d has %d bytes, want %d", i, len(chunk), tt.want[i])
+				}
+			}
+		})
+	}
+}
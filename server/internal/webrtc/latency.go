@@ -0,0 +1,117 @@
+package webrtc
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/rtp"
+
+	"m1k1o/neko/pkg/types"
+)
+
+// how many latency samples are kept for computing percentiles
+const latencyWindowSize = 256
+
+// absCaptureTimeURI is RTP header extension carrying capture timestamp of a frame
+// https://webrtc.googlesource.com/src/+/refs/heads/main/docs/native-code/rtp-hdrext/abs-capture-time
+const absCaptureTimeURI = "http://www.webrtc.org/experiments/rtp-hdrext/abs-capture-time"
+
+//
+// latency window
+//
+
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []float64
+	next    int
+}
+
+func newLatencyWindow() *latencyWindow {
+	return &latencyWindow{
+		samples: make([]float64, 0, latencyWindowSize),
+	}
+}
+
+func (w *latencyWindow) Add(value float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < latencyWindowSize {
+		w.samples = append(w.samples, value)
+		return
+	}
+
+	w.samples[w.next] = value
+	w.next = (w.next + 1) % latencyWindowSize
+}
+
+func (w *latencyWindow) Percentiles() types.LatencyPercentiles {
+	w.mu.Lock()
+	sorted := make([]float64, len(w.samples))
+	copy(sorted, w.samples)
+	w.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return types.LatencyPercentiles{}
+	}
+
+	sort.Float64s(sorted)
+	percentile := func(p float64) float64 {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+
+	return types.LatencyPercentiles{
+		Samples: len(sorted),
+		P50:     percentile(0.50),
+		P90:     percentile(0.90),
+		P99:     percentile(0.99),
+	}
+}
+
+//
+// abs-capture-time interceptor
+//
+
+// captureTimeInterceptor adds abs-capture-time header extension to outgoing
+// RTP packets, with the timestamp of the last sample written to the track.
+type captureTimeInterceptor struct {
+	interceptor.NoOp
+	captureTime atomic.Int64
+}
+
+func (i *captureTimeInterceptor) NewInterceptor(id string) (interceptor.Interceptor, error) {
+	return i, nil
+}
+
+func (i *captureTimeInterceptor) SetCaptureTime(t time.Time) {
+	i.captureTime.Store(t.UnixNano())
+}
+
+func (i *captureTimeInterceptor) BindLocalStream(info *interceptor.StreamInfo, writer interceptor.RTPWriter) interceptor.RTPWriter {
+	var extId uint8
+	for _, ext := range info.RTPHeaderExtensions {
+		if ext.URI == absCaptureTimeURI {
+			extId = uint8(ext.ID)
+			break
+		}
+	}
+
+	// extension was not negotiated
+	if extId == 0 {
+		return writer
+	}
+
+	return interceptor.RTPWriterFunc(func(header *rtp.Header, payload []byte, attributes interceptor.Attributes) (int, error) {
+		if ts := i.captureTime.Load(); ts != 0 {
+			ext, err := rtp.NewAbsCaptureTimeExtension(time.Unix(0, ts)).Marshal()
+			if err == nil {
+				_ = header.SetExtension(extId, ext)
+			}
+		}
+
+		return writer.Write(header, payload, attributes)
+	})
+}
@@ -0,0 +1,62 @@
+package webrtc
+
+import (
+	"testing"
+
+	"m1k1o/neko/pkg/types"
+)
+
+func TestLatencyWindowPercentiles(t *testing.T) {
+	sequence := func(from, to int) []float64 {
+		values := []float64{}
+		for i := from; i <= to; i++ {
+			values = append(values, float64(i))
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values []float64
+		want   types.LatencyPercentiles
+	}{
+		{
+			name:   "no samples",
+			values: nil,
+			want:   types.LatencyPercentiles{},
+		},
+		{
+			name:   "single sample",
+			values: []float64{5},
+			want:   types.LatencyPercentiles{Samples: 1, P50: 5, P90: 5, P99: 5},
+		},
+		{
+			name:   "unsorted samples",
+			values: []float64{30, 10, 20},
+			want:   types.LatencyPercentiles{Samples: 3, P50: 20, P90: 20, P99: 20},
+		},
+		{
+			name:   "full window",
+			values: sequence(1, latencyWindowSize),
+			want:   types.LatencyPercentiles{Samples: latencyWindowSize, P50: 128, P90: 230, P99: 253},
+		},
+		{
+			name:   "oldest samples are replaced",
+			values: sequence(1, latencyWindowSize+latencyWindowSize/2),
+			want:   types.LatencyPercentiles{Samples: latencyWindowSize, P50: 256, P90: 358, P99: 381},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newLatencyWindow()
+			for _, value := range tt.values {
+				w.Add(value)
+			}
+
+			if got := w.Percentiles(); got != tt.want {
+				t.Errorf("Percentiles() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -44,8 +44,8 @@ func (manager *WebRTCManagerCtx) handleLegacy(
 	logger zerolog.Logger, data []byte,
 	session types.Session,
 ) error {
-	// continue only if session is host
-	if !session.LegacyIsHost() {
+	// continue only if session is host and input is not locked
+	if !session.LegacyIsHost() || manager.desktop.InputLocked() {
 		return nil
 	}
 
@@ -2,6 +2,7 @@ package webrtc
 
 import (
 	"fmt"
+	"image"
 	"net"
 	"strings"
 	"sync"
@@ -19,6 +20,7 @@ import (
 
 	"m1k1o/neko/internal/config"
 	"m1k1o/neko/internal/webrtc/cursor"
+	"m1k1o/neko/internal/webrtc/payload"
 	"m1k1o/neko/internal/webrtc/pionlog"
 	"m1k1o/neko/pkg/types"
 	"m1k1o/neko/pkg/types/codec"
@@ -45,6 +47,9 @@ const (
 
 	// send a PLI on an interval so that the publisher is pushing a keyframe every rtcpPLIInterval
 	rtcpPLIInterval = 3 * time.Second
+
+	// captured window can move, video areas of peers are refreshed this often
+	videoWindowRefresh = 250 * time.Millisecond
 )
 
 func New(desktop types.DesktopManager, capture types.CaptureManager, config *config.WebRTC) *WebRTCManagerCtx {
@@ -85,6 +90,11 @@ func New(desktop types.DesktopManager, capture types.CaptureManager, config *con
 		capture:     capture,
 		curImage:    cursor.NewImage(logger, desktop),
 		curPosition: cursor.NewPosition(logger),
+
+		peers:     map[*WebRTCPeerCtx]struct{}{},
+		whipPeers: map[string]*whipPeer{},
+
+		shutdown: make(chan struct{}),
 	}
 }
 
@@ -104,12 +114,55 @@ type WebRTCManagerCtx struct {
 	tcpMux ice.TCPMux
 	udpMux ice.UDPMux
 
+	// stops currently received remote webcam and microphone tracks
 	camStop, micStop *func()
+	remoteTrackMu    sync.Mutex
+
+	peers   map[*WebRTCPeerCtx]struct{}
+	peersMu sync.Mutex
+
+	whipPeers   map[string]*whipPeer
+	whipPeersMu sync.Mutex
+
+	wg       sync.WaitGroup
+	shutdown chan struct{}
 }
 
 func (manager *WebRTCManagerCtx) Start() {
 	manager.curImage.Start()
 
+	// removed video stream moved its listeners, update peers watching it
+	manager.capture.Video().OnStreamMoved(func(from, to types.StreamSinkManager) {
+		manager.peersMu.Lock()
+		defer manager.peersMu.Unlock()
+
+		for peer := range manager.peers {
+			peer.videoStreamMoved(from, to)
+		}
+	})
+
+	// outputs and regions could have moved together with the screen size
+	manager.desktop.OnAfterScreenSizeChange(func() {
+		manager.refreshVideoAreas(false)
+	})
+
+	manager.wg.Add(1)
+	go func() {
+		defer manager.wg.Done()
+
+		ticker := time.NewTicker(videoWindowRefresh)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-manager.shutdown:
+				return
+			case <-ticker.C:
+				manager.refreshVideoAreas(true)
+			}
+		}
+	}()
+
 	logger := pionlog.New(manager.logger)
 
 	// add TCP Mux listener
@@ -158,9 +211,14 @@ func (manager *WebRTCManagerCtx) Start() {
 func (manager *WebRTCManagerCtx) Shutdown() error {
 	manager.logger.Info().Msg("shutdown")
 
+	close(manager.shutdown)
+	manager.wg.Wait()
+
 	manager.curImage.Shutdown()
 	manager.curPosition.Shutdown()
 
+	manager.destroyWhipPeers()
+
 	return nil
 }
 
@@ -168,7 +226,18 @@ func (manager *WebRTCManagerCtx) ICEServers() []types.ICEServer {
 	return manager.config.ICEServersFrontend
 }
 
-func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs []codec.RTPCodec) (*webrtc.PeerConnection, cc.BandwidthEstimator, error) {
+func (manager *WebRTCManagerCtx) DataChannelCapabilities() types.DataChannelCapabilities {
+	return types.DataChannelCapabilities{
+		"keyboard_modifiers": payload.KEYBOARD_MODIFIERS_VERSION,
+		"text":               payload.TEXT_VERSION,
+		"clipboard":          payload.CLIPBOARD_VERSION,
+		"scroll_hires":       payload.SCROLL_HIRES_VERSION,
+		"server_ping":        payload.SERVER_PING_VERSION,
+		"latency":            payload.LATENCY_VERSION,
+	}
+}
+
+func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs []codec.RTPCodec, interceptors ...interceptor.Factory) (*webrtc.PeerConnection, cc.BandwidthEstimator, error) {
 	// create media engine
 	engine := &webrtc.MediaEngine{}
 	for _, codec := range codecs {
@@ -177,6 +246,14 @@ func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs
 		}
 	}
 
+	// send capture time of video frames
+	err := engine.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{
+		URI: absCaptureTimeURI,
+	}, webrtc.RTPCodecTypeVideo)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// create setting engine
 	settings := webrtc.SettingEngine{
 		LoggerFactory: pionlog.New(logger),
@@ -221,6 +298,9 @@ func (manager *WebRTCManagerCtx) newPeerConnection(logger zerolog.Logger, codecs
 
 	// create interceptor registry
 	registry := &interceptor.Registry{}
+	for _, factory := range interceptors {
+		registry.Add(factory)
+	}
 
 	// create bandwidth estimator
 	estimatorChan := make(chan cc.BandwidthEstimator, 1)
@@ -277,15 +357,16 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 	logger.Info().Msg("creating webrtc peer")
 
 	// all audios must have the same codec
-	audio := manager.capture.Audio()
+	audio := manager.capture.Audios()
 	audioCodec := audio.Codec()
 
 	// all videos must have the same codec
 	video := manager.capture.Video()
 	videoCodec := video.Codec()
 
+	captureTime := &captureTimeInterceptor{}
 	connection, estimator, err := manager.newPeerConnection(
-		logger, []codec.RTPCodec{audioCodec, videoCodec})
+		logger, []codec.RTPCodec{audioCodec, videoCodec}, captureTime)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -315,15 +396,15 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 	// we disable audio by default manually
 	audioTrack.SetPaused(true)
 
-	// set stream for audio track
-	_, err = audioTrack.SetStream(audio)
+	// set default stream for audio track
+	_, err = audioTrack.SetStream(manager.capture.Audio())
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// video track
 	videoRtcp := make(chan []rtcp.Packet, 1)
-	videoTrack, err := NewTrack(logger, videoCodec, connection, WithRtcpChan(videoRtcp))
+	videoTrack, err := NewTrack(logger, videoCodec, connection, WithRtcpChan(videoRtcp), WithCaptureTime(captureTime))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -369,8 +450,37 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 		iceTrickle:      manager.config.ICETrickle,
 		estimatorConfig: manager.config.Estimator,
 		audioDisabled:   true, // we disable audio by default manually
+		audioID:         audio.IDs()[0],
+		audioVolume:     100,
+		// cursor position is sent relative to the output or region the peer
+		// watches, host input is mapped to it as well
+		videoChanged: func(peer *WebRTCPeerCtx, videoID string) {
+			area, err := manager.videoArea(videoID)
+			if err != nil {
+				logger.Warn().Err(err).Str("video_id", videoID).Msg("failed to get video area, using whole screen")
+			}
+			manager.setVideoArea(peer, videoID, area)
+
+			if !session.IsHost() {
+				return
+			}
+
+			if output := video.ScreenOutput(videoID); output != "" {
+				err = manager.desktop.SetInputOutput(output)
+			} else {
+				err = manager.desktop.SetInputRegion(video.ScreenRegion(videoID))
+			}
+
+			if err != nil {
+				logger.Err(err).Str("video_id", videoID).Msg("failed to map input to screen output or region")
+			}
+		},
 	}
 
+	manager.peersMu.Lock()
+	manager.peers[peer] = struct{}{}
+	manager.peersMu.Unlock()
+
 	connection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
 		logger := logger.With().
 			Str("kind", track.Kind().String()).
@@ -385,87 +495,7 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 			return
 		}
 
-		// parse codec from remote track
-		codec, ok := codec.ParseRTC(track.Codec())
-		if !ok {
-			err := receiver.Stop()
-			logger.Warn().Err(err).Msg("remote track with unknown codec")
-			return
-		}
-
-		var srcManager types.StreamSrcManager
-
-		stopped := false
-		stopFn := func() {
-			if stopped {
-				return
-			}
-
-			stopped = true
-			err := receiver.Stop()
-			srcManager.Stop()
-			logger.Err(err).Msg("remote track stopped")
-		}
-
-		if track.Kind() == webrtc.RTPCodecTypeAudio {
-			// audio -> microphone
-			srcManager = manager.capture.Microphone()
-			defer stopFn()
-
-			if manager.micStop != nil {
-				(*manager.micStop)()
-			}
-			manager.micStop = &stopFn
-		} else if track.Kind() == webrtc.RTPCodecTypeVideo {
-			// video -> webcam
-			srcManager = manager.capture.Webcam()
-			defer stopFn()
-
-			if manager.camStop != nil {
-				(*manager.camStop)()
-			}
-			manager.camStop = &stopFn
-		} else {
-			err := receiver.Stop()
-			logger.Warn().Err(err).Msg("remote track with unsupported codec type")
-			return
-		}
-
-		err := srcManager.Start(codec)
-		if err != nil {
-			logger.Err(err).Msg("failed to start pipeline")
-			return
-		}
-
-		ticker := time.NewTicker(rtcpPLIInterval)
-		defer ticker.Stop()
-
-		go func() {
-			for range ticker.C {
-				err := connection.WriteRTCP([]rtcp.Packet{
-					&rtcp.PictureLossIndication{
-						MediaSSRC: uint32(track.SSRC()),
-					},
-				})
-
-				if err != nil {
-					logger.Err(err).Msg("remote track rtcp send err")
-				}
-			}
-		}()
-
-		buf := make([]byte, 1400)
-		for {
-			i, _, err := track.Read(buf)
-			if err != nil {
-				logger.Warn().Err(err).Msg("failed read from remote track")
-				break
-			}
-
-			srcManager.Push(buf[:i])
-		}
-
-		logger.Info().Msg("remote track data finished")
+		manager.handleRemoteTrack(logger, connection, track, receiver)
 	})
 
 	connection.OnDataChannel(func(dc *webrtc.DataChannel) {
@@ -499,12 +529,17 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 			// ensure we only run this once
 			once.Do(func() {
 				session.SetWebRTCConnected(peer, false)
+
+				manager.peersMu.Lock()
+				delete(manager.peers, peer)
+				manager.peersMu.Unlock()
 				//
 				// TODO: Shutdown peer?
 				//
 				audioTrack.Shutdown()
 				videoTrack.Shutdown()
 				close(videoRtcp)
+				metrics.DeleteLatency()
 			})
 		}
 
@@ -539,8 +574,9 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 		manager.curPosition.RemoveListener(peer)
 	})
 
+	dataChannelState := &dataChannelState{peer: peer}
 	dataChannel.OnMessage(func(message webrtc.DataChannelMessage) {
-		if err := manager.handle(logger, message.Data, dataChannel, session); err != nil {
+		if err := manager.handle(logger, message.Data, dataChannel, session, dataChannelState); err != nil {
 			logger.Err(err).Msg("data handle failed")
 		}
 	})
@@ -586,6 +622,157 @@ func (manager *WebRTCManagerCtx) CreatePeer(session types.Session) (*webrtc.Sess
 	return offer, peer, nil
 }
 
+// handleRemoteTrack pushes remote track into webcam or microphone stream source, it blocks until track is finished
+func (manager *WebRTCManagerCtx) handleRemoteTrack(logger zerolog.Logger, connection *webrtc.PeerConnection, track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+	// parse codec from remote track
+	codec, ok := codec.ParseRTC(track.Codec())
+	if !ok {
+		err := receiver.Stop()
+		logger.Warn().Err(err).Msg("remote track with unknown codec")
+		return
+	}
+
+	var srcManager types.StreamSrcManager
+
+	// can be called by this track or by a newer track replacing it
+	var stopOnce sync.Once
+	stopFn := func() {
+		stopOnce.Do(func() {
+			err := receiver.Stop()
+			srcManager.Stop()
+			logger.Err(err).Msg("remote track stopped")
+		})
+	}
+
+	var prevStop *func()
+	if track.Kind() == webrtc.RTPCodecTypeAudio {
+		// audio -> microphone
+		srcManager = manager.capture.Microphone()
+		defer stopFn()
+
+		manager.remoteTrackMu.Lock()
+		prevStop, manager.micStop = manager.micStop, &stopFn
+		manager.remoteTrackMu.Unlock()
+	} else if track.Kind() == webrtc.RTPCodecTypeVideo {
+		// video -> webcam
+		srcManager = manager.capture.Webcam()
+		defer stopFn()
+
+		manager.remoteTrackMu.Lock()
+		prevStop, manager.camStop = manager.camStop, &stopFn
+		manager.remoteTrackMu.Unlock()
+	} else {
+		err := receiver.Stop()
+		logger.Warn().Err(err).Msg("remote track with unsupported codec type")
+		return
+	}
+
+	// previous track must be stopped before starting the new one
+	if prevStop != nil {
+		(*prevStop)()
+	}
+
+	err := srcManager.Start(codec)
+	if err != nil {
+		logger.Err(err).Msg("failed to start pipeline")
+		return
+	}
+
+	ticker := time.NewTicker(rtcpPLIInterval)
+	defer ticker.Stop()
+
+	go func() {
+		for range ticker.C {
+			err := connection.WriteRTCP([]rtcp.Packet{
+				&rtcp.PictureLossIndication{
+					MediaSSRC: uint32(track.SSRC()),
+				},
+			})
+
+			if err != nil {
+				logger.Err(err).Msg("remote track rtcp send err")
+			}
+		}
+	}()
+
+	buf := make([]byte, 1400)
+	for {
+		i, _, err := track.Read(buf)
+		if err != nil {
+			logger.Warn().Err(err).Msg("failed read from remote track")
+			break
+		}
+
+		srcManager.Push(buf[:i])
+	}
+
+	logger.Info().Msg("remote track data finished")
+}
+
 func (manager *WebRTCManagerCtx) SetCursorPosition(x, y int) {
 	manager.curPosition.Set(x, y)
 }
+
+// part of the screen that is captured in the video, nil means whole screen
+func (manager *WebRTCManagerCtx) videoArea(videoID string) (*image.Rectangle, error) {
+	video := manager.capture.Video()
+
+	if name := video.ScreenOutput(videoID); name != "" {
+		output, ok := manager.desktop.ScreenOutput(name)
+		if !ok {
+			return nil, types.ErrScreenOutputNotFound
+		}
+
+		rect := image.Rect(output.X, output.Y, output.X+output.Width, output.Y+output.Height)
+		return &rect, nil
+	}
+
+	region := video.ScreenRegion(videoID)
+	if region.IsZero() {
+		return nil, nil
+	}
+
+	rect, err := manager.desktop.ScreenRegionRect(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rect, nil
+}
+
+// cursor position is sent again, when it moved relative to the new area
+func (manager *WebRTCManagerCtx) setVideoArea(peer *WebRTCPeerCtx, videoID string, area *image.Rectangle) {
+	if !peer.setVideoArea(videoID, area) || peer.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return
+	}
+
+	x, y := manager.desktop.GetCursorPosition()
+	if err := peer.SendCursorPosition(x, y); err != nil {
+		peer.logger.Err(err).Msg("failed to set cursor position")
+	}
+}
+
+// areas that can not be found keep their last known position
+func (manager *WebRTCManagerCtx) refreshVideoAreas(windowsOnly bool) {
+	manager.peersMu.Lock()
+	peers := make([]*WebRTCPeerCtx, 0, len(manager.peers))
+	for peer := range manager.peers {
+		peers = append(peers, peer)
+	}
+	manager.peersMu.Unlock()
+
+	video := manager.capture.Video()
+	for _, peer := range peers {
+		videoID := peer.Video().ID
+		if videoID == "" || (windowsOnly && video.ScreenRegion(videoID).Window == "") {
+			continue
+		}
+
+		area, err := manager.videoArea(videoID)
+		if err != nil {
+			continue
+		}
+
+		manager.setVideoArea(peer, videoID, area)
+	}
+}
@@ -17,6 +17,27 @@ const (
 	connectionStatsInterval = 5 * time.Second
 )
 
+// latency quantiles with their allowed errors
+var latencyObjectives = map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001}
+
+// latency summaries are removed when peer closes, so that they do not pile up
+var (
+	rttSummary = promauto.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "rtt_seconds",
+		Namespace:  "neko",
+		Subsystem:  "webrtc",
+		Help:       "Round trip time of datachannel ping measured by a session.",
+		Objectives: latencyObjectives,
+	}, []string{"session_id"})
+	inputToDisplaySummary = promauto.NewSummaryVec(prometheus.SummaryOpts{
+		Name:       "input_to_display_seconds",
+		Namespace:  "neko",
+		Subsystem:  "webrtc",
+		Help:       "Time from input event to its display measured by a session.",
+		Objectives: latencyObjectives,
+	}, []string{"session_id"})
+)
+
 type metricsManager struct {
 	mu sync.Mutex
 
@@ -216,6 +237,9 @@ func (m *metricsManager) getBySession(session types.Session) *metrics {
 				"transport":  "sctp",
 			},
 		}),
+
+		rttWindow:            newLatencyWindow(),
+		inputToDisplayWindow: newLatencyWindow(),
 	}
 
 	m.sessions[sessionId] = met
@@ -253,6 +277,9 @@ type metrics struct {
 	iceBytesReceived  prometheus.Gauge
 	sctpBytesSent     prometheus.Gauge
 	sctpBytesReceived prometheus.Gauge
+
+	rttWindow            *latencyWindow
+	inputToDisplayWindow *latencyWindow
 }
 
 func (met *metrics) reset() {
@@ -367,6 +394,29 @@ func (met *metrics) SetReceiverReport(report rtcp.ReceptionReport) {
 	met.receiverReportTotalLost.Set(float64(report.TotalLost))
 }
 
+func (met *metrics) ObserveRtt(rtt time.Duration) {
+	rttSummary.WithLabelValues(met.sessionId).Observe(rtt.Seconds())
+	met.rttWindow.Add(float64(rtt) / float64(time.Millisecond))
+}
+
+func (met *metrics) ObserveInputToDisplay(latency time.Duration) {
+	inputToDisplaySummary.WithLabelValues(met.sessionId).Observe(latency.Seconds())
+	met.inputToDisplayWindow.Add(float64(latency) / float64(time.Millisecond))
+}
+
+// DeleteLatency removes latency summaries of a closed peer.
+func (met *metrics) DeleteLatency() {
+	rttSummary.DeleteLabelValues(met.sessionId)
+	inputToDisplaySummary.DeleteLabelValues(met.sessionId)
+}
+
+func (met *metrics) Latency() types.PeerLatency {
+	return types.PeerLatency{
+		RTT:            met.rttWindow.Percentiles(),
+		InputToDisplay: met.inputToDisplayWindow.Percentiles(),
+	}
+}
+
 func (met *metrics) SetIceTransportStats(data webrtc.TransportStats) {
 	met.iceBytesSent.Set(float64(data.BytesSent))
 	met.iceBytesReceived.Set(float64(data.BytesReceived))
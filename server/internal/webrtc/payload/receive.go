@@ -14,8 +14,28 @@ const (
 	OP_TOUCH_BEGIN  = 0x08
 	OP_TOUCH_UPDATE = 0x09
 	OP_TOUCH_END    = 0x0a
+	// versioned events, payload starts with message version
+	OP_KEYBOARD_MODIFIERS = 0x0b
+	OP_TEXT               = 0x0c // followed by utf-8 encoded text
+	OP_CLIPBOARD          = 0x0d
+	OP_SCROLL_HIRES       = 0x0e
+	OP_LATENCY            = 0x0f
+	OP_SERVER_PONG        = 0x10 // followed by echoed ServerPing
 )
 
+// highest supported versions of versioned events
+const (
+	KEYBOARD_MODIFIERS_VERSION = 1
+	TEXT_VERSION               = 1
+	CLIPBOARD_VERSION          = 1
+	SCROLL_HIRES_VERSION       = 1
+	LATENCY_VERSION            = 1
+	SERVER_PING_VERSION        = 1
+)
+
+// high resolution scroll delta of a single wheel step
+const SCROLL_HIRES_STEP = 120
+
 type Move struct {
 	X uint16
 	Y uint16
@@ -53,3 +73,35 @@ type Touch struct {
 	Y        int32
 	Pressure uint8
 }
+
+type Version struct {
+	Version uint8
+}
+
+type KeyboardModifiers struct {
+	// bitmask of modifiers, that are being changed
+	Mask uint8
+	// bitmask of modifiers state
+	State uint8
+}
+
+// followed by clipboard text chunk
+type Clipboard struct {
+	Index uint16
+	Count uint16
+}
+
+type ScrollHiRes struct {
+	// deltas in fractions of a wheel step
+	DeltaX     int32
+	DeltaY     int32
+	ControlKey bool
+}
+
+// latency measured by client, 0 if not measured
+type Latency struct {
+	// round trip time of ping in microseconds
+	Rtt uint32
+	// time from input event to its display in microseconds
+	InputToDisplay uint32
+}
@@ -6,8 +6,15 @@ const (
 	OP_CURSOR_POSITION = 0x01
 	OP_CURSOR_IMAGE    = 0x02
 	OP_PONG            = 0x03
+	// versioned events, payload starts with message version
+	OP_CLIPBOARD_UPDATE = 0x04 // followed by Clipboard and text chunk
+	OP_SERVER_PING      = 0x05 // followed by ServerPing, answered by OP_SERVER_PONG
 )
 
+// maximum size of clipboard text in a single message, so that
+// whole message fits into 16KiB, supported by all browsers
+const CLIPBOARD_CHUNK_SIZE = 16*1024 - 8
+
 type CursorPosition struct {
 	X uint16
 	Y uint16
@@ -31,3 +38,14 @@ type Pong struct {
 func (p Pong) ServerTs() uint64 {
 	return (uint64(p.ServerTs1) * uint64(math.MaxUint32)) + uint64(p.ServerTs2)
 }
+
+// round trip time measured by server, client echoes it back unchanged
+type ServerPing struct {
+	// server's timestamp in microseconds split into two uint32
+	ServerTs1 uint32
+	ServerTs2 uint32
+}
+
+func (p ServerPing) ServerTs() uint64 {
+	return (uint64(p.ServerTs1) * uint64(math.MaxUint32)) + uint64(p.ServerTs2)
+}
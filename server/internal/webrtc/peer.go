@@ -3,7 +3,10 @@ package webrtc
 import (
 	"bytes"
 	"encoding/binary"
+	"image"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pion/interceptor/pkg/cc"
@@ -29,12 +32,14 @@ type WebRTCPeerCtx struct {
 	estimateTrend *utils.TrendDetector
 	// stream selectors
 	video types.StreamSelectorManager
-	audio types.StreamSinkManager
+	audio types.AudioSelectorManager
 	// tracks & channels
 	audioTrack  *Track
 	videoTrack  *Track
 	dataChannel *webrtc.DataChannel
 	rtcpChannel chan []rtcp.Packet
+	// versioned messages supported by client
+	dataChannelCaps types.DataChannelCapabilities
 	// config
 	iceTrickle      bool
 	estimatorConfig config.WebRTCEstimator
@@ -42,6 +47,17 @@ type WebRTCPeerCtx struct {
 	videoAuto       bool
 	videoDisabled   bool
 	audioDisabled   bool
+	// muted by admin or room settings
+	audioMuted bool
+	// selected audio stream and its volume in percent
+	audioID     string
+	audioVolume int
+	// called when selected video stream changes, only the latest change is applied
+	videoChanged    func(peer *WebRTCPeerCtx, videoID string)
+	videoChangedSeq atomic.Uint64
+	videoChangedMu  sync.Mutex
+	// part of the screen that is in the selected video, nil means whole screen
+	videoArea *image.Rectangle
 }
 
 //
@@ -184,6 +200,36 @@ func (peer *WebRTCPeerCtx) estimatorReader() {
 			continue
 		}
 
+		// in continuous mode, follow estimated bitrate with encoder bitrate, but only
+		// if we are the only listener, otherwise we would affect other peers
+		if conf.Continuous && stream.ListenersCount() == 1 {
+			bitrate := targetBitrate
+			if bitrate < conf.ContinuousMinBitrate {
+				bitrate = conf.ContinuousMinBitrate
+			}
+			if bitrate > conf.ContinuousMaxBitrate {
+				bitrate = conf.ContinuousMaxBitrate
+			}
+
+			// encoder bitrate is in kbit/s
+			bitrate /= 1000
+
+			// change bitrate only if difference is big enough
+			current, ok := stream.EstimatedBitrate()
+			if ok && math.Abs(float64(bitrate-current))/float64(current) < conf.DiffThreshold {
+				continue
+			}
+
+			err := stream.SetEstimatedBitrate(bitrate)
+			if err == nil {
+				debugLogger.Info().Int("bitrate", bitrate).Msg("changed encoder bitrate")
+				continue
+			}
+
+			// fallback to switching streams
+			debugLogger.Warn().Err(err).Msg("failed to change encoder bitrate")
+		}
+
 		// check whats the difference between target and stream bitrate
 		diff := float64(targetBitrate) / float64(streamBitrate)
 
@@ -316,10 +362,10 @@ func (peer *WebRTCPeerCtx) SetPaused(isPaused bool) error {
 	defer peer.mu.Unlock()
 
 	peer.videoTrack.SetPaused(isPaused || peer.videoDisabled)
-	peer.audioTrack.SetPaused(isPaused || peer.audioDisabled)
 
 	peer.logger.Info().Bool("is_paused", isPaused).Msg("set paused")
 	peer.paused = isPaused
+	peer.updateAudioPaused()
 
 	return nil
 }
@@ -375,6 +421,7 @@ func (peer *WebRTCPeerCtx) SetVideo(r types.PeerVideoRequest) error {
 		if changed {
 			videoID := stream.ID()
 			peer.metrics.SetVideoID(videoID)
+			peer.notifyVideoChanged(videoID)
 
 			peer.logger.Info().Str("video_id", videoID).Msg("set video")
 			modified = true
@@ -410,6 +457,62 @@ func (peer *WebRTCPeerCtx) SetVideo(r types.PeerVideoRequest) error {
 	return nil
 }
 
+// stream was removed and its listeners were moved to another stream
+func (peer *WebRTCPeerCtx) videoStreamMoved(from, to types.StreamSinkManager) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if peer.videoTrack == nil || !peer.videoTrack.ReplaceStream(from, to) {
+		return
+	}
+
+	videoID := to.ID()
+	peer.metrics.SetVideoID(videoID)
+	peer.notifyVideoChanged(videoID)
+
+	peer.logger.Info().Str("video_id", videoID).Msg("video stream moved")
+
+	go func() {
+		// in goroutine because of mutex and we don't want to block
+		peer.session.Send(event.SIGNAL_VIDEO, peer.Video())
+	}()
+}
+
+// in goroutine because of mutex, changes that are outdated when they run are skipped
+func (peer *WebRTCPeerCtx) notifyVideoChanged(videoID string) {
+	seq := peer.videoChangedSeq.Add(1)
+
+	go func() {
+		peer.videoChangedMu.Lock()
+		defer peer.videoChangedMu.Unlock()
+
+		if peer.videoChangedSeq.Load() != seq {
+			return
+		}
+
+		peer.videoChanged(peer, videoID)
+	}()
+}
+
+// area is set only if the video was not changed in the meantime,
+// returns true if the area was changed
+func (peer *WebRTCPeerCtx) setVideoArea(videoID string, area *image.Rectangle) bool {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	stream, ok := peer.videoTrack.Stream()
+	if !ok || stream.ID() != videoID {
+		return false
+	}
+
+	if area == peer.videoArea || (area != nil && peer.videoArea != nil && *area == *peer.videoArea) {
+		return false
+	}
+
+	peer.videoArea = area
+	return true
+}
+
 func (peer *WebRTCPeerCtx) Video() types.PeerVideo {
 	peer.mu.Lock()
 	defer peer.mu.Unlock()
@@ -446,14 +549,52 @@ func (peer *WebRTCPeerCtx) SetAudio(r types.PeerAudioRequest) error {
 		// update only if changed
 		if peer.audioDisabled != disabled {
 			peer.audioDisabled = disabled
-			peer.audioTrack.SetPaused(disabled || peer.paused)
+			peer.updateAudioPaused()
 
 			peer.logger.Info().Bool("disabled", disabled).Msg("set audio disabled")
 			modified = true
 		}
 	}
 
-	// send video signal if modified
+	// audio selector and volume
+	if r.Selector != nil || r.Volume != nil {
+		selector := types.StreamSelector{ID: peer.audioID}
+		if r.Selector != nil {
+			selector = *r.Selector
+		}
+
+		volume := peer.audioVolume
+		if r.Volume != nil {
+			volume = min(max(*r.Volume, 0), 200)
+		}
+
+		// get requested audio stream from selector, volume is set to the applied one
+		stream, audioID, volume, ok := peer.audio.GetStream(selector, volume)
+		if !ok {
+			return types.ErrWebRTCStreamNotFound
+		}
+
+		// set audio stream to track
+		changed, err := peer.audioTrack.SetStream(stream)
+		if err != nil {
+			return err
+		}
+
+		// update only if stream or volume changed
+		if changed || peer.audioVolume != volume {
+			peer.audioID = audioID
+			peer.audioVolume = volume
+			peer.updateAudioPaused()
+
+			peer.logger.Info().
+				Str("audio_id", audioID).
+				Int("volume", volume).
+				Msg("set audio")
+			modified = true
+		}
+	}
+
+	// send audio signal if modified
 	if modified {
 		go func() {
 			// in goroutine because of mutex and we don't want to block
@@ -464,15 +605,46 @@ func (peer *WebRTCPeerCtx) SetAudio(r types.PeerAudioRequest) error {
 	return nil
 }
 
+func (peer *WebRTCPeerCtx) SetAudioMuted(muted bool) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if peer.audioMuted == muted {
+		return
+	}
+
+	peer.audioMuted = muted
+	peer.updateAudioPaused()
+
+	peer.logger.Info().Bool("muted", muted).Msg("set audio muted")
+
+	go func() {
+		// in goroutine because of mutex and we don't want to block
+		peer.session.Send(event.SIGNAL_AUDIO, peer.Audio())
+	}()
+}
+
+// audio is not sent when paused, disabled, muted or silent
+func (peer *WebRTCPeerCtx) updateAudioPaused() {
+	peer.audioTrack.SetPaused(peer.paused || peer.audioDisabled || peer.audioMuted || peer.audioVolume == 0)
+}
+
 func (peer *WebRTCPeerCtx) Audio() types.PeerAudio {
 	peer.mu.Lock()
 	defer peer.mu.Unlock()
 
 	return types.PeerAudio{
 		Disabled: peer.audioDisabled,
+		Muted:    peer.audioMuted,
+		ID:       peer.audioID,
+		Volume:   peer.audioVolume,
 	}
 }
 
+func (peer *WebRTCPeerCtx) Latency() types.PeerLatency {
+	return peer.metrics.Latency()
+}
+
 //
 // data channel
 //
@@ -486,6 +658,8 @@ func (peer *WebRTCPeerCtx) SendCursorPosition(x, y int) error {
 		return nil
 	}
 
+	x, y = screenToVideoArea(peer.videoArea, x, y)
+
 	header := payload.Header{
 		Event:  payload.OP_CURSOR_POSITION,
 		Length: 7,
@@ -509,6 +683,18 @@ func (peer *WebRTCPeerCtx) SendCursorPosition(x, y int) error {
 	return peer.dataChannel.Send(buffer.Bytes())
 }
 
+// position on the screen is translated to the position in the video area,
+// cursor outside of the area is shown on its edge
+func screenToVideoArea(area *image.Rectangle, x, y int) (int, int) {
+	if area == nil {
+		return x, y
+	}
+
+	x = min(max(x, area.Min.X), area.Max.X-1)
+	y = min(max(y, area.Min.Y), area.Max.Y-1)
+	return x - area.Min.X, y - area.Min.Y
+}
+
 func (peer *WebRTCPeerCtx) SendCursorImage(cur *types.CursorImage, img []byte) error {
 	peer.mu.Lock()
 	defer peer.mu.Unlock()
@@ -541,3 +727,74 @@ func (peer *WebRTCPeerCtx) SendCursorImage(cur *types.CursorImage, img []byte) e
 
 	return peer.dataChannel.Send(buffer.Bytes())
 }
+
+// must be called with peer lock held
+func (peer *WebRTCPeerCtx) dataChannelSupports(name string, version uint8) bool {
+	return peer.dataChannelCaps[name] >= version
+}
+
+// SupportsDataChannel returns whether client supports given versioned message.
+func (peer *WebRTCPeerCtx) SupportsDataChannel(name string, version uint8) bool {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	return peer.dataChannelSupports(name, version)
+}
+
+func (peer *WebRTCPeerCtx) SetDataChannelCapabilities(capabilities types.DataChannelCapabilities) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	peer.dataChannelCaps = capabilities
+}
+
+// SendClipboardText sends clipboard text in chunks, if client supports it.
+func (peer *WebRTCPeerCtx) SendClipboardText(text string) error {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if !peer.dataChannelSupports("clipboard", payload.CLIPBOARD_VERSION) {
+		return types.ErrWebRTCDataChannelUnsupported
+	}
+
+	chunks := clipboardChunks([]byte(text))
+	for i, chunk := range chunks {
+		header := payload.Header{
+			Event:  payload.OP_CLIPBOARD_UPDATE,
+			Length: uint16(8 + len(chunk)),
+		}
+
+		version := payload.Version{
+			Version: payload.CLIPBOARD_VERSION,
+		}
+
+		data := payload.Clipboard{
+			Index: uint16(i),
+			Count: uint16(len(chunks)),
+		}
+
+		buffer := &bytes.Buffer{}
+
+		if err := binary.Write(buffer, binary.BigEndian, header); err != nil {
+			return err
+		}
+
+		if err := binary.Write(buffer, binary.BigEndian, version); err != nil {
+			return err
+		}
+
+		if err := binary.Write(buffer, binary.BigEndian, data); err != nil {
+			return err
+		}
+
+		if err := binary.Write(buffer, binary.BigEndian, chunk); err != nil {
+			return err
+		}
+
+		if err := peer.dataChannel.Send(buffer.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package webrtc
+
+import (
+	"image"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPeerNotifyVideoChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		videoIDs []string
+		want     []string
+	}{
+		{
+			name:     "single change",
+			videoIDs: []string{"hd"},
+			want:     []string{"hd"},
+		},
+		{
+			name:     "only the latest pending change is applied",
+			videoIDs: []string{"hd", "sd", "output/DP-1"},
+			want:     []string{"output/DP-1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			applied := []string{}
+			done := make(chan struct{})
+
+			peer := &WebRTCPeerCtx{
+				videoChanged: func(peer *WebRTCPeerCtx, videoID string) {
+					mu.Lock()
+					applied = append(applied, videoID)
+					mu.Unlock()
+
+					if videoID == tt.videoIDs[len(tt.videoIDs)-1] {
+						close(done)
+					}
+				},
+			}
+
+			// changes are pending until all of them are requested
+			peer.videoChangedMu.Lock()
+			for _, videoID := range tt.videoIDs {
+				peer.notifyVideoChanged(videoID)
+			}
+			peer.videoChangedMu.Unlock()
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("latest change was not applied")
+			}
+
+			// outdated changes must not be applied after the latest one
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if !reflect.DeepEqual(applied, tt.want) {
+				t.Errorf("applied changes = %v, want %v", applied, tt.want)
+			}
+		})
+	}
+}
+
+func TestScreenToVideoArea(t *testing.T) {
+	output := image.Rect(1920, 0, 3200, 720)
+
+	tests := []struct {
+		name  string
+		area  *image.Rectangle
+		x, y  int
+		wantX int
+		wantY int
+	}{
+		{
+			name:  "whole screen",
+			x:     2000,
+			y:     100,
+			wantX: 2000,
+			wantY: 100,
+		},
+		{
+			name:  "inside of area",
+			area:  &output,
+			x:     2000,
+			y:     100,
+			wantX: 80,
+			wantY: 100,
+		},
+		{
+			name:  "top left corner of area",
+			area:  &output,
+			x:     1920,
+			y:     0,
+			wantX: 0,
+			wantY: 0,
+		},
+		{
+			name:  "left of area",
+			area:  &output,
+			x:     100,
+			y:     100,
+			wantX: 0,
+			wantY: 100,
+		},
+		{
+			name:  "below and right of area",
+			area:  &output,
+			x:     3500,
+			y:     1000,
+			wantX: 1279,
+			wantY: 719,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x, y := screenToVideoArea(tt.area, tt.x, tt.y)
+			if x != tt.wantX || y != tt.wantY {
+				t.Errorf("screenToVideoArea() = (%v, %v), want (%v, %v)", x, y, tt.wantX, tt.wantY)
+			}
+		})
+	}
+}
@@ -18,8 +18,9 @@ type Track struct {
 	logger zerolog.Logger
 	track  *webrtc.TrackLocalStaticSample
 
-	rtcpCh chan []rtcp.Packet
-	sample chan types.Sample
+	rtcpCh      chan []rtcp.Packet
+	sample      chan types.Sample
+	captureTime *captureTimeInterceptor
 
 	paused   bool
 	stream   types.StreamSinkManager
@@ -34,6 +35,12 @@ func WithRtcpChan(rtcp chan []rtcp.Packet) trackOption {
 	}
 }
 
+func WithCaptureTime(captureTime *captureTimeInterceptor) trackOption {
+	return func(t *Track) {
+		t.captureTime = captureTime
+	}
+}
+
 func NewTrack(logger zerolog.Logger, codec codec.RTPCodec, connection *webrtc.PeerConnection, opts ...trackOption) (*Track, error) {
 	id := codec.Type.String()
 	track, err := webrtc.NewTrackLocalStaticSample(codec.Capability, id, "stream")
@@ -97,6 +104,10 @@ func (t *Track) sampleReader() {
 			return
 		}
 
+		if t.captureTime != nil {
+			t.captureTime.SetCaptureTime(sample.Timestamp)
+		}
+
 		err := t.track.WriteSample(media.Sample{
 			Data:      sample.Data,
 			Duration:  sample.Duration,
@@ -144,6 +155,19 @@ func (t *Track) SetStream(stream types.StreamSinkManager) (bool, error) {
 	return true, nil
 }
 
+// replace stream reference, when listener was already moved to another stream
+func (t *Track) ReplaceStream(from, to types.StreamSinkManager) bool {
+	t.streamMu.Lock()
+	defer t.streamMu.Unlock()
+
+	if t.stream != from {
+		return false
+	}
+
+	t.stream = to
+	return true
+}
+
 func (t *Track) RemoveStream() {
 	t.streamMu.Lock()
 	defer t.streamMu.Unlock()
@@ -0,0 +1,172 @@
+package webrtc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/codec"
+	"m1k1o/neko/pkg/utils"
+)
+
+// whip answer is sent with all candidates, gathering them must not take forever
+const whipGatherTimeout = 10 * time.Second
+
+var errWhipGatherTimeout = errors.New("ice gathering timed out")
+
+type whipPeer struct {
+	session    types.Session
+	connection *webrtc.PeerConnection
+}
+
+// CreateWhipPeer accepts WHIP offer and creates receive only peer, that pushes
+// incoming tracks to webcam and microphone stream sources.
+func (manager *WebRTCManagerCtx) CreateWhipPeer(session types.Session, offer string) (*webrtc.SessionDescription, string, error) {
+	// only codecs with configured pipeline can be negotiated
+	codecs := []codec.RTPCodec{}
+	codecs = append(codecs, manager.capture.Webcam().Codecs()...)
+	codecs = append(codecs, manager.capture.Microphone().Codecs()...)
+	if len(codecs) == 0 {
+		return nil, "", types.ErrWebRTCWhipNotEnabled
+	}
+
+	id, err := utils.NewUID(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	logger := manager.logger.With().
+		Str("session_id", session.ID()).
+		Str("whip_id", id).
+		Logger()
+
+	logger.Info().Msg("creating whip peer")
+
+	connection, _, err := manager.newPeerConnection(logger, codecs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// register peer before negotiation, so that it can be closed at any time
+	manager.whipPeersMu.Lock()
+	manager.whipPeers[id] = &whipPeer{
+		session:    session,
+		connection: connection,
+	}
+	manager.whipPeersMu.Unlock()
+
+	// remove peer when negotiation fails
+	failed := func(err error) (*webrtc.SessionDescription, string, error) {
+		manager.whipPeersMu.Lock()
+		delete(manager.whipPeers, id)
+		manager.whipPeersMu.Unlock()
+
+		_ = connection.Close()
+		return nil, "", err
+	}
+
+	connection.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
+		logger := logger.With().
+			Str("kind", track.Kind().String()).
+			Str("mime", track.Codec().RTPCodecCapability.MimeType).
+			Logger()
+
+		logger.Info().Msgf("received new remote track")
+
+		manager.handleRemoteTrack(logger, connection, track, receiver)
+	})
+
+	closed := make(chan struct{})
+	connection.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
+		switch state {
+		case webrtc.PeerConnectionStateDisconnected,
+			webrtc.PeerConnectionStateFailed:
+			if err := connection.Close(); err != nil {
+				logger.Err(err).Msg("failed to close whip peer")
+			}
+		case webrtc.PeerConnectionStateClosed:
+			manager.whipPeersMu.Lock()
+			delete(manager.whipPeers, id)
+			manager.whipPeersMu.Unlock()
+			close(closed)
+
+			logger.Info().Msg("whip peer closed")
+		}
+	})
+
+	err = connection.SetRemoteDescription(webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  offer,
+	})
+	if err != nil {
+		return failed(err)
+	}
+
+	answer, err := connection.CreateAnswer(nil)
+	if err != nil {
+		return failed(err)
+	}
+
+	// whip does not require trickle ice, so we wait for all candidates
+	gatherComplete := webrtc.GatheringCompletePromise(connection)
+
+	if err := connection.SetLocalDescription(answer); err != nil {
+		return failed(err)
+	}
+
+	select {
+	case <-gatherComplete:
+	case <-closed:
+		return nil, "", types.ErrWebRTCConnectionNotFound
+	case <-time.After(whipGatherTimeout):
+		return failed(errWhipGatherTimeout)
+	}
+
+	return connection.LocalDescription(), id, nil
+}
+
+// DestroyWhipPeer closes WHIP peer, it can be closed only by its creator or by an admin.
+func (manager *WebRTCManagerCtx) DestroyWhipPeer(session types.Session, id string) error {
+	manager.whipPeersMu.Lock()
+	peer, ok := manager.whipPeers[id]
+	manager.whipPeersMu.Unlock()
+
+	if !ok || (peer.session != session && !session.Profile().IsAdmin) {
+		return types.ErrWebRTCConnectionNotFound
+	}
+
+	return peer.connection.Close()
+}
+
+// DestroyWhipPeers closes all WHIP peers created by given session.
+func (manager *WebRTCManagerCtx) DestroyWhipPeers(session types.Session) {
+	manager.whipPeersMu.Lock()
+	peers := map[string]*whipPeer{}
+	for id, peer := range manager.whipPeers {
+		if peer.session == session {
+			peers[id] = peer
+		}
+	}
+	manager.whipPeersMu.Unlock()
+
+	for id, peer := range peers {
+		if err := peer.connection.Close(); err != nil {
+			manager.logger.Err(err).Str("whip_id", id).Msg("failed to close whip peer")
+		}
+	}
+}
+
+func (manager *WebRTCManagerCtx) destroyWhipPeers() {
+	manager.whipPeersMu.Lock()
+	peers := manager.whipPeers
+	manager.whipPeers = map[string]*whipPeer{}
+	manager.whipPeersMu.Unlock()
+
+	for id, peer := range peers {
+		if err := peer.connection.Close(); err != nil {
+			manager.logger.Err(err).Str("whip_id", id).Msg("failed to close whip peer")
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+
+	"m1k1o/neko/pkg/types/event"
+	"m1k1o/neko/pkg/types/message"
+)
+
+const (
+	// app logs are sent in batches, at most this often
+	appLogInterval = 250 * time.Millisecond
+	// logs over this count in a single batch are dropped
+	appLogBatchMax = 100
+)
+
+// output of launched apps is collected and sent in batches, so that chatty apps are not slowed down
+type appLogBatch struct {
+	mu      sync.Mutex
+	logs    message.SystemLogs
+	dropped int
+	timer   *time.Timer
+	flushFn func(logs message.SystemLogs)
+}
+
+func (b *appLogBatch) add(log message.SystemLog) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.logs) >= appLogBatchMax {
+		b.dropped++
+		return
+	}
+
+	b.logs = append(b.logs, log)
+
+	if b.timer == nil {
+		b.timer = time.AfterFunc(appLogInterval, b.flush)
+	}
+}
+
+func (b *appLogBatch) flush() {
+	b.mu.Lock()
+	logs, dropped := b.logs, b.dropped
+	b.logs, b.dropped, b.timer = nil, 0, nil
+	b.mu.Unlock()
+
+	if dropped > 0 {
+		logs = append(logs, message.SystemLog{
+			Level: "warn",
+			Fields: map[string]any{
+				"dropped": dropped,
+			},
+			Message: "too many app logs, some were dropped",
+		})
+	}
+
+	b.flushFn(logs)
+}
+
+// output of launched apps is streamed to admins
+func (manager *WebSocketManagerCtx) appLogEvents() {
+	batch := &appLogBatch{
+		flushFn: func(logs message.SystemLogs) {
+			manager.sessions.AdminBroadcast(event.SYSTEM_LOGS, logs)
+		},
+	}
+
+	manager.desktop.OnAppLog(func(name, stream, line string) {
+		level := "info"
+		if stream == "stderr" {
+			level = "warn"
+		}
+
+		batch.add(message.SystemLog{
+			Level: level,
+			Fields: map[string]any{
+				"app":    name,
+				"stream": stream,
+			},
+			Message: line,
+		})
+	})
+}
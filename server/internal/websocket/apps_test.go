@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"testing"
+
+	"m1k1o/neko/pkg/types/message"
+)
+
+func TestAppLogBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		want  int
+	}{
+		{
+			name:  "single log",
+			count: 1,
+			want:  1,
+		},
+		{
+			name:  "full batch",
+			count: appLogBatchMax,
+			want:  appLogBatchMax,
+		},
+		{
+			name:  "dropped logs are reported",
+			count: appLogBatchMax + 10,
+			want:  appLogBatchMax + 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flushed := make(chan message.SystemLogs, 1)
+			batch := &appLogBatch{
+				flushFn: func(logs message.SystemLogs) {
+					flushed <- logs
+				},
+			}
+
+			for i := 0; i < tt.count; i++ {
+				batch.add(message.SystemLog{Message: "line"})
+			}
+
+			if logs := <-flushed; len(logs) != tt.want {
+				t.Errorf("flushed %d logs, want %d", len(logs), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"errors"
+
+	"m1k1o/neko/pkg/types"
+	"m1k1o/neko/pkg/types/event"
+	"m1k1o/neko/pkg/types/message"
+)
+
+func (h *MessageHandlerCtx) captureEncoder(session types.Session, payload *message.CaptureEncoder) error {
+	if !session.Profile().IsAdmin {
+		return errors.New("is not the admin")
+	}
+
+	stream, ok := h.capture.Video().GetStream(types.StreamSelector{
+		ID: payload.VideoID,
+	})
+	if !ok {
+		return errors.New("video stream not found")
+	}
+
+	if err := stream.SetEncoderParams(payload.EncoderParams); err != nil {
+		return err
+	}
+
+	h.sessions.AdminBroadcast(
+		event.CAPTURE_ENCODER,
+		message.CaptureEncoder{
+			VideoID:       stream.ID(),
+			EncoderParams: stream.EncoderParams(),
+		})
+
+	return nil
+}
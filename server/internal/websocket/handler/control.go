@@ -76,7 +76,7 @@ func (h *MessageHandlerCtx) controlMove(session types.Session, payload *message.
 
 	// handle active cursor movement
 	h.desktop.Move(payload.X, payload.Y)
-	h.webrtc.SetCursorPosition(payload.X, payload.Y)
+	h.webrtc.SetCursorPosition(h.desktop.InputToScreen(payload.X, payload.Y))
 	return nil
 }
 
@@ -188,6 +188,14 @@ func (h *MessageHandlerCtx) controlTouchEnd(session types.Session, payload *mess
 	return h.desktop.TouchEnd(payload.TouchId, payload.X, payload.Y, payload.Pressure)
 }
 
+func (h *MessageHandlerCtx) controlType(session types.Session, payload *message.ControlType) error {
+	if err := h.controlRequest(session); err != nil && !errors.Is(err, ErrIsAlreadyTheHost) {
+		return err
+	}
+
+	return h.desktop.TypeText(payload.Text)
+}
+
 func (h *MessageHandlerCtx) controlCut(session types.Session) error {
 	if err := h.controlRequest(session); err != nil && !errors.Is(err, ErrIsAlreadyTheHost) {
 		return err
@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"sync"
+	"time"
+
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
@@ -22,6 +25,9 @@ func New(
 		desktop:  desktop,
 		capture:  capture,
 		webrtc:   webrtc,
+
+		keyboardMaps: map[string]types.KeyboardMap{},
+		viewports:    map[string]types.ScreenSize{},
 	}
 }
 
@@ -31,9 +37,37 @@ type MessageHandlerCtx struct {
 	webrtc   types.WebRTCManager
 	desktop  types.DesktopManager
 	capture  types.CaptureManager
+
+	// keyboard maps of sessions, applied when they become host
+	keyboardMaps   map[string]types.KeyboardMap
+	keyboardMapsMu sync.Mutex
+
+	// viewports of sessions, screen is fitted to the one of host
+	viewports   map[string]types.ScreenSize
+	viewportsMu sync.Mutex
+	screenFit   *time.Timer
+}
+
+// events that send input to the desktop
+func isInputEvent(name string) bool {
+	switch name {
+	case event.CONTROL_MOVE, event.CONTROL_SCROLL,
+		event.CONTROL_BUTTONPRESS, event.CONTROL_BUTTONDOWN, event.CONTROL_BUTTONUP,
+		event.CONTROL_KEYPRESS, event.CONTROL_KEYDOWN, event.CONTROL_KEYUP, event.CONTROL_TYPE,
+		event.CONTROL_TOUCHBEGIN, event.CONTROL_TOUCHUPDATE, event.CONTROL_TOUCHEND,
+		event.CONTROL_CUT, event.CONTROL_COPY, event.CONTROL_PASTE, event.CONTROL_SELECT_ALL:
+		return true
+	}
+	return false
 }
 
 func (h *MessageHandlerCtx) Message(session types.Session, data types.WebSocketMessage) bool {
+	// input is ignored while it is locked, e.g. by input sequence
+	if isInputEvent(data.Event) && h.desktop.InputLocked() {
+		h.logger.Debug().Str("event", data.Event).Str("session_id", session.ID()).Msg("input is locked, ignoring")
+		return true
+	}
+
 	var err error
 	switch data.Event {
 	// System Events
@@ -122,6 +156,11 @@ func (h *MessageHandlerCtx) Message(session types.Session, data types.WebSocketM
 		err = utils.Unmarshal(payload, data.Payload, func() error {
 			return h.controlKeyUp(session, payload)
 		})
+	case event.CONTROL_TYPE:
+		payload := &message.ControlType{}
+		err = utils.Unmarshal(payload, data.Payload, func() error {
+			return h.controlType(session, payload)
+		})
 	// touch
 	case event.CONTROL_TOUCHBEGIN:
 		payload := &message.ControlTouch{}
@@ -157,6 +196,11 @@ func (h *MessageHandlerCtx) Message(session types.Session, data types.WebSocketM
 		err = utils.Unmarshal(payload, data.Payload, func() error {
 			return h.screenSet(session, payload)
 		})
+	case event.SCREEN_VIEWPORT:
+		payload := &message.ScreenSize{}
+		err = utils.Unmarshal(payload, data.Payload, func() error {
+			return h.screenViewport(session, payload)
+		})
 
 	// Clipboard Events
 	case event.CLIPBOARD_SET:
@@ -177,6 +221,13 @@ func (h *MessageHandlerCtx) Message(session types.Session, data types.WebSocketM
 			return h.keyboardModifiers(session, payload)
 		})
 
+	// Capture Events
+	case event.CAPTURE_ENCODER:
+		payload := &message.CaptureEncoder{}
+		err = utils.Unmarshal(payload, data.Payload, func() error {
+			return h.captureEncoder(session, payload)
+		})
+
 	// Send Events
 	case event.SEND_UNICAST:
 		payload := &message.SendUnicast{}
@@ -8,13 +8,45 @@ import (
 )
 
 func (h *MessageHandlerCtx) keyboardMap(session types.Session, payload *message.KeyboardMap) error {
+	// remember layout of every session, so that it can be used once it becomes host
+	if payload.Layout != "" {
+		h.keyboardMapsMu.Lock()
+		h.keyboardMaps[session.ID()] = types.KeyboardMap{
+			Layout:  payload.Layout,
+			Variant: payload.Variant,
+		}
+		h.keyboardMapsMu.Unlock()
+	}
+
 	if !session.IsHost() {
-		return errors.New("is not the host")
+		return nil
 	}
 
 	return h.desktop.SetKeyboardMap(payload.KeyboardMap)
 }
 
+// switch to keyboard layout of the new host
+func (h *MessageHandlerCtx) SessionHostChanged(host types.Session) error {
+	if host == nil {
+		return nil
+	}
+
+	h.keyboardMapsMu.Lock()
+	keyboardMap, ok := h.keyboardMaps[host.ID()]
+	h.keyboardMapsMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	current, err := h.desktop.GetKeyboardMap()
+	if err == nil && current.Layout == keyboardMap.Layout && current.Variant == keyboardMap.Variant {
+		return nil
+	}
+
+	return h.desktop.SetKeyboardMap(keyboardMap)
+}
+
 func (h *MessageHandlerCtx) keyboardModifiers(session types.Session, payload *message.KeyboardModifiers) error {
 	if !session.IsHost() {
 		return errors.New("is not the host")
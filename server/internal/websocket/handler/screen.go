@@ -2,12 +2,17 @@ package handler
 
 import (
 	"errors"
+	"time"
 
 	"m1k1o/neko/pkg/types"
 	"m1k1o/neko/pkg/types/event"
 	"m1k1o/neko/pkg/types/message"
 )
 
+// viewport changes continuously while browser window is resized,
+// screen is fitted only after it settles
+const screenFitDelay = 500 * time.Millisecond
+
 func (h *MessageHandlerCtx) screenSet(session types.Session, payload *message.ScreenSize) error {
 	if !session.Profile().IsAdmin {
 		return errors.New("is not the admin")
@@ -24,3 +29,87 @@ func (h *MessageHandlerCtx) screenSet(session types.Session, payload *message.Sc
 	})
 	return nil
 }
+
+func (h *MessageHandlerCtx) screenViewport(session types.Session, payload *message.ScreenSize) error {
+	if payload.Width <= 0 || payload.Height <= 0 {
+		return errors.New("invalid viewport size")
+	}
+
+	// remember viewport of every session, so that it can be used once it becomes host
+	h.viewportsMu.Lock()
+	h.viewports[session.ID()] = payload.ScreenSize
+	h.viewportsMu.Unlock()
+
+	if !session.IsHost() {
+		return nil
+	}
+
+	h.ScreenFitHost(session)
+	return nil
+}
+
+// resize screen to viewport of the host, if auto fit is enabled
+func (h *MessageHandlerCtx) ScreenFitHost(host types.Session) {
+	if host == nil || !h.sessions.Settings().ScreenAutoFit {
+		return
+	}
+
+	h.viewportsMu.Lock()
+	defer h.viewportsMu.Unlock()
+
+	if _, ok := h.viewports[host.ID()]; !ok {
+		return
+	}
+
+	if h.screenFit != nil {
+		h.screenFit.Stop()
+	}
+
+	h.screenFit = time.AfterFunc(screenFitDelay, func() {
+		if err := h.screenFitApply(host); err != nil {
+			h.logger.Err(err).
+				Str("session_id", host.ID()).
+				Msg("failed to fit screen to host viewport")
+		}
+	})
+}
+
+func (h *MessageHandlerCtx) screenFitApply(host types.Session) error {
+	// host or settings could have changed in the meantime
+	if !host.IsHost() || !h.sessions.Settings().ScreenAutoFit {
+		return nil
+	}
+
+	h.viewportsMu.Lock()
+	viewport, ok := h.viewports[host.ID()]
+	h.viewportsMu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	sizeMin, sizeMax := h.desktop.ScreenSizeBounds()
+	current := h.desktop.GetScreenSize()
+
+	// width is rounded to 8, because of Xorg
+	size := types.ScreenSize{
+		Width:  min(max(viewport.Width-viewport.Width%8, sizeMin.Width), sizeMax.Width),
+		Height: min(max(viewport.Height, sizeMin.Height), sizeMax.Height),
+		Rate:   min(max(current.Rate, sizeMin.Rate), sizeMax.Rate),
+	}
+
+	if size == current {
+		return nil
+	}
+
+	size, err := h.desktop.SetScreenSize(size)
+	if err != nil {
+		return err
+	}
+
+	h.sessions.Broadcast(event.SCREEN_UPDATED, message.ScreenSizeUpdate{
+		ID:         host.ID(),
+		ScreenSize: size,
+	})
+	return nil
+}
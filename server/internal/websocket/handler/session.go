@@ -19,6 +19,14 @@ func (h *MessageHandlerCtx) SessionCreated(session types.Session) error {
 }
 
 func (h *MessageHandlerCtx) SessionDeleted(session types.Session) error {
+	h.keyboardMapsMu.Lock()
+	delete(h.keyboardMaps, session.ID())
+	h.keyboardMapsMu.Unlock()
+
+	h.viewportsMu.Lock()
+	delete(h.viewports, session.ID())
+	h.viewportsMu.Unlock()
+
 	h.sessions.Broadcast(
 		event.SESSION_DELETED,
 		message.SessionID{
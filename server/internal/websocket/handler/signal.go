@@ -25,11 +25,22 @@ func (h *MessageHandlerCtx) signalRequest(session types.Session, payload *messag
 		peer.SetPaused(true)
 	}
 
+	peer.SetDataChannelCapabilities(payload.DataChannel)
+
+	// mute audio if it was muted by admin or for the whole room
+	if session.IsAudioMuted() {
+		peer.SetAudioMuted(true)
+	}
+
 	video := payload.Video
 
 	// use default first video, if not provided
 	if video.Selector == nil {
 		videos := h.capture.Video().IDs()
+		if len(videos) == 0 {
+			return errors.New("no video stream available")
+		}
+
 		video.Selector = &types.StreamSelector{
 			ID:   videos[0],
 			Type: types.StreamSelectorTypeExact,
@@ -32,18 +32,36 @@ func (h *MessageHandlerCtx) systemInit(session types.Session) error {
 		}
 	}
 
+	video := h.capture.Video()
+
+	videoOutputs := map[string]string{}
+	videoRegions := map[string]types.ScreenRegion{}
+	for _, id := range video.IDs() {
+		if output := video.ScreenOutput(id); output != "" {
+			videoOutputs[id] = output
+		}
+		if region := video.ScreenRegion(id); !region.IsZero() {
+			videoRegions[id] = region
+		}
+	}
+
 	session.Send(
 		event.SYSTEM_INIT,
 		message.SystemInit{
 			SessionId:         session.ID(),
 			ControlHost:       controlHost,
 			ScreenSize:        h.desktop.GetScreenSize(),
+			ScreenOutputs:     h.desktop.ScreenOutputs(),
 			Sessions:          sessions,
 			Settings:          h.sessions.Settings(),
 			TouchEvents:       h.desktop.HasTouchSupport(),
 			ScreencastEnabled: h.capture.Screencast().Enabled(),
 			WebRTC: message.SystemWebRTC{
-				Videos: h.capture.Video().IDs(),
+				Videos:       video.IDs(),
+				Audios:       h.capture.Audios().IDs(),
+				DataChannel:  h.webrtc.DataChannelCapabilities(),
+				VideoOutputs: videoOutputs,
+				VideoRegions: videoRegions,
 			},
 		})
 
@@ -62,15 +80,15 @@ func (h *MessageHandlerCtx) systemAdmin(session types.Session) error {
 		})
 	}
 
-	broadcast := h.capture.Broadcast()
+	sizeMin, sizeMax := h.desktop.ScreenSizeBounds()
+
 	session.Send(
 		event.SYSTEM_ADMIN,
 		message.SystemAdmin{
 			ScreenSizesList: list, // TODO: remove
-			BroadcastStatus: message.BroadcastStatus{
-				IsActive: broadcast.Started(),
-				URL:      broadcast.Url(),
-			},
+			ScreenSizeMin:   sizeMin,
+			ScreenSizeMax:   sizeMax,
+			BroadcastStatus: h.capture.Broadcast().Status(),
 		})
 
 	return nil
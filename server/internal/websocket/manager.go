@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -50,6 +51,7 @@ func New(
 		shutdown: make(chan struct{}),
 		sessions: sessions,
 		desktop:  desktop,
+		capture:  capture,
 		handler:  handler.New(sessions, desktop, capture, webrtc),
 		handlers: []types.WebSocketHandler{},
 	}
@@ -61,6 +63,7 @@ type WebSocketManagerCtx struct {
 	shutdown chan struct{}
 	sessions types.SessionManager
 	desktop  types.DesktopManager
+	capture  types.CaptureManager
 	handler  *handler.MessageHandlerCtx
 	handlers []types.WebSocketHandler
 
@@ -124,6 +127,37 @@ func (manager *WebSocketManagerCtx) Start() {
 
 		manager.sessions.Broadcast(event.CONTROL_HOST, payload)
 
+		if err := manager.handler.SessionHostChanged(host); err != nil {
+			manager.logger.Err(err).
+				Str("host_id", payload.HostID).
+				Msg("failed to apply keyboard map of new host")
+		}
+
+		// input of the new host is mapped to the output or region it watches
+		output, region := "", types.ScreenRegion{}
+		if host != nil {
+			if peer := host.GetWebRTCPeer(); peer != nil {
+				videoID := peer.Video().ID
+				output = manager.capture.Video().ScreenOutput(videoID)
+				region = manager.capture.Video().ScreenRegion(videoID)
+			}
+		}
+
+		var err error
+		if output != "" {
+			err = manager.desktop.SetInputOutput(output)
+		} else {
+			err = manager.desktop.SetInputRegion(region)
+		}
+
+		if err != nil {
+			manager.logger.Err(err).
+				Str("host_id", payload.HostID).
+				Msg("failed to map input to screen output or region of new host")
+		}
+
+		manager.handler.ScreenFitHost(host)
+
 		manager.logger.Info().
 			Str("session_id", session.ID()).
 			Bool("has_host", payload.HasHost).
@@ -142,6 +176,13 @@ func (manager *WebSocketManagerCtx) Start() {
 			manager.stopInactiveCursors()
 		}
 
+		// fit screen to current host
+		if new.ScreenAutoFit && !old.ScreenAutoFit {
+			if host, ok := manager.sessions.GetHost(); ok {
+				manager.handler.ScreenFitHost(host)
+			}
+		}
+
 		manager.sessions.Broadcast(event.SYSTEM_SETTINGS, message.SystemSettingsUpdate{
 			ID:       session.ID(),
 			Settings: new,
@@ -154,6 +195,35 @@ func (manager *WebSocketManagerCtx) Start() {
 			Msg("settings changed")
 	})
 
+	manager.capture.OnScreenSizeReverted(func(size types.ScreenSize) {
+		manager.sessions.Broadcast(
+			event.SCREEN_UPDATED,
+			message.ScreenSizeUpdate{
+				ScreenSize: size,
+			})
+	})
+
+	manager.capture.OnStatusChanged(func(id string, status types.CapturePipelineStatus) {
+		manager.sessions.AdminBroadcast(
+			event.CAPTURE_STATUS,
+			message.CaptureStatus{
+				ID:                    id,
+				CapturePipelineStatus: status,
+			})
+
+		// broadcast outputs are reconnecting or recovered
+		if !strings.HasPrefix(id, "broadcast/") {
+			return
+		}
+
+		// status is read outside of the supervisor, that reported the change
+		go func() {
+			manager.sessions.AdminBroadcast(
+				event.BROADCAST_STATUS,
+				manager.capture.Broadcast().Status())
+		}()
+	})
+
 	manager.desktop.OnClipboardUpdated(func() {
 		host, hasHost := manager.sessions.GetHost()
 		if !hasHost || !host.Profile().CanAccessClipboard {
@@ -168,6 +238,18 @@ func (manager *WebSocketManagerCtx) Start() {
 			return
 		}
 
+		// prefer datachannel, when client supports it
+		if peer := host.GetWebRTCPeer(); peer != nil {
+			err := peer.SendClipboardText(data.Text)
+			if err == nil {
+				return
+			}
+
+			if !errors.Is(err, types.ErrWebRTCDataChannelUnsupported) {
+				manager.logger.Warn().Err(err).Msg("could not send clipboard over datachannel")
+			}
+		}
+
 		host.Send(
 			event.CLIPBOARD_UPDATED,
 			message.ClipboardData{
@@ -176,6 +258,9 @@ func (manager *WebSocketManagerCtx) Start() {
 			})
 	})
 
+	manager.windowEvents()
+	manager.appLogEvents()
+
 	if manager.desktop.IsFileChooserDialogEnabled() {
 		manager.fileChooserDialogEvents()
 	}
@@ -0,0 +1,32 @@
+package websocket
+
+import (
+	"m1k1o/neko/pkg/types/event"
+	"m1k1o/neko/pkg/types/message"
+)
+
+// window titles can be private, so they are sent only to sessions that can control windows
+func (manager *WebSocketManagerCtx) windowEvents() {
+	manager.desktop.OnWindowOpened(func(id uint32) {
+		// window properties are read outside of the event loop
+		go func() {
+			window, err := manager.desktop.Window(id)
+			if err != nil {
+				manager.logger.Debug().Err(err).Uint32("window_id", id).Msg("opened window not found")
+				return
+			}
+
+			manager.logger.Debug().Uint32("window_id", id).Str("title", window.Title).Msg("window opened")
+			manager.sessions.HostsBroadcast(event.WINDOW_OPENED, window)
+		}()
+	})
+
+	manager.desktop.OnWindowClosed(func(id uint32) {
+		manager.logger.Debug().Uint32("window_id", id).Msg("window closed")
+		go manager.sessions.HostsBroadcast(event.WINDOW_CLOSED, message.WindowID{ID: id})
+	})
+
+	manager.desktop.OnWindowFocused(func(id uint32) {
+		go manager.sessions.HostsBroadcast(event.WINDOW_FOCUSED, message.WindowID{ID: id})
+	})
+}
@@ -280,6 +280,47 @@ func TestCanAccessClipboardOnly(t *testing.T) {
 	}
 }
 
+func TestCanShareMediaOnly(t *testing.T) {
+	r1, _, err := rWithSession(types.MemberProfile{CanShareMedia: false})
+	if err != nil {
+		t.Errorf("could not create session %s", err.Error())
+		return
+	}
+
+	r2, _, err := rWithSession(types.MemberProfile{CanShareMedia: true})
+	if err != nil {
+		t.Errorf("could not create session %s", err.Error())
+		return
+	}
+
+	tests := []struct {
+		name    string
+		r       *http.Request
+		wantErr bool
+	}{
+		{
+			name:    "can not share media",
+			r:       r1,
+			wantErr: true,
+		},
+		{
+			name:    "can share media",
+			r:       r2,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CanShareMediaOnly(nil, tt.r)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CanShareMediaOnly() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+		})
+	}
+}
+
 func TestPluginsGenericOnly(t *testing.T) {
 	r1, _, err := rWithSession(types.MemberProfile{
 		Plugins: map[string]any{
@@ -1,20 +1,22 @@
 #include "gst.h"
 
-static void gstreamer_pipeline_log(GstPipelineCtx *ctx, char* level, const char* format, ...) {
+static void gstreamer_pipeline_log(int pipelineId, char* level, const char* format, ...) {
   va_list argptr;
   va_start(argptr, format);
   char buffer[100];
-  vsprintf(buffer, format, argptr);
+  vsnprintf(buffer, sizeof(buffer), format, argptr);
   va_end(argptr);
-  goPipelineLog(ctx->pipelineId, level, buffer);
+  goPipelineLog(pipelineId, level, buffer);
 }
 
+// bus watch gets only pipeline id, so that it never touches already freed context
 static gboolean gstreamer_bus_call(GstBus *bus, GstMessage *msg, gpointer user_data) {
-  GstPipelineCtx *ctx = (GstPipelineCtx *)user_data;
+  int pipelineId = GPOINTER_TO_INT(user_data);
 
   switch (GST_MESSAGE_TYPE(msg)) {
     case GST_MESSAGE_EOS: {
-      gstreamer_pipeline_log(ctx, "fatal", "end of stream");
+      gstreamer_pipeline_log(pipelineId, "fatal", "end of stream");
+      goPipelineMessage(pipelineId, PIPELINE_MESSAGE_EOS, GST_OBJECT_NAME(msg->src), "end of stream", 0, 0, 0, 0);
       break;
     }
 
@@ -22,11 +24,16 @@ static gboolean gstreamer_bus_call(GstBus *bus, GstMessage *msg, gpointer user_d
       GstState old_state, new_state;
       gst_message_parse_state_changed(msg, &old_state, &new_state, NULL);
 
-      gstreamer_pipeline_log(ctx, "debug",
+      gstreamer_pipeline_log(pipelineId, "debug",
         "element %s changed state from %s to %s",
           GST_OBJECT_NAME(msg->src),
           gst_element_state_get_name(old_state),
           gst_element_state_get_name(new_state));
+
+      // only state of the whole pipeline is reported
+      if (GST_IS_PIPELINE(msg->src)) {
+        goPipelineMessage(pipelineId, PIPELINE_MESSAGE_STATE_CHANGED, GST_OBJECT_NAME(msg->src), "", old_state, new_state, 0, 0);
+      }
       break;
     }
 
@@ -34,7 +41,7 @@ static gboolean gstreamer_bus_call(GstBus *bus, GstMessage *msg, gpointer user_d
       GstTagList *tags = NULL;
       gst_message_parse_tag(msg, &tags);
 
-      gstreamer_pipeline_log(ctx, "debug",
+      gstreamer_pipeline_log(pipelineId, "debug",
         "got tags from element %s",
           GST_OBJECT_NAME(msg->src));
 
@@ -47,26 +54,93 @@ static gboolean gstreamer_bus_call(GstBus *bus, GstMessage *msg, gpointer user_d
       gchar *dbg_info = NULL;
       gst_message_parse_error(msg, &err, &dbg_info);
 
-      gstreamer_pipeline_log(ctx, "error",
+      gstreamer_pipeline_log(pipelineId, "error",
         "error from element %s: %s",
           GST_OBJECT_NAME(msg->src), err->message);
-      gstreamer_pipeline_log(ctx, "warn",
+      gstreamer_pipeline_log(pipelineId, "warn",
         "debugging info: %s",
           (dbg_info) ? dbg_info : "none");
+      goPipelineMessage(pipelineId, PIPELINE_MESSAGE_ERROR, GST_OBJECT_NAME(msg->src), err->message, 0, 0, 0, 0);
 
       g_error_free(err);
       g_free(dbg_info);
       break;
     }
 
+    case GST_MESSAGE_WARNING: {
+      GError *err = NULL;
+      gchar *dbg_info = NULL;
+      gst_message_parse_warning(msg, &err, &dbg_info);
+
+      gstreamer_pipeline_log(pipelineId, "warn",
+        "warning from element %s: %s",
+          GST_OBJECT_NAME(msg->src), err->message);
+      goPipelineMessage(pipelineId, PIPELINE_MESSAGE_WARNING, GST_OBJECT_NAME(msg->src), err->message, 0, 0, 0, 0);
+
+      g_error_free(err);
+      g_free(dbg_info);
+      break;
+    }
+
+    case GST_MESSAGE_QOS: {
+      GstFormat format;
+      guint64 processed, dropped;
+      gst_message_parse_qos_stats(msg, &format, &processed, &dropped);
+
+      // only buffers are counted as frames
+      if (format == GST_FORMAT_BUFFERS) {
+        goPipelineMessage(pipelineId, PIPELINE_MESSAGE_QOS, GST_OBJECT_NAME(msg->src), "", 0, 0, processed, dropped);
+      }
+      break;
+    }
+
+    case GST_MESSAGE_LATENCY: {
+      gstreamer_pipeline_log(pipelineId, "debug",
+        "latency changed by element %s",
+          GST_OBJECT_NAME(msg->src));
+      goPipelineMessage(pipelineId, PIPELINE_MESSAGE_LATENCY, GST_OBJECT_NAME(msg->src), "", 0, 0, 0, 0);
+      break;
+    }
+
+    case GST_MESSAGE_ELEMENT: {
+      // only name of the structure is reported
+      const GstStructure *s = gst_message_get_structure(msg);
+      if (s != NULL) {
+        goPipelineMessage(pipelineId, PIPELINE_MESSAGE_ELEMENT, GST_OBJECT_NAME(msg->src), (char *) gst_structure_get_name(s), 0, 0, 0, 0);
+      }
+      break;
+    }
+
     default:
-      gstreamer_pipeline_log(ctx, "trace", "unknown message");
+      gstreamer_pipeline_log(pipelineId, "trace", "unknown message");
       break;
   }
 
   return TRUE;
 }
 
+// bus watches are dispatched on own context, so that the default
+// context stays free for other main loops in the process (e.g. gtk)
+static GMainContext *gstreamer_context = NULL;
+
+void gstreamer_main_context_init() {
+  gstreamer_context = g_main_context_new();
+}
+
+GMainContext *gstreamer_main_context() {
+  return gstreamer_context;
+}
+
+void gstreamer_main_loop_run() {
+  g_main_context_push_thread_default(gstreamer_context);
+
+  GMainLoop *loop = g_main_loop_new(gstreamer_context, FALSE);
+  g_main_loop_run(loop);
+  g_main_loop_unref(loop);
+
+  g_main_context_pop_thread_default(gstreamer_context);
+}
+
 GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GError **error) {
   GstElement *pipeline = gst_parse_launch(pipelineStr, error);
   if (pipeline == NULL) return NULL;
@@ -77,12 +151,35 @@ GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GEr
   ctx->pipeline = pipeline;
 
   GstBus *bus = gst_pipeline_get_bus(GST_PIPELINE(pipeline));
-  gst_bus_add_watch(bus, gstreamer_bus_call, ctx);
+  ctx->busWatch = gst_bus_create_watch(bus);
+  g_source_set_callback(ctx->busWatch, (GSourceFunc) gstreamer_bus_call, GINT_TO_POINTER(pipelineId), NULL);
+  g_source_attach(ctx->busWatch, gstreamer_context);
   gst_object_unref(bus);
 
   return ctx;
 }
 
+// time elapsed since the buffer was captured, according to pipeline clock
+static guint64 gstreamer_buffer_age(GstElement *element, GstSample *sample, GstBuffer *buffer) {
+  GstClockTime pts = GST_BUFFER_PTS(buffer);
+  if (!GST_CLOCK_TIME_IS_VALID(pts)) pts = GST_BUFFER_DTS(buffer);
+
+  GstSegment *segment = gst_sample_get_segment(sample);
+  if (!GST_CLOCK_TIME_IS_VALID(pts) || segment == NULL) return 0;
+
+  guint64 running_time = gst_segment_to_running_time(segment, GST_FORMAT_TIME, pts);
+  if (!GST_CLOCK_TIME_IS_VALID(running_time)) return 0;
+
+  GstClock *clock = gst_element_get_clock(element);
+  if (clock == NULL) return 0;
+
+  GstClockTime now = gst_clock_get_time(clock);
+  gst_object_unref(clock);
+
+  GstClockTime captured = gst_element_get_base_time(element) + running_time;
+  return now > captured ? now - captured : 0;
+}
+
 static GstFlowReturn gstreamer_send_new_sample_handler(GstElement *object, gpointer user_data) {
   GstPipelineCtx *ctx = (GstPipelineCtx *)user_data;
   GstSample *sample = NULL;
@@ -97,7 +194,8 @@ static GstFlowReturn gstreamer_send_new_sample_handler(GstElement *object, gpoin
       gst_buffer_extract_dup(buffer, 0, gst_buffer_get_size(buffer), &copy, &copy_size);
       goHandlePipelineBuffer(ctx->pipelineId, copy, copy_size,
         GST_BUFFER_DURATION(buffer),
-        GST_BUFFER_FLAG_IS_SET(buffer, GST_BUFFER_FLAG_DELTA_UNIT)
+        GST_BUFFER_FLAG_IS_SET(buffer, GST_BUFFER_FLAG_DELTA_UNIT),
+        gstreamer_buffer_age(object, sample, buffer)
       );
     }
     gst_sample_unref(sample);
@@ -125,6 +223,11 @@ void gstreamer_pipeline_pause(GstPipelineCtx *ctx) {
 }
 
 void gstreamer_pipeline_destory(GstPipelineCtx *ctx) {
+  // stop watching bus, eos sent below is not an error
+  g_source_destroy(ctx->busWatch);
+  g_source_unref(ctx->busWatch);
+  ctx->busWatch = NULL;
+
   // end appsrc, if exists
   if (ctx->appsrc) {
     gst_app_src_end_of_stream(GST_APP_SRC(ctx->appsrc));
@@ -157,6 +260,51 @@ void gstreamer_pipeline_push(GstPipelineCtx *ctx, void *buffer, int bufferLen) {
   }
 }
 
+// for pipelines with more appsrc elements, looked up by name
+void gstreamer_pipeline_push_to(GstPipelineCtx *ctx, char *srcName, void *buffer, int bufferLen) {
+  GstElement *src = gst_bin_get_by_name(GST_BIN(ctx->pipeline), srcName);
+  if (src == NULL) return;
+
+  gpointer p = g_memdup2(buffer, bufferLen);
+  GstBuffer *buf = gst_buffer_new_wrapped(p, bufferLen);
+  gst_app_src_push_buffer(GST_APP_SRC(src), buf);
+  gst_object_unref(src);
+}
+
+gboolean gstreamer_pipeline_has_element(GstPipelineCtx *ctx, char *binName) {
+  GstElement *el = gst_bin_get_by_name(GST_BIN(ctx->pipeline), binName);
+  if (el == NULL) return FALSE;
+
+  gst_object_unref(el);
+  return TRUE;
+}
+
+gboolean gstreamer_pipeline_get_prop_int(GstPipelineCtx *ctx, char *binName, char *prop, gint64 *value) {
+  GstElement *el = gst_bin_get_by_name(GST_BIN(ctx->pipeline), binName);
+  if (el == NULL) return FALSE;
+
+  GParamSpec *spec = g_object_class_find_property(G_OBJECT_GET_CLASS(el), prop);
+  if (spec == NULL) {
+    gst_object_unref(el);
+    return FALSE;
+  }
+
+  // property can be of any integer type
+  GValue current = G_VALUE_INIT;
+  GValue converted = G_VALUE_INIT;
+  g_value_init(&current, spec->value_type);
+  g_value_init(&converted, G_TYPE_INT64);
+
+  g_object_get_property(G_OBJECT(el), prop, &current);
+  gboolean ok = g_value_transform(&current, &converted);
+  if (ok) *value = g_value_get_int64(&converted);
+
+  g_value_unset(&current);
+  g_value_unset(&converted);
+  gst_object_unref(el);
+  return ok;
+}
+
 gboolean gstreamer_pipeline_set_prop_int(GstPipelineCtx *ctx, char *binName, char *prop, gint value) {
   GstElement *el = gst_bin_get_by_name(GST_BIN(ctx->pipeline), binName);
   if (el == NULL) return FALSE;
@@ -215,3 +363,57 @@ gboolean gstreamer_pipeline_emit_video_keyframe(GstPipelineCtx *ctx) {
 	GstEvent *keyFrameEvent = gst_video_event_new_downstream_force_key_unit(now, time, now, TRUE, 0);
 	return gst_element_send_event(GST_ELEMENT(ctx->pipeline), keyFrameEvent);
 }
+
+gboolean gstreamer_pipeline_query_latency(GstPipelineCtx *ctx, guint64 *latency) {
+  GstQuery *query = gst_query_new_latency();
+
+  gboolean ok = gst_element_query(ctx->pipeline, query);
+  if (ok) {
+    gboolean live;
+    GstClockTime min_latency, max_latency;
+    gst_query_parse_latency(query, &live, &min_latency, &max_latency);
+    *latency = min_latency;
+  }
+
+  gst_query_unref(query);
+  return ok;
+}
+
+static GstPadProbeReturn gstreamer_bytes_probe(GstPad *pad, GstPadProbeInfo *info, gpointer user_data) {
+  GstPipelineCtx *ctx = user_data;
+
+  if (GST_PAD_PROBE_INFO_TYPE(info) & GST_PAD_PROBE_TYPE_BUFFER) {
+    GstBuffer *buf = GST_PAD_PROBE_INFO_BUFFER(info);
+    g_atomic_pointer_add(&ctx->bytes, gst_buffer_get_size(buf));
+  } else if (GST_PAD_PROBE_INFO_TYPE(info) & GST_PAD_PROBE_TYPE_BUFFER_LIST) {
+    GstBufferList *list = GST_PAD_PROBE_INFO_BUFFER_LIST(info);
+    g_atomic_pointer_add(&ctx->bytes, gst_buffer_list_calculate_size(list));
+  }
+
+  return GST_PAD_PROBE_OK;
+}
+
+static gboolean gstreamer_bytes_probe_add(GstElement *element, GstPad *pad, gpointer user_data) {
+  gst_pad_add_probe(pad, GST_PAD_PROBE_TYPE_BUFFER | GST_PAD_PROBE_TYPE_BUFFER_LIST,
+    gstreamer_bytes_probe, user_data, NULL);
+  return TRUE;
+}
+
+// probes are removed together with the pipeline, before ctx is freed,
+// all sink pads are counted, so that sinks with request pads are supported
+gboolean gstreamer_pipeline_attach_bytes_counter(GstPipelineCtx *ctx, char *elementName) {
+  GstElement *el = gst_bin_get_by_name(GST_BIN(ctx->pipeline), elementName);
+  if (el == NULL) return FALSE;
+
+  gboolean ok = el->numsinkpads > 0;
+  if (ok) {
+    gst_element_foreach_sink_pad(el, gstreamer_bytes_probe_add, ctx);
+  }
+
+  gst_object_unref(el);
+  return ok;
+}
+
+guint64 gstreamer_pipeline_get_bytes(GstPipelineCtx *ctx) {
+  return (guint64) (gsize) g_atomic_pointer_get(&ctx->bytes);
+}
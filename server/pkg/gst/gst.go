@@ -26,14 +26,106 @@ var (
 	registry      *C.GstRegistry
 )
 
+// size of buffered bus messages, when full new informational messages are dropped
+const messagesBufferSize = 32
+
 func init() {
 	C.gst_init(nil, nil)
 	registry = C.gst_registry_get()
+
+	// bus watches are dispatched by own main loop, not the default one
+	C.gstreamer_main_context_init()
+	go C.gstreamer_main_loop_run()
+}
+
+// MainContext returns GMainContext dispatched by gstreamer main loop,
+// as unsafe pointer so that it can be passed to other cgo packages.
+func MainContext() unsafe.Pointer {
+	return unsafe.Pointer(C.gstreamer_main_context())
+}
+
+type MessageType int
+
+// must match PipelineMessageType in gst.h
+const (
+	MessageError MessageType = iota
+	MessageEOS
+	MessageWarning
+	MessageStateChanged
+	MessageQoS
+	MessageLatency
+	MessageElement
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageError:
+		return "error"
+	case MessageEOS:
+		return "eos"
+	case MessageWarning:
+		return "warning"
+	case MessageStateChanged:
+		return "state_changed"
+	case MessageQoS:
+		return "qos"
+	case MessageLatency:
+		return "latency"
+	case MessageElement:
+		return "element"
+	default:
+		return fmt.Sprintf("%d", int(t))
+	}
+}
+
+// matches GstState
+type State int
+
+const (
+	StateVoidPending State = iota
+	StateNull
+	StateReady
+	StatePaused
+	StatePlaying
+)
+
+func (s State) String() string {
+	switch s {
+	case StateVoidPending:
+		return "void_pending"
+	case StateNull:
+		return "null"
+	case StateReady:
+		return "ready"
+	case StatePaused:
+		return "paused"
+	case StatePlaying:
+		return "playing"
+	default:
+		return fmt.Sprintf("%d", int(s))
+	}
+}
+
+type Message struct {
+	Type MessageType
+	// name of element, that posted the message
+	Source string
+	// error or warning text, structure name of element message
+	Text string
+	// state of the pipeline, for state changed message
+	OldState State
+	NewState State
+	// frames processed and dropped by the element, for qos message
+	Processed uint64
+	Dropped   uint64
 }
 
 type Pipeline interface {
 	Src() string
 	Sample() chan types.Sample
+	// typed messages posted on the bus, informational ones are dropped when
+	// not read, errors and eos are delivered until the pipeline is destroyed
+	Messages() <-chan Message
 	// attach sink or src to pipeline
 	AttachAppsink(sinkName string)
 	AttachAppsrc(srcName string)
@@ -42,20 +134,34 @@ type Pipeline interface {
 	Pause()
 	Destroy()
 	Push(buffer []byte)
-	// modify the property of a bin
+	// push to appsrc with given name, it does not need to be attached
+	PushTo(srcName string, buffer []byte)
+	// query or modify the property of a bin
+	HasElement(binName string) bool
+	GetPropInt(binName string, prop string) (int, bool)
 	SetPropInt(binName string, prop string, value int) bool
 	SetCapsFramerate(binName string, numerator, denominator int) bool
 	SetCapsResolution(binName string, width, height int) bool
 	// emit video keyframe
 	EmitVideoKeyframe() bool
+	// minimal latency of a live pipeline
+	QueryLatency() (time.Duration, bool)
+	// count bytes received by all sink pads of an element
+	AttachBytesCounter(elementName string) bool
+	BytesCount() uint64
 }
 
 type pipeline struct {
-	id     int
-	logger zerolog.Logger
-	src    string
-	ctx    *C.GstPipelineCtx
-	sample chan types.Sample
+	id       int
+	logger   zerolog.Logger
+	src      string
+	ctx      *C.GstPipelineCtx
+	sample   chan types.Sample
+	messages chan Message
+
+	// closed when pipeline is destroyed, undelivered messages are discarded
+	destroyed chan struct{}
+	pending   sync.WaitGroup
 }
 
 func CreatePipeline(pipelineStr string) (Pipeline, error) {
@@ -81,9 +187,11 @@ func CreatePipeline(pipelineStr string) (Pipeline, error) {
 			Str("module", "capture").
 			Str("submodule", "gstreamer").
 			Int("pipeline_id", int(id)).Logger(),
-		src:    pipelineStr,
-		ctx:    ctx,
-		sample: make(chan types.Sample),
+		src:       pipelineStr,
+		ctx:       ctx,
+		sample:    make(chan types.Sample),
+		messages:  make(chan Message, messagesBufferSize),
+		destroyed: make(chan struct{}),
 	}
 
 	pipelines[p.id] = p
@@ -98,6 +206,10 @@ func (p *pipeline) Sample() chan types.Sample {
 	return p.sample
 }
 
+func (p *pipeline) Messages() <-chan Message {
+	return p.messages
+}
+
 func (p *pipeline) AttachAppsink(sinkName string) {
 	sinkNameUnsafe := C.CString(sinkName)
 	defer C.free(unsafe.Pointer(sinkNameUnsafe))
@@ -127,6 +239,11 @@ func (p *pipeline) Destroy() {
 	delete(pipelines, p.id)
 	pipelinesLock.Unlock()
 
+	// wait for pending messages, before closing their channel
+	close(p.destroyed)
+	p.pending.Wait()
+	close(p.messages)
+
 	close(p.sample)
 	C.free(unsafe.Pointer(p.ctx))
 }
@@ -138,6 +255,36 @@ func (p *pipeline) Push(buffer []byte) {
 	C.gstreamer_pipeline_push(p.ctx, bytes, C.int(len(buffer)))
 }
 
+func (p *pipeline) PushTo(srcName string, buffer []byte) {
+	srcNameUnsafe := C.CString(srcName)
+	defer C.free(unsafe.Pointer(srcNameUnsafe))
+
+	bytes := C.CBytes(buffer)
+	defer C.free(bytes)
+
+	C.gstreamer_pipeline_push_to(p.ctx, srcNameUnsafe, bytes, C.int(len(buffer)))
+}
+
+func (p *pipeline) HasElement(binName string) bool {
+	cBinName := C.CString(binName)
+	defer C.free(unsafe.Pointer(cBinName))
+
+	ok := C.gstreamer_pipeline_has_element(p.ctx, cBinName)
+	return ok == C.TRUE
+}
+
+func (p *pipeline) GetPropInt(binName string, prop string) (int, bool) {
+	cBinName := C.CString(binName)
+	defer C.free(unsafe.Pointer(cBinName))
+
+	cProp := C.CString(prop)
+	defer C.free(unsafe.Pointer(cProp))
+
+	var cValue C.gint64
+	ok := C.gstreamer_pipeline_get_prop_int(p.ctx, cBinName, cProp, &cValue)
+	return int(cValue), ok == C.TRUE
+}
+
 func (p *pipeline) SetPropInt(binName string, prop string, value int) bool {
 	cBinName := C.CString(binName)
 	defer C.free(unsafe.Pointer(cBinName))
@@ -184,6 +331,24 @@ func (p *pipeline) EmitVideoKeyframe() bool {
 	return ok == C.TRUE
 }
 
+func (p *pipeline) QueryLatency() (time.Duration, bool) {
+	var latency C.guint64
+	ok := C.gstreamer_pipeline_query_latency(p.ctx, &latency)
+	return time.Duration(latency), ok == C.TRUE
+}
+
+func (p *pipeline) AttachBytesCounter(elementName string) bool {
+	elementNameUnsafe := C.CString(elementName)
+	defer C.free(unsafe.Pointer(elementNameUnsafe))
+
+	ok := C.gstreamer_pipeline_attach_bytes_counter(p.ctx, elementNameUnsafe)
+	return ok == C.TRUE
+}
+
+func (p *pipeline) BytesCount() uint64 {
+	return uint64(C.gstreamer_pipeline_get_bytes(p.ctx))
+}
+
 // gst-inspect-1.0
 func CheckPlugins(plugins []string) error {
 	var plugin *C.GstPlugin
@@ -199,8 +364,27 @@ func CheckPlugins(plugins []string) error {
 	return nil
 }
 
+// gst-launch-1.0 syntax check, pipeline is parsed but never started
+func CheckPipeline(pipelineStr string) error {
+	pipelineStrUnsafe := C.CString(pipelineStr)
+	defer C.free(unsafe.Pointer(pipelineStrUnsafe))
+
+	var gstError *C.GError
+	element := C.gst_parse_launch(pipelineStrUnsafe, &gstError)
+	if element != nil {
+		C.gst_object_unref(C.gpointer(unsafe.Pointer(element)))
+	}
+
+	if gstError != nil {
+		defer C.g_error_free(gstError)
+		return fmt.Errorf("(pipeline error) %s", C.GoString(gstError.message))
+	}
+
+	return nil
+}
+
 //export goHandlePipelineBuffer
-func goHandlePipelineBuffer(pipelineID C.int, buf C.gpointer, bufLen C.int, duration C.guint64, deltaUnit C.gboolean) {
+func goHandlePipelineBuffer(pipelineID C.int, buf C.gpointer, bufLen C.int, duration C.guint64, deltaUnit C.gboolean, age C.guint64) {
 	defer C.g_free(buf)
 
 	pipelinesLock.Lock()
@@ -208,10 +392,13 @@ func goHandlePipelineBuffer(pipelineID C.int, buf C.gpointer, bufLen C.int, dura
 	pipelinesLock.Unlock()
 
 	if ok {
+		// capture time, including time spent in pipeline
+		timestamp := time.Now().Add(-time.Duration(age))
+
 		pipeline.sample <- types.Sample{
 			Data:      C.GoBytes(unsafe.Pointer(buf), bufLen),
 			Length:    int(bufLen),
-			Timestamp: time.Now(),
+			Timestamp: timestamp,
 			Duration:  time.Duration(duration),
 			DeltaUnit: deltaUnit == C.TRUE,
 		}
@@ -224,6 +411,52 @@ func goHandlePipelineBuffer(pipelineID C.int, buf C.gpointer, bufLen C.int, dura
 	}
 }
 
+//export goPipelineMessage
+func goPipelineMessage(pipelineID C.int, messageType C.int, srcUnsafe *C.char, msgUnsafe *C.char, oldState C.int, newState C.int, processed C.guint64, dropped C.guint64) {
+	message := Message{
+		Type:      MessageType(messageType),
+		Source:    C.GoString(srcUnsafe),
+		Text:      C.GoString(msgUnsafe),
+		OldState:  State(oldState),
+		NewState:  State(newState),
+		Processed: uint64(processed),
+		Dropped:   uint64(dropped),
+	}
+
+	pipelinesLock.Lock()
+	defer pipelinesLock.Unlock()
+
+	pipeline, ok := pipelines[int(pipelineID)]
+	if !ok {
+		return
+	}
+
+	select {
+	case pipeline.messages <- message:
+		return
+	default:
+	}
+
+	// errors and eos must not be lost, they are delivered once buffer has space
+	// without blocking the main loop, that dispatches messages of all pipelines
+	if message.Type == MessageError || message.Type == MessageEOS {
+		pipeline.pending.Add(1)
+		go func() {
+			defer pipeline.pending.Done()
+
+			select {
+			case pipeline.messages <- message:
+			case <-pipeline.destroyed:
+			}
+		}()
+		return
+	}
+
+	pipeline.logger.Warn().
+		Str("type", message.Type.String()).
+		Msg("discarding bus message, buffer is full")
+}
+
 //export goPipelineLog
 func goPipelineLog(pipelineID C.int, levelUnsafe *C.char, msgUnsafe *C.char) {
 	levelStr := C.GoString(levelUnsafe)
@@ -16,16 +16,34 @@
 #define g_memdup2 g_memdup
 #endif
 
+// must match MessageType in gst.go
+typedef enum {
+  PIPELINE_MESSAGE_ERROR,
+  PIPELINE_MESSAGE_EOS,
+  PIPELINE_MESSAGE_WARNING,
+  PIPELINE_MESSAGE_STATE_CHANGED,
+  PIPELINE_MESSAGE_QOS,
+  PIPELINE_MESSAGE_LATENCY,
+  PIPELINE_MESSAGE_ELEMENT,
+} PipelineMessageType;
+
 typedef struct GstPipelineCtx {
   int pipelineId;
   GstElement *pipeline;
   GstElement *appsink;
   GstElement *appsrc;
+  GSource *busWatch;
+  // bytes received by the counted element
+  gsize bytes;
 } GstPipelineCtx;
 
-extern void goHandlePipelineBuffer(int pipelineId, void *buffer, int bufferLen, guint64 duration, gboolean deltaUnit);
+extern void goHandlePipelineBuffer(int pipelineId, void *buffer, int bufferLen, guint64 duration, gboolean deltaUnit, guint64 age);
 extern void goPipelineLog(int pipelineId, char *level, char *msg);
+extern void goPipelineMessage(int pipelineId, int messageType, char *src, char *msg, int oldState, int newState, guint64 processed, guint64 dropped);
 
+void gstreamer_main_context_init();
+GMainContext *gstreamer_main_context();
+void gstreamer_main_loop_run();
 GstPipelineCtx *gstreamer_pipeline_create(char *pipelineStr, int pipelineId, GError **error);
 void gstreamer_pipeline_attach_appsink(GstPipelineCtx *ctx, char *sinkName);
 void gstreamer_pipeline_attach_appsrc(GstPipelineCtx *ctx, char *srcName);
@@ -33,8 +51,14 @@ void gstreamer_pipeline_play(GstPipelineCtx *ctx);
 void gstreamer_pipeline_pause(GstPipelineCtx *ctx);
 void gstreamer_pipeline_destory(GstPipelineCtx *ctx);
 void gstreamer_pipeline_push(GstPipelineCtx *ctx, void *buffer, int bufferLen);
+void gstreamer_pipeline_push_to(GstPipelineCtx *ctx, char *srcName, void *buffer, int bufferLen);
 
+gboolean gstreamer_pipeline_has_element(GstPipelineCtx *ctx, char *binName);
+gboolean gstreamer_pipeline_get_prop_int(GstPipelineCtx *ctx, char *binName, char *prop, gint64 *value);
 gboolean gstreamer_pipeline_set_prop_int(GstPipelineCtx *ctx, char *binName, char *prop, gint value);
 gboolean gstreamer_pipeline_set_caps_framerate(GstPipelineCtx *ctx, const gchar* binName, gint numerator, gint denominator);
 gboolean gstreamer_pipeline_set_caps_resolution(GstPipelineCtx *ctx, const gchar* binName, gint width, gint height);
 gboolean gstreamer_pipeline_emit_video_keyframe(GstPipelineCtx *ctx);
+gboolean gstreamer_pipeline_query_latency(GstPipelineCtx *ctx, guint64 *latency);
+gboolean gstreamer_pipeline_attach_bytes_counter(GstPipelineCtx *ctx, char *elementName);
+guint64 gstreamer_pipeline_get_bytes(GstPipelineCtx *ctx);
@@ -0,0 +1,89 @@
+#include "rtsp.h"
+
+// role allowed to access the media, when authentication is enabled
+#define RTSP_ROLE "viewer"
+
+// media is fed by appsrc with this name
+#define RTSP_APPSRC "appsrc"
+
+static void gstreamer_rtsp_media_unprepared(GstRTSPMedia *media, gpointer user_data) {
+  goRtspMediaUnprepared(GPOINTER_TO_INT(user_data));
+}
+
+// shared media is configured once, when first client connects
+static void gstreamer_rtsp_media_configure(GstRTSPMediaFactory *factory, GstRTSPMedia *media, gpointer user_data) {
+  GstElement *element = gst_rtsp_media_get_element(media);
+  GstElement *appsrc = gst_bin_get_by_name_recurse_up(GST_BIN(element), RTSP_APPSRC);
+  gst_object_unref(element);
+
+  g_signal_connect(media, "unprepared", G_CALLBACK(gstreamer_rtsp_media_unprepared), user_data);
+
+  // reference to appsrc is passed along
+  goRtspMediaConfigure(GPOINTER_TO_INT(user_data), appsrc);
+}
+
+void gstreamer_rtsp_push(GstElement *appsrc, void *buffer, int bufferLen) {
+  gpointer p = g_memdup2(buffer, bufferLen);
+  GstBuffer *buf = gst_buffer_new_wrapped(p, bufferLen);
+  gst_app_src_push_buffer(GST_APP_SRC(appsrc), buf);
+}
+
+GstRTSPServer *gstreamer_rtsp_server_create(int serverId, char *service, char *path, char *launch, char *username, char *password, GMainContext *context, guint *sourceId) {
+  GstRTSPServer *server = gst_rtsp_server_new();
+  gst_rtsp_server_set_service(server, service);
+
+  GstRTSPMediaFactory *factory = gst_rtsp_media_factory_new();
+  gst_rtsp_media_factory_set_launch(factory, launch);
+
+  // all clients share one pipeline, it is created for the first client
+  gst_rtsp_media_factory_set_shared(factory, TRUE);
+  g_signal_connect(factory, "media-configure", G_CALLBACK(gstreamer_rtsp_media_configure), GINT_TO_POINTER(serverId));
+
+  if (username != NULL) {
+    GstRTSPAuth *auth = gst_rtsp_auth_new();
+
+    GstRTSPToken *token = gst_rtsp_token_new(GST_RTSP_TOKEN_MEDIA_FACTORY_ROLE, G_TYPE_STRING, RTSP_ROLE, NULL);
+    gchar *basic = gst_rtsp_auth_make_basic(username, password);
+    gst_rtsp_auth_add_basic(auth, basic, token);
+    g_free(basic);
+    gst_rtsp_token_unref(token);
+
+    gst_rtsp_server_set_auth(server, auth);
+    g_object_unref(auth);
+
+    gst_rtsp_media_factory_add_role(factory, RTSP_ROLE,
+      GST_RTSP_PERM_MEDIA_FACTORY_ACCESS, G_TYPE_BOOLEAN, TRUE,
+      GST_RTSP_PERM_MEDIA_FACTORY_CONSTRUCT, G_TYPE_BOOLEAN, TRUE, NULL);
+  }
+
+  GstRTSPMountPoints *mounts = gst_rtsp_server_get_mount_points(server);
+  gst_rtsp_mount_points_add_factory(mounts, path, factory);
+  g_object_unref(mounts);
+
+  // served by gstreamer main loop
+  *sourceId = gst_rtsp_server_attach(server, context);
+  if (*sourceId == 0) {
+    g_object_unref(server);
+    return NULL;
+  }
+
+  return server;
+}
+
+static GstRTSPFilterResult gstreamer_rtsp_client_remove(GstRTSPServer *server, GstRTSPClient *client, gpointer user_data) {
+  return GST_RTSP_FILTER_REMOVE;
+}
+
+void gstreamer_rtsp_server_disconnect_clients(GstRTSPServer *server) {
+  gst_rtsp_server_client_filter(server, gstreamer_rtsp_client_remove, NULL);
+}
+
+void gstreamer_rtsp_server_destroy(GstRTSPServer *server, GMainContext *context, guint sourceId) {
+  GSource *source = g_main_context_find_source_by_id(context, sourceId);
+  if (source != NULL) {
+    g_source_destroy(source);
+  }
+
+  gstreamer_rtsp_server_disconnect_clients(server);
+  g_object_unref(server);
+}
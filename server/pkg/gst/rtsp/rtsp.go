@@ -0,0 +1,187 @@
+package rtsp
+
+/*
+#cgo pkg-config: gstreamer-1.0 gstreamer-app-1.0 gstreamer-rtsp-server-1.0
+
+#include "rtsp.h"
+*/
+import "C"
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"m1k1o/neko/pkg/gst"
+)
+
+var (
+	sSerial     int32
+	servers     = make(map[int]*Server)
+	serversLock sync.Mutex
+)
+
+// Server serves one media pipeline to RTSP clients, pipeline
+// is created when first client connects and shared by all of them.
+type Server struct {
+	id       int
+	mu       sync.Mutex
+	ctx      *C.GstRTSPServer
+	context  *C.GMainContext
+	sourceId C.guint
+
+	// appsrc of the shared media, while some clients are connected, it has own
+	// lock because media can be unprepared synchronously when clients are disconnected
+	appsrc    *C.GstElement
+	appsrcMu  sync.Mutex
+	clientsFn func(connected bool)
+}
+
+// launch is gst-launch syntax pipeline enclosed in brackets, with payloaders named pay0, pay1, ...
+// and appsrc named appsrc, that is fed by Push. clientsFn is called when first client connects,
+// so that data should be pushed, and when last client disconnects. when username is empty,
+// authentication is disabled.
+func NewServer(port int, path string, launch string, username string, password string, clientsFn func(connected bool)) (*Server, error) {
+	serviceUnsafe := C.CString(strconv.Itoa(port))
+	defer C.free(unsafe.Pointer(serviceUnsafe))
+
+	pathUnsafe := C.CString(path)
+	defer C.free(unsafe.Pointer(pathUnsafe))
+
+	launchUnsafe := C.CString(launch)
+	defer C.free(unsafe.Pointer(launchUnsafe))
+
+	var usernameUnsafe, passwordUnsafe *C.char
+	if username != "" {
+		usernameUnsafe = C.CString(username)
+		defer C.free(unsafe.Pointer(usernameUnsafe))
+
+		passwordUnsafe = C.CString(password)
+		defer C.free(unsafe.Pointer(passwordUnsafe))
+	}
+
+	// gstreamer is initialized and its main loop is running
+	context := (*C.GMainContext)(gst.MainContext())
+
+	s := &Server{
+		id:        int(atomic.AddInt32(&sSerial, 1)),
+		context:   context,
+		clientsFn: clientsFn,
+	}
+
+	// server must be registered before clients can connect
+	serversLock.Lock()
+	servers[s.id] = s
+	serversLock.Unlock()
+
+	var sourceId C.guint
+	ctx := C.gstreamer_rtsp_server_create(C.int(s.id), serviceUnsafe, pathUnsafe, launchUnsafe, usernameUnsafe, passwordUnsafe, context, &sourceId)
+	if ctx == nil {
+		serversLock.Lock()
+		delete(servers, s.id)
+		serversLock.Unlock()
+
+		return nil, fmt.Errorf("unable to attach rtsp server to port %d", port)
+	}
+
+	s.mu.Lock()
+	s.ctx = ctx
+	s.sourceId = sourceId
+	s.mu.Unlock()
+
+	return s, nil
+}
+
+// data is pushed to the shared media, if some clients are connected
+func (s *Server) Push(buffer []byte) {
+	s.appsrcMu.Lock()
+	defer s.appsrcMu.Unlock()
+
+	if s.appsrc == nil {
+		return
+	}
+
+	bytes := C.CBytes(buffer)
+	defer C.free(bytes)
+
+	C.gstreamer_rtsp_push(s.appsrc, bytes, C.int(len(buffer)))
+}
+
+// clients are disconnected, so that shared pipeline is destroyed
+func (s *Server) DisconnectClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx != nil {
+		C.gstreamer_rtsp_server_disconnect_clients(s.ctx)
+	}
+}
+
+func (s *Server) Close() {
+	serversLock.Lock()
+	delete(servers, s.id)
+	serversLock.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return
+	}
+
+	C.gstreamer_rtsp_server_destroy(s.ctx, s.context, s.sourceId)
+	s.ctx = nil
+
+	s.setAppsrc(nil)
+}
+
+func (s *Server) setAppsrc(appsrc *C.GstElement) {
+	s.appsrcMu.Lock()
+	defer s.appsrcMu.Unlock()
+
+	if s.appsrc != nil {
+		C.gst_object_unref(C.gpointer(unsafe.Pointer(s.appsrc)))
+	}
+
+	s.appsrc = appsrc
+}
+
+func getServer(serverId C.int) (*Server, bool) {
+	serversLock.Lock()
+	defer serversLock.Unlock()
+
+	s, ok := servers[int(serverId)]
+	return s, ok
+}
+
+//export goRtspMediaConfigure
+func goRtspMediaConfigure(serverId C.int, appsrc *C.GstElement) {
+	s, ok := getServer(serverId)
+	if !ok {
+		if appsrc != nil {
+			C.gst_object_unref(C.gpointer(unsafe.Pointer(appsrc)))
+		}
+		return
+	}
+
+	s.setAppsrc(appsrc)
+
+	if s.clientsFn != nil {
+		s.clientsFn(true)
+	}
+}
+
+//export goRtspMediaUnprepared
+func goRtspMediaUnprepared(serverId C.int) {
+	s, ok := getServer(serverId)
+	if !ok {
+		return
+	}
+
+	s.setAppsrc(nil)
+
+	if s.clientsFn != nil {
+		s.clientsFn(false)
+	}
+}
@@ -0,0 +1,18 @@
+#pragma once
+
+#include <gst/gst.h>
+#include <gst/app/gstappsrc.h>
+#include <gst/rtsp-server/rtsp-server.h>
+
+// g_memdup2 was added in glib 2.67.4, maintain compatibility with older versions
+#if !GLIB_CHECK_VERSION(2, 67, 4)
+#define g_memdup2 g_memdup
+#endif
+
+extern void goRtspMediaConfigure(int serverId, GstElement *appsrc);
+extern void goRtspMediaUnprepared(int serverId);
+
+GstRTSPServer *gstreamer_rtsp_server_create(int serverId, char *service, char *path, char *launch, char *username, char *password, GMainContext *context, guint *sourceId);
+void gstreamer_rtsp_server_disconnect_clients(GstRTSPServer *server);
+void gstreamer_rtsp_server_destroy(GstRTSPServer *server, GMainContext *context, guint sourceId);
+void gstreamer_rtsp_push(GstElement *appsrc, void *buffer, int bufferLen);
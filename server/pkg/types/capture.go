@@ -14,7 +14,14 @@ import (
 )
 
 var (
-	ErrCapturePipelineAlreadyExists = errors.New("capture pipeline already exists")
+	ErrCapturePipelineAlreadyExists    = errors.New("capture pipeline already exists")
+	ErrCapturePipelineNotFound         = errors.New("capture pipeline not found")
+	ErrCapturePipelineNotRunning       = errors.New("capture pipeline is not running")
+	ErrCapturePipelineLast             = errors.New("capture pipeline is the last one")
+	ErrCaptureEncoderParamNotSupported = errors.New("capture encoder param not supported")
+	ErrBroadcastOutputNotFound         = errors.New("broadcast output not found")
+	ErrBroadcastOutputAlreadyExists    = errors.New("broadcast output already exists")
+	ErrBroadcastOutputStarted          = errors.New("broadcast output is started")
 )
 
 type Sample struct {
@@ -33,17 +40,66 @@ type SampleListener interface {
 	WriteSample(Sample)
 }
 
+type BroadcastOutput struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	IsActive bool   `json:"is_active"`
+	// state of output pipeline, running while data is being sent
+	State   CapturePipelineState `json:"state"`
+	Retries int                  `json:"retries,omitempty"`
+	// last error, while output is being recovered
+	Error string `json:"error,omitempty"`
+	// seconds since output pipeline was started
+	Uptime float64 `json:"uptime,omitempty"`
+	// measured bitrate in kbit/s
+	Bitrate int `json:"bitrate,omitempty"`
+}
+
+// status of the default output, along with all named outputs
+type BroadcastStatus struct {
+	IsActive bool   `json:"is_active"`
+	URL      string `json:"url,omitempty"`
+	// health of the default output
+	Error   string  `json:"error,omitempty"`
+	Uptime  float64 `json:"uptime,omitempty"`
+	Bitrate int     `json:"bitrate,omitempty"`
+
+	Outputs []BroadcastOutput `json:"outputs,omitempty"`
+}
+
 type BroadcastManager interface {
+	// control the default output
 	Start(url string) error
 	Stop()
 	Started() bool
 	Url() string
+	Status() BroadcastStatus
+
+	// named outputs sharing the same encoder
+	Outputs() []BroadcastOutput
+	Output(name string) (BroadcastOutput, bool)
+	AddOutput(name string, url string) error
+	RemoveOutput(name string) error
+	StartOutput(name string) error
+	StopOutput(name string) error
 }
 
 type ScreencastManager interface {
 	Enabled() bool
 	Started() bool
 	Image() ([]byte, error)
+	Subscribe() (<-chan []byte, func(), error)
+}
+
+// name of the playlist, that lists segments relative to it
+const HlsPlaylistName = "playlist.m3u8"
+
+type HlsManager interface {
+	Enabled() bool
+	// segmenter is started on demand, playlist is returned once first segment is written
+	Playlist(ctx context.Context) ([]byte, error)
+	// path to the segment file listed in the playlist
+	Segment(name string) (string, error)
 }
 
 type StreamSelectorType int
@@ -108,6 +164,39 @@ type StreamSelectorManager interface {
 	Codec() codec.RTPCodec
 
 	GetStream(selector StreamSelector) (StreamSinkManager, bool)
+
+	// randr output captured by the stream, empty when whole screen is captured
+	ScreenOutput(id string) string
+	// region of the screen captured by the stream, zero when it is not set
+	ScreenRegion(id string) ScreenRegion
+
+	Pipelines() map[string]VideoConfig
+	AddPipeline(id string, config VideoConfig) error
+	UpdatePipeline(id string, config VideoConfig) error
+	RemovePipeline(id string) error
+
+	// listeners of removed or replaced streams are moved to another stream
+	OnStreamMoved(listener func(from, to StreamSinkManager))
+}
+
+type AudioSelectorManager interface {
+	IDs() []string
+	Codec() codec.RTPCodec
+
+	// only exact and nearest types are supported, nearest falls back to the default stream,
+	// multiple ids joined by + are mixed together (custom pipelines can not be mixed),
+	// volume in percent is applied by a separate pipeline, returned is also id of selected stream
+	// and applied volume, that is rounded and 0 if silent (custom pipelines are always at 100)
+	GetStream(selector StreamSelector, volume int) (stream StreamSinkManager, id string, applied int, ok bool)
+}
+
+type EncoderParams struct {
+	// bitrate in kbit/s
+	Bitrate *int `json:"bitrate,omitempty"`
+	// frames per second
+	Fps *int `json:"fps,omitempty"`
+	// keyframe interval in frames
+	KeyframeInterval *int `json:"keyframe_interval,omitempty"`
 }
 
 type StreamSinkManager interface {
@@ -124,10 +213,17 @@ type StreamSinkManager interface {
 
 	CreatePipeline() error
 	DestroyPipeline()
+
+	SetEncoderParams(params EncoderParams) error
+	EncoderParams() EncoderParams
+	// bitrate estimated for a single listener, reset when listeners change
+	SetEstimatedBitrate(bitrate int) error
+	EstimatedBitrate() (int, bool)
 }
 
 type StreamSrcManager interface {
 	Codec() codec.RTPCodec
+	Codecs() []codec.RTPCodec
 
 	Start(codec codec.RTPCodec) error
 	Stop()
@@ -136,30 +232,83 @@ type StreamSrcManager interface {
 	Started() bool
 }
 
+type CapturePipelineState string
+
+const (
+	CapturePipelineStopped    CapturePipelineState = "stopped"
+	CapturePipelineRunning    CapturePipelineState = "running"
+	CapturePipelineRecovering CapturePipelineState = "recovering"
+)
+
+type CapturePipelineStatus struct {
+	State CapturePipelineState `json:"state"`
+	// last error, that caused pipeline restart
+	Error string `json:"error,omitempty"`
+	// number of restarts since pipeline was last healthy
+	Retries int `json:"retries"`
+	// last known-good pipeline is used instead of configured one
+	Fallback bool `json:"fallback"`
+}
+
+type CapturePipelineDiagnostics struct {
+	// gstreamer state of the pipeline
+	State string `json:"state"`
+	// minimal latency of live pipeline in ms
+	Latency float64 `json:"latency"`
+
+	Errors    uint64 `json:"errors"`
+	LastError string `json:"last_error,omitempty"`
+
+	Warnings    uint64 `json:"warnings"`
+	LastWarning string `json:"last_warning,omitempty"`
+
+	// reported by elements through qos messages
+	ProcessedFrames uint64 `json:"processed_frames"`
+	DroppedFrames   uint64 `json:"dropped_frames"`
+}
+
 type CaptureManager interface {
 	Start()
 	Shutdown() error
 
+	// status of supervised pipelines, by their id
+	Status() map[string]CapturePipelineStatus
+	OnStatusChanged(listener func(id string, status CapturePipelineStatus))
+	// screen size was changed back, because video pipelines failed at the new one
+	OnScreenSizeReverted(listener func(size ScreenSize))
+	// bus messages and metrics of pipelines, by their id
+	Diagnostics() map[string]CapturePipelineDiagnostics
+
 	Broadcast() BroadcastManager
 	Screencast() ScreencastManager
+	Hls() HlsManager
+	// default audio stream
 	Audio() StreamSinkManager
+	Audios() AudioSelectorManager
 	Video() StreamSelectorManager
 
 	Webcam() StreamSrcManager
 	Microphone() StreamSrcManager
 }
 
+type AudioConfig struct {
+	Device      string `mapstructure:"device" json:"device,omitempty"`             // pulseaudio device to capture
+	GstPipeline string `mapstructure:"gst_pipeline" json:"gst_pipeline,omitempty"` // whole pipeline as a string
+}
+
 type VideoConfig struct {
-	Width       string            `mapstructure:"width"`        // expression
-	Height      string            `mapstructure:"height"`       // expression
-	Fps         string            `mapstructure:"fps"`          // expression
-	Bitrate     int               `mapstructure:"bitrate"`      // pipeline bitrate
-	GstPrefix   string            `mapstructure:"gst_prefix"`   // pipeline prefix, starts with !
-	GstEncoder  string            `mapstructure:"gst_encoder"`  // gst encoder name
-	GstParams   map[string]string `mapstructure:"gst_params"`   // map of expressions
-	GstSuffix   string            `mapstructure:"gst_suffix"`   // pipeline suffix, starts with !
-	GstPipeline string            `mapstructure:"gst_pipeline"` // whole pipeline as a string
-	ShowPointer bool              `mapstructure:"show_pointer"` // show pointer in the video
+	Width       string            `mapstructure:"width" json:"width,omitempty"`               // expression
+	Height      string            `mapstructure:"height" json:"height,omitempty"`             // expression
+	Fps         string            `mapstructure:"fps" json:"fps,omitempty"`                   // expression
+	Bitrate     int               `mapstructure:"bitrate" json:"bitrate,omitempty"`           // pipeline bitrate
+	GstPrefix   string            `mapstructure:"gst_prefix" json:"gst_prefix,omitempty"`     // pipeline prefix, starts with !
+	GstEncoder  string            `mapstructure:"gst_encoder" json:"gst_encoder,omitempty"`   // gst encoder name
+	GstParams   map[string]string `mapstructure:"gst_params" json:"gst_params,omitempty"`     // map of expressions
+	GstSuffix   string            `mapstructure:"gst_suffix" json:"gst_suffix,omitempty"`     // pipeline suffix, starts with !
+	GstPipeline string            `mapstructure:"gst_pipeline" json:"gst_pipeline,omitempty"` // whole pipeline as a string
+	ShowPointer bool              `mapstructure:"show_pointer" json:"show_pointer"`           // show pointer in the video
+	Output      string            `mapstructure:"output" json:"output,omitempty"`             // capture only this randr output
+	Region      *ScreenRegion     `mapstructure:"region" json:"region,omitempty"`             // capture only this region, not together with output
 }
 
 func (config *VideoConfig) GetPipeline(screen ScreenSize) (string, error) {
@@ -171,7 +320,14 @@ func (config *VideoConfig) GetPipeline(screen ScreenSize) (string, error) {
 
 	language := []gval.Language{
 		gval.Function("round", func(args ...any) (any, error) {
-			return (int)(math.Round(args[0].(float64))), nil
+			if len(args) != 1 {
+				return nil, fmt.Errorf("round expects exactly one argument")
+			}
+			val, ok := args[0].(float64)
+			if !ok {
+				return nil, fmt.Errorf("round expects number, got %T", args[0])
+			}
+			return (int)(math.Round(val)), nil
 		}),
 	}
 
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"image"
 )
@@ -24,6 +25,37 @@ func (s ScreenSize) String() string {
 	return fmt.Sprintf("%dx%d@%d", s.Width, s.Height, s.Rate)
 }
 
+var (
+	ErrScreenOutputNotFound  = errors.New("screen output not found")
+	ErrScreenSizeOutOfBounds = errors.New("screen size out of bounds")
+	ErrScreenRegionInvalid   = errors.New("screen region is invalid")
+)
+
+// part of the screen shown on a single monitor
+type ScreenOutput struct {
+	Name    string `json:"name"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Primary bool   `json:"primary"`
+}
+
+// part of the screen, either a static rectangle or a window that is followed,
+// zero value means whole screen
+type ScreenRegion struct {
+	X      int `mapstructure:"x" json:"x,omitempty"`
+	Y      int `mapstructure:"y" json:"y,omitempty"`
+	Width  int `mapstructure:"width" json:"width,omitempty"`
+	Height int `mapstructure:"height" json:"height,omitempty"`
+	// class or title of the followed window
+	Window string `mapstructure:"window" json:"window,omitempty"`
+}
+
+func (r ScreenRegion) IsZero() bool {
+	return r == ScreenRegion{}
+}
+
 type KeyboardModifiers struct {
 	Shift    *bool `json:"shift"`
 	CapsLock *bool `json:"capslock"`
@@ -36,8 +68,70 @@ type KeyboardModifiers struct {
 }
 
 type KeyboardMap struct {
+	// layouts and variants of multiple groups are separated by comma
 	Layout  string `json:"layout"`
 	Variant string `json:"variant"`
+	// index of active layout group
+	Group *int `json:"group,omitempty"`
+}
+
+type KeyboardVariant struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Languages   []string `json:"languages,omitempty"`
+}
+
+type KeyboardLayout struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Languages   []string          `json:"languages,omitempty"`
+	Variants    []KeyboardVariant `json:"variants"`
+}
+
+var ErrWindowNotFound = errors.New("window not found")
+
+var (
+	ErrAppNotFound       = errors.New("app not found")
+	ErrAppAlreadyRunning = errors.New("app is already running")
+	ErrAppNotRunning     = errors.New("app is not running")
+)
+
+const (
+	AppRestartNever     = "never"
+	AppRestartOnFailure = "on-failure"
+	AppRestartAlways    = "always"
+)
+
+type AppConfig struct {
+	Command   []string          `mapstructure:"command" json:"command"`
+	Env       map[string]string `mapstructure:"env" json:"env,omitempty"`
+	Autostart bool              `mapstructure:"autostart" json:"autostart,omitempty"`
+	Restart   string            `mapstructure:"restart" json:"restart,omitempty"` // never, on-failure or always
+}
+
+type AppStatus struct {
+	Name     string `json:"name"`
+	Running  bool   `json:"running"`
+	PID      int    `json:"pid,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	// in seconds, while running
+	Uptime   float64 `json:"uptime,omitempty"`
+	Restarts int     `json:"restarts"`
+	Restart  string  `json:"restart"`
+}
+
+type Window struct {
+	ID        uint32 `json:"id"`
+	Title     string `json:"title"`
+	Class     string `json:"class"`
+	PID       int    `json:"pid,omitempty"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Focused   bool   `json:"focused"`
+	Maximized bool   `json:"maximized"`
+	Minimized bool   `json:"minimized"`
 }
 
 type ClipboardText struct {
@@ -52,7 +146,9 @@ type DesktopManager interface {
 	OnAfterScreenSizeChange(listener func())
 
 	// xorg
+	// moves cursor to position relative to the input output or region
 	Move(x, y int)
+	// cursor position on the whole screen
 	GetCursorPosition() (int, int)
 	Scroll(deltaX, deltaY int, controlKey bool)
 	ButtonDown(code uint32) error
@@ -61,24 +157,67 @@ type DesktopManager interface {
 	KeyUp(code uint32) error
 	ButtonPress(code uint32) error
 	KeyPress(codes ...uint32) error
+	TypeText(text string) error
 	ResetKeys()
+	// input of sessions is ignored while it is locked, e.g. by input sequence,
+	// returns false if it is already locked
+	LockInput() bool
+	UnlockInput()
+	InputLocked() bool
 	ScreenConfigurations() []ScreenSize
 	SetScreenSize(ScreenSize) (ScreenSize, error)
+	// smallest and largest screen size that can be set
+	ScreenSizeBounds() (min ScreenSize, max ScreenSize)
 	GetScreenSize() ScreenSize
+	ScreenOutputs() []ScreenOutput
+	ScreenOutput(name string) (ScreenOutput, bool)
+	// current position of the region on the screen
+	ScreenRegionRect(region ScreenRegion) (image.Rectangle, error)
+	// input coordinates are relative to this output, empty means whole screen
+	SetInputOutput(name string) error
+	InputOutput() string
+	// input coordinates are relative to this region, zero means whole screen
+	SetInputRegion(region ScreenRegion) error
+	InputRegion() ScreenRegion
+	// translates input coordinates to position on the whole screen
+	InputToScreen(x, y int) (int, int)
 	SetKeyboardMap(KeyboardMap) error
 	GetKeyboardMap() (*KeyboardMap, error)
+	KeyboardLayouts() ([]KeyboardLayout, error)
 	SetKeyboardModifiers(mod KeyboardModifiers)
 	GetKeyboardModifiers() KeyboardModifiers
 	GetCursorImage() *CursorImage
 	GetScreenshotImage() *image.RGBA
+	Windows() []Window
+	Window(id uint32) (Window, error)
+	// window with matching class or title, focused one is preferred
+	FindWindow(match string) (Window, error)
+	FocusWindow(id uint32) error
+	MoveResizeWindow(id uint32, x, y, width, height int) error
+	MaximizeWindow(id uint32, maximize bool) error
+	CloseWindow(id uint32) error
 
 	// xevent
+	// counter of screen changes, zero when they are not tracked
+	GetScreenDamage() uint64
 	OnCursorChanged(listener func(serial uint64))
 	OnClipboardUpdated(listener func())
 	OnFileChooserDialogOpened(listener func())
 	OnFileChooserDialogClosed(listener func())
+	OnFilesDragged(listener func(files []string))
+	OnWindowOpened(listener func(id uint32))
+	OnWindowClosed(listener func(id uint32))
+	OnWindowFocused(listener func(id uint32))
 	OnEventError(listener func(error_code uint8, message string, request_code uint8, minor_code uint8))
 
+	// apps
+	Apps() []AppStatus
+	App(name string) (AppStatus, error)
+	AppStart(name string) error
+	AppStop(name string) error
+	AppRestart(name string) error
+	OnAppLog(listener func(name, stream, line string))
+
 	// input driver
 	HasTouchSupport() bool
 	TouchBegin(touchId uint32, x, y int, pressure uint8) error
@@ -95,6 +234,8 @@ type DesktopManager interface {
 	// drop
 	DropFiles(x int, y int, files []string) bool
 	IsUploadDropEnabled() bool
+	IsDownloadDragEnabled() bool
+	DownloadDragDir() string
 
 	// filechooser
 	HandleFileChooserDialog(uri string) error
@@ -43,6 +43,7 @@ const (
 	CONTROL_KEYPRESS = "control/keypress"
 	CONTROL_KEYDOWN  = "control/keydown"
 	CONTROL_KEYUP    = "control/keyup"
+	CONTROL_TYPE     = "control/type"
 	// touch
 	CONTROL_TOUCHBEGIN  = "control/touchbegin"
 	CONTROL_TOUCHUPDATE = "control/touchupdate"
@@ -55,8 +56,9 @@ const (
 )
 
 const (
-	SCREEN_UPDATED = "screen/updated"
-	SCREEN_SET     = "screen/set"
+	SCREEN_UPDATED  = "screen/updated"
+	SCREEN_SET      = "screen/set"
+	SCREEN_VIEWPORT = "screen/viewport"
 )
 
 const (
@@ -73,6 +75,22 @@ const (
 	BROADCAST_STATUS = "broadcast/status"
 )
 
+const (
+	CAPTURE_ENCODER   = "capture/encoder"
+	CAPTURE_PIPELINES = "capture/pipelines"
+	CAPTURE_STATUS    = "capture/status"
+)
+
+const (
+	WINDOW_OPENED  = "window/opened"
+	WINDOW_CLOSED  = "window/closed"
+	WINDOW_FOCUSED = "window/focused"
+)
+
+const (
+	INPUT_SEQUENCE_PROGRESS = "input/sequence/progress"
+)
+
 const (
 	SEND_UNICAST   = "send/unicast"
 	SEND_BROADCAST = "send/broadcast"
@@ -82,3 +100,7 @@ const (
 	FILE_CHOOSER_DIALOG_OPENED = "file_chooser_dialog/opened"
 	FILE_CHOOSER_DIALOG_CLOSED = "file_chooser_dialog/closed"
 )
+
+const (
+	DOWNLOAD_OFFER = "download/offer"
+)
@@ -1,6 +1,8 @@
 package message
 
 import (
+	"time"
+
 	"github.com/pion/webrtc/v3"
 
 	"m1k1o/neko/pkg/types"
@@ -11,13 +13,20 @@ import (
 /////////////////////////////
 
 type SystemWebRTC struct {
-	Videos []string `json:"videos"`
+	Videos      []string                      `json:"videos"`
+	Audios      []string                      `json:"audios"`
+	DataChannel types.DataChannelCapabilities `json:"data_channel"`
+	// screen output captured by each video, when not whole screen
+	VideoOutputs map[string]string `json:"video_outputs,omitempty"`
+	// screen region captured by each video, when it is set
+	VideoRegions map[string]types.ScreenRegion `json:"video_regions,omitempty"`
 }
 
 type SystemInit struct {
 	SessionId         string                 `json:"session_id"`
 	ControlHost       ControlHost            `json:"control_host"`
 	ScreenSize        types.ScreenSize       `json:"screen_size"`
+	ScreenOutputs     []types.ScreenOutput   `json:"screen_outputs,omitempty"`
 	Sessions          map[string]SessionData `json:"sessions"`
 	Settings          types.Settings         `json:"settings"`
 	TouchEvents       bool                   `json:"touch_events"`
@@ -27,6 +36,8 @@ type SystemInit struct {
 
 type SystemAdmin struct {
 	ScreenSizesList []types.ScreenSize `json:"screen_sizes_list"`
+	ScreenSizeMin   types.ScreenSize   `json:"screen_size_min"`
+	ScreenSizeMax   types.ScreenSize   `json:"screen_size_max"`
 	BroadcastStatus BroadcastStatus    `json:"broadcast_status"`
 }
 
@@ -54,6 +65,8 @@ type SystemSettingsUpdate struct {
 type SignalRequest struct {
 	Video types.PeerVideoRequest `json:"video"`
 	Audio types.PeerAudioRequest `json:"audio"`
+	// versioned datachannel messages supported by client
+	DataChannel types.DataChannelCapabilities `json:"data_channel,omitempty"`
 
 	Auto bool `json:"auto"` // TODO: Remove this
 }
@@ -146,6 +159,10 @@ type ControlKey struct {
 	Keysym uint32 `json:"keysym"`
 }
 
+type ControlType struct {
+	Text string `json:"text"`
+}
+
 type ControlTouch struct {
 	*ControlPos
 	TouchId  uint32 `json:"touch_id"`
@@ -189,9 +206,61 @@ type KeyboardModifiers struct {
 // Broadcast
 /////////////////////////////
 
-type BroadcastStatus struct {
-	IsActive bool   `json:"is_active"`
-	URL      string `json:"url,omitempty"`
+type BroadcastStatus = types.BroadcastStatus
+
+/////////////////////////////
+// Capture
+/////////////////////////////
+
+type CaptureEncoder struct {
+	VideoID string `json:"video_id"`
+	types.EncoderParams
+}
+
+type CapturePipelines struct {
+	Videos []string `json:"videos"`
+}
+
+type CaptureStatus struct {
+	ID string `json:"id"`
+	types.CapturePipelineStatus
+}
+
+/////////////////////////////
+// Window
+/////////////////////////////
+
+type WindowID struct {
+	ID uint32 `json:"id"`
+}
+
+/////////////////////////////
+// Input
+/////////////////////////////
+
+type InputSequence struct {
+	ID string `json:"id"`
+	// running, finished, cancelled or failed
+	State string `json:"state"`
+	// number of already executed actions
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	Error string `json:"error,omitempty"`
+}
+
+/////////////////////////////
+// Download
+/////////////////////////////
+
+type DownloadOffer struct {
+	Files []DownloadFile `json:"files"`
+}
+
+type DownloadFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
 /////////////////////////////
@@ -37,6 +37,9 @@ type SessionState struct {
 	WatchingSince *time.Time `json:"watching_since,omitempty"`
 	// when the session was last not watching
 	NotWatchingSince *time.Time `json:"not_watching_since,omitempty"`
+
+	// audio was muted by an admin
+	AudioMuted bool `json:"audio_muted"`
 }
 
 type Settings struct {
@@ -47,6 +50,8 @@ type Settings struct {
 	ImplicitHosting   bool `json:"implicit_hosting"`
 	InactiveCursors   bool `json:"inactive_cursors"`
 	MercifulReconnect bool `json:"merciful_reconnect"`
+	AudioMuted        bool `json:"audio_muted"`
+	ScreenAutoFit     bool `json:"screen_auto_fit"`
 
 	// plugin scope
 	Plugins PluginSettings `json:"plugins"`
@@ -72,6 +77,8 @@ type Session interface {
 	SetAsHostBy(session Session)
 	ClearHost()
 	PrivateModeEnabled() bool
+	IsAudioMuted() bool
+	SetAudioMuted(muted bool)
 
 	// cursor
 	SetCursor(cursor Cursor)
@@ -105,6 +112,7 @@ type SessionManager interface {
 
 	Broadcast(event string, payload any, exclude ...string)
 	AdminBroadcast(event string, payload any, exclude ...string)
+	HostsBroadcast(event string, payload any, exclude ...string)
 	InactiveCursorsBroadcast(event string, payload any, exclude ...string)
 
 	OnCreated(listener func(session Session))
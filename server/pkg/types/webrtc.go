@@ -7,9 +7,11 @@ import (
 )
 
 var (
-	ErrWebRTCDataChannelNotFound = errors.New("webrtc data channel not found")
-	ErrWebRTCConnectionNotFound  = errors.New("webrtc connection not found")
-	ErrWebRTCStreamNotFound      = errors.New("webrtc stream not found")
+	ErrWebRTCDataChannelNotFound    = errors.New("webrtc data channel not found")
+	ErrWebRTCConnectionNotFound     = errors.New("webrtc connection not found")
+	ErrWebRTCStreamNotFound         = errors.New("webrtc stream not found")
+	ErrWebRTCWhipNotEnabled         = errors.New("webrtc whip ingest not enabled")
+	ErrWebRTCDataChannelUnsupported = errors.New("webrtc data channel message not supported by client")
 )
 
 type ICEServer struct {
@@ -18,6 +20,9 @@ type ICEServer struct {
 	Credential string   `mapstructure:"credential" json:"credential,omitempty"`
 }
 
+// DataChannelCapabilities maps versioned datachannel messages to their highest supported version.
+type DataChannelCapabilities map[string]uint8
+
 type PeerVideo struct {
 	Disabled bool   `json:"disabled"`
 	ID       string `json:"id"`
@@ -32,11 +37,30 @@ type PeerVideoRequest struct {
 }
 
 type PeerAudio struct {
-	Disabled bool `json:"disabled"`
+	Disabled bool   `json:"disabled"`
+	Muted    bool   `json:"muted"`
+	ID       string `json:"id"`
+	Volume   int    `json:"volume"`
 }
 
 type PeerAudioRequest struct {
-	Disabled *bool `json:"disabled,omitempty"`
+	Disabled *bool           `json:"disabled,omitempty"`
+	Selector *StreamSelector `json:"selector,omitempty"`
+	// in percent, from 0 to 200
+	Volume *int `json:"volume,omitempty"`
+}
+
+// LatencyPercentiles are in milliseconds.
+type LatencyPercentiles struct {
+	Samples int     `json:"samples"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+}
+
+type PeerLatency struct {
+	RTT            LatencyPercentiles `json:"rtt"`
+	InputToDisplay LatencyPercentiles `json:"input_to_display"`
 }
 
 type WebRTCPeer interface {
@@ -51,10 +75,15 @@ type WebRTCPeer interface {
 	SetVideo(PeerVideoRequest) error
 	Video() PeerVideo
 	SetAudio(PeerAudioRequest) error
+	SetAudioMuted(muted bool)
 	Audio() PeerAudio
 
 	SendCursorPosition(x, y int) error
 	SendCursorImage(cur *CursorImage, img []byte) error
+	SetDataChannelCapabilities(capabilities DataChannelCapabilities)
+	SendClipboardText(text string) error
+
+	Latency() PeerLatency
 
 	Destroy()
 }
@@ -64,7 +93,12 @@ type WebRTCManager interface {
 	Shutdown() error
 
 	ICEServers() []ICEServer
+	DataChannelCapabilities() DataChannelCapabilities
 
 	CreatePeer(session Session) (*webrtc.SessionDescription, WebRTCPeer, error)
+	CreateWhipPeer(session Session, offer string) (*webrtc.SessionDescription, string, error)
+	DestroyWhipPeer(session Session, id string) error
+	DestroyWhipPeers(session Session)
+	// position on the whole screen, peers receive it relative to their video
 	SetCursorPosition(x, y int)
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"image"
+	"image/draw"
 	"image/jpeg"
 	"image/png"
 )
@@ -28,6 +29,59 @@ func CreateJPGImage(img *image.RGBA, quality int) ([]byte, error) {
 	return out.Bytes(), nil
 }
 
+func ImageToRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// scales image by averaging all source pixels covered by each target pixel
+func ScaleImage(src *image.RGBA, width int, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if width == srcWidth && height == srcHeight {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		y0 := y * srcHeight / height
+		y1 := max((y+1)*srcHeight/height, y0+1)
+
+		for x := 0; x < width; x++ {
+			x0 := x * srcWidth / width
+			x1 := max((x+1)*srcWidth/width, x0+1)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(bounds.Min.X+x0, bounds.Min.Y+sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
 func CreatePNGImageURI(img *image.RGBA) (string, error) {
 	data, err := CreatePNGImage(img)
 	if err != nil {
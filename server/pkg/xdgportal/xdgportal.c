@@ -0,0 +1,192 @@
+#include "xdgportal.h"
+
+// portal backends are called by the frontend on this path, requests are its children
+#define PORTAL_PATH "/org/freedesktop/portal/desktop"
+
+static DBusConnection *connection = NULL;
+
+static void PortalReadOptions(DBusMessageIter *iter, int *multiple, int *directory) {
+  DBusMessageIter dict;
+  dbus_message_iter_recurse(iter, &dict);
+
+  while (dbus_message_iter_get_arg_type(&dict) == DBUS_TYPE_DICT_ENTRY) {
+    DBusMessageIter entry, variant;
+    const char *key;
+
+    dbus_message_iter_recurse(&dict, &entry);
+    dbus_message_iter_get_basic(&entry, &key);
+    dbus_message_iter_next(&entry);
+    dbus_message_iter_recurse(&entry, &variant);
+
+    if (dbus_message_iter_get_arg_type(&variant) == DBUS_TYPE_BOOLEAN) {
+      dbus_bool_t value;
+      dbus_message_iter_get_basic(&variant, &value);
+
+      if (strcmp(key, "multiple") == 0) {
+        *multiple = value;
+      } else if (strcmp(key, "directory") == 0) {
+        *directory = value;
+      }
+    }
+
+    dbus_message_iter_next(&dict);
+  }
+}
+
+static DBusHandlerResult PortalMessage(DBusConnection *conn, DBusMessage *message, void *user_data) {
+  if (dbus_message_is_method_call(message, "org.freedesktop.impl.portal.FileChooser", "OpenFile")) {
+    DBusMessageIter iter;
+    const char *handle, *app_id, *parent_window, *title;
+    int multiple = 0, directory = 0;
+
+    if (!dbus_message_has_signature(message, "osssa{sv}") || !dbus_message_iter_init(message, &iter)) {
+      DBusMessage *error = dbus_message_new_error(message, DBUS_ERROR_INVALID_ARGS, "Invalid arguments");
+      dbus_connection_send(conn, error, NULL);
+      dbus_message_unref(error);
+      return DBUS_HANDLER_RESULT_HANDLED;
+    }
+
+    dbus_message_iter_get_basic(&iter, &handle);
+    dbus_message_iter_next(&iter);
+    dbus_message_iter_get_basic(&iter, &app_id);
+    dbus_message_iter_next(&iter);
+    dbus_message_iter_get_basic(&iter, &parent_window);
+    dbus_message_iter_next(&iter);
+    dbus_message_iter_get_basic(&iter, &title);
+    dbus_message_iter_next(&iter);
+    PortalReadOptions(&iter, &multiple, &directory);
+
+    // reply is sent once files are selected, message is kept until then
+    dbus_message_ref(message);
+    goPortalOpenFile(message, (char *) handle, (char *) app_id, (char *) title, multiple, directory);
+    return DBUS_HANDLER_RESULT_HANDLED;
+  }
+
+  // save dialogs are not provided, applications get them answered as cancelled
+  if (dbus_message_is_method_call(message, "org.freedesktop.impl.portal.FileChooser", "SaveFile") ||
+      dbus_message_is_method_call(message, "org.freedesktop.impl.portal.FileChooser", "SaveFiles")) {
+    if (!dbus_message_has_signature(message, "osssa{sv}")) {
+      DBusMessage *error = dbus_message_new_error(message, DBUS_ERROR_INVALID_ARGS, "Invalid arguments");
+      dbus_connection_send(conn, error, NULL);
+      dbus_message_unref(error);
+      return DBUS_HANDLER_RESULT_HANDLED;
+    }
+
+    dbus_message_ref(message);
+    PortalReply(message, PORTAL_RESPONSE_CANCELLED, NULL, 0);
+    return DBUS_HANDLER_RESULT_HANDLED;
+  }
+
+  if (dbus_message_is_method_call(message, "org.freedesktop.impl.portal.Request", "Close")) {
+    goPortalClose((char *) dbus_message_get_path(message));
+
+    DBusMessage *reply = dbus_message_new_method_return(message);
+    dbus_connection_send(conn, reply, NULL);
+    dbus_message_unref(reply);
+    return DBUS_HANDLER_RESULT_HANDLED;
+  }
+
+  return DBUS_HANDLER_RESULT_NOT_YET_HANDLED;
+}
+
+char *PortalConnect(char *name) {
+  static const DBusObjectPathVTable vtable = {
+    .message_function = PortalMessage,
+  };
+
+  DBusError error;
+  char *msg = NULL;
+
+  // replies are sent from other threads than the dispatching one
+  dbus_threads_init_default();
+  dbus_error_init(&error);
+
+  connection = dbus_bus_get_private(DBUS_BUS_SESSION, &error);
+  if (connection == NULL) {
+    msg = strdup(error.message);
+    dbus_error_free(&error);
+    return msg;
+  }
+
+  dbus_connection_set_exit_on_disconnect(connection, FALSE);
+
+  int ret = dbus_bus_request_name(connection, name, DBUS_NAME_FLAG_DO_NOT_QUEUE, &error);
+  if (dbus_error_is_set(&error)) {
+    msg = strdup(error.message);
+    dbus_error_free(&error);
+  } else if (ret != DBUS_REQUEST_NAME_REPLY_PRIMARY_OWNER) {
+    msg = strdup("name is already owned by another connection");
+  } else if (!dbus_connection_register_fallback(connection, PORTAL_PATH, &vtable, NULL)) {
+    msg = strdup("unable to register object path");
+  }
+
+  if (msg != NULL) {
+    PortalDisconnect();
+  }
+
+  return msg;
+}
+
+void PortalDisconnect() {
+  if (connection == NULL) return;
+
+  dbus_connection_close(connection);
+  dbus_connection_unref(connection);
+  connection = NULL;
+}
+
+int PortalDispatch(int timeout) {
+  return dbus_connection_read_write_dispatch(connection, timeout);
+}
+
+void PortalReply(DBusMessage *message, unsigned int response, char **uris, int size) {
+  DBusMessage *reply = dbus_message_new_method_return(message);
+  DBusMessageIter iter, results;
+
+  dbus_message_iter_init_append(reply, &iter);
+  dbus_message_iter_append_basic(&iter, DBUS_TYPE_UINT32, &response);
+  dbus_message_iter_open_container(&iter, DBUS_TYPE_ARRAY, "{sv}", &results);
+
+  if (size > 0) {
+    DBusMessageIter entry, variant, array;
+    const char *key = "uris";
+
+    dbus_message_iter_open_container(&results, DBUS_TYPE_DICT_ENTRY, NULL, &entry);
+    dbus_message_iter_append_basic(&entry, DBUS_TYPE_STRING, &key);
+    dbus_message_iter_open_container(&entry, DBUS_TYPE_VARIANT, "as", &variant);
+    dbus_message_iter_open_container(&variant, DBUS_TYPE_ARRAY, "s", &array);
+
+    for (int i = 0; i < size; i++) {
+      dbus_message_iter_append_basic(&array, DBUS_TYPE_STRING, &uris[i]);
+    }
+
+    dbus_message_iter_close_container(&variant, &array);
+    dbus_message_iter_close_container(&entry, &variant);
+    dbus_message_iter_close_container(&results, &entry);
+  }
+
+  dbus_message_iter_close_container(&iter, &results);
+
+  if (connection != NULL) {
+    dbus_connection_send(connection, reply, NULL);
+    dbus_connection_flush(connection);
+  }
+
+  dbus_message_unref(reply);
+  dbus_message_unref(message);
+}
+
+char **portalUrisMake(int size) {
+  return calloc(size, sizeof(char *));
+}
+
+void portalUrisSet(char **uris, char *uri, int n) {
+  uris[n] = uri;
+}
+
+void portalUrisFree(char **uris, int size) {
+  for (int i = 0; i < size; i++) {
+    free(uris[i]);
+  }
+  free(uris);
+}
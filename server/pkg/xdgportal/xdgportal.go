@@ -0,0 +1,145 @@
+package xdgportal
+
+/*
+#cgo pkg-config: dbus-1
+
+#include "xdgportal.h"
+*/
+import "C"
+
+import (
+	"errors"
+	"sync"
+	"unsafe"
+
+	"github.com/kataras/go-events"
+)
+
+// name under which file chooser backend is provided to the portal frontend
+const BusName = "org.freedesktop.impl.portal.desktop.neko"
+
+var ErrRequestNotFound = errors.New("portal request not found")
+
+var Emmiter events.EventEmmiter
+
+// file chooser requests waiting for a reply, by their handle
+var requests = map[string]*C.DBusMessage{}
+var mu = sync.Mutex{}
+
+type FileChooserRequest struct {
+	Handle    string
+	AppID     string
+	Title     string
+	Multiple  bool
+	Directory bool
+}
+
+func init() {
+	Emmiter = events.New()
+}
+
+func Connect() error {
+	nameUnsafe := C.CString(BusName)
+	defer C.free(unsafe.Pointer(nameUnsafe))
+
+	errUnsafe := C.PortalConnect(nameUnsafe)
+	if errUnsafe != nil {
+		defer C.free(unsafe.Pointer(errUnsafe))
+		return errors.New(C.GoString(errUnsafe))
+	}
+
+	return nil
+}
+
+// dispatches portal requests until shutdown, then cancels those that are left
+func EventLoop(shutdown <-chan struct{}) error {
+	defer C.PortalDisconnect()
+	defer func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for handle, message := range requests {
+			C.PortalReply(message, C.PORTAL_RESPONSE_CANCELLED, nil, 0)
+			delete(requests, handle)
+
+			go Emmiter.Emit("file-chooser-closed", handle)
+		}
+	}()
+
+	for {
+		select {
+		case <-shutdown:
+			return nil
+		default:
+		}
+
+		if C.PortalDispatch(100) == 0 {
+			return errors.New("disconnected from session bus")
+		}
+	}
+}
+
+// selected files are sent as uris to the application
+func Respond(handle string, uris []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	message, ok := requests[handle]
+	if !ok {
+		return ErrRequestNotFound
+	}
+	delete(requests, handle)
+
+	size := C.int(len(uris))
+	urisUnsafe := C.portalUrisMake(size)
+	defer C.portalUrisFree(urisUnsafe, size)
+
+	for i, uri := range uris {
+		C.portalUrisSet(urisUnsafe, C.CString(uri), C.int(i))
+	}
+
+	C.PortalReply(message, C.PORTAL_RESPONSE_SUCCESS, urisUnsafe, size)
+	return nil
+}
+
+func Cancel(handle string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	message, ok := requests[handle]
+	if !ok {
+		return ErrRequestNotFound
+	}
+	delete(requests, handle)
+
+	C.PortalReply(message, C.PORTAL_RESPONSE_CANCELLED, nil, 0)
+	return nil
+}
+
+//export goPortalOpenFile
+func goPortalOpenFile(message *C.DBusMessage, handle *C.char, appId *C.char, title *C.char, multiple C.int, directory C.int) {
+	request := FileChooserRequest{
+		Handle:    C.GoString(handle),
+		AppID:     C.GoString(appId),
+		Title:     C.GoString(title),
+		Multiple:  multiple != 0,
+		Directory: directory != 0,
+	}
+
+	mu.Lock()
+	requests[request.Handle] = message
+	mu.Unlock()
+
+	go Emmiter.Emit("file-chooser-opened", request)
+}
+
+// application closed the request, it is answered as cancelled
+//
+//export goPortalClose
+func goPortalClose(handle *C.char) {
+	if err := Cancel(C.GoString(handle)); err != nil {
+		return
+	}
+
+	go Emmiter.Emit("file-chooser-closed", C.GoString(handle))
+}
@@ -0,0 +1,27 @@
+#pragma once
+
+#include <dbus/dbus.h>
+#include <stdlib.h>
+#include <string.h>
+
+// responses defined by the portal request
+enum {
+  PORTAL_RESPONSE_SUCCESS,
+  PORTAL_RESPONSE_CANCELLED,
+  PORTAL_RESPONSE_OTHER
+};
+
+extern void goPortalOpenFile(DBusMessage *message, char *handle, char *app_id, char *title, int multiple, int directory);
+extern void goPortalClose(char *handle);
+
+static void PortalReadOptions(DBusMessageIter *iter, int *multiple, int *directory);
+static DBusHandlerResult PortalMessage(DBusConnection *conn, DBusMessage *message, void *user_data);
+
+char *PortalConnect(char *name);
+void PortalDisconnect();
+int PortalDispatch(int timeout);
+void PortalReply(DBusMessage *message, unsigned int response, char **uris, int size);
+
+char **portalUrisMake(int size);
+void portalUrisSet(char **uris, char *uri, int n);
+void portalUrisFree(char **uris, int size);
@@ -18,7 +18,7 @@ void XSetupErrorHandler() {
   XSetErrorHandler(XEventError);
 }
 
-void XEventLoop(char *name) {
+void XEventLoop(char *name, int drag_out) {
   Display *display = XOpenDisplay(name);
   Window root = DefaultRootWindow(display);
 
@@ -34,11 +34,56 @@ void XEventLoop(char *name) {
   Atom XA_CLIPBOARD = XInternAtom(display, "CLIPBOARD", 0);
   XFixesSelectSelectionInput(display, root, XA_CLIPBOARD, XFixesSetSelectionOwnerNotifyMask);
   XFixesSelectCursorInput(display, root, XFixesDisplayCursorNotifyMask);
-  XSelectInput(display, root, SubstructureNotifyMask);
+  XSelectInput(display, root, SubstructureNotifyMask | PropertyChangeMask | (drag_out ? StructureNotifyMask : 0));
+
+  // managed windows and their focus are tracked by the window manager
+  Atom NET_CLIENT_LIST = XInternAtom(display, "_NET_CLIENT_LIST", 0);
+  Atom NET_ACTIVE_WINDOW = XInternAtom(display, "_NET_ACTIVE_WINDOW", 0);
+  XEventClientList(display, root, NET_CLIENT_LIST);
+  XEventActiveWindow(display, root, NET_ACTIVE_WINDOW);
+
+  // screen changes are counted only when damage extension is available
+  int damage_event_base = -1, damage_error_base;
+  if (XDamageQueryExtension(display, &damage_event_base, &damage_error_base)) {
+    XDamageCreate(display, root, XDamageReportNonEmpty);
+    goXEventDamage();
+  } else {
+    damage_event_base = -1;
+  }
+
+  // files dragged out of applications are dropped onto a strip at the screen edge,
+  // it is mapped only while dragging so that it does not take clicks otherwise
+  Window drag_window = None, drag_source = None;
+  int drag_mapped = 0;
+  Atom XdndEnter = XInternAtom(display, "XdndEnter", 0);
+  Atom XdndPosition = XInternAtom(display, "XdndPosition", 0);
+  Atom XdndStatus = XInternAtom(display, "XdndStatus", 0);
+  Atom XdndLeave = XInternAtom(display, "XdndLeave", 0);
+  Atom XdndDrop = XInternAtom(display, "XdndDrop", 0);
+  Atom XdndFinished = XInternAtom(display, "XdndFinished", 0);
+  Atom XdndActionCopy = XInternAtom(display, "XdndActionCopy", 0);
+  Atom XdndSelection = XInternAtom(display, "XdndSelection", 0);
+  Atom TEXT_URI_LIST = XInternAtom(display, "text/uri-list", 0);
+  Atom NEKO_DRAG_OUT = XInternAtom(display, "NEKO_DRAG_OUT", 0);
+  if (drag_out) {
+    drag_window = XDragOutCreate(display, root);
+    // drag sources take ownership of the selection when dragging starts
+    XFixesSelectSelectionInput(display, root, XdndSelection, XFixesSetSelectionOwnerNotifyMask);
+  }
 
   XSync(display, 0);
 
   while (goXEventActive()) {
+    // strip is hidden once dragging ended without dropping, no button is held anymore
+    if (drag_mapped && drag_source == None && !XPending(display) && !XEventWait(display, 100)) {
+      if (!XDragOutHeld(display, root)) {
+        XUnmapWindow(display, drag_window);
+        XFlush(display);
+        drag_mapped = 0;
+      }
+      continue;
+    }
+
     XEvent event;
     XNextEvent(display, &event);
 
@@ -58,6 +103,49 @@ void XEventLoop(char *name) {
         goXEventClipboardUpdated();
         continue;
       }
+      if (drag_window != None && notifyEvent.subtype == XFixesSetSelectionOwnerNotify && notifyEvent.selection == XdndSelection) {
+        if (notifyEvent.owner != None && !drag_mapped) {
+          XDragOutPlace(display, root, drag_window);
+          XMapRaised(display, drag_window);
+          XFlush(display);
+          drag_mapped = 1;
+        }
+        continue;
+      }
+    }
+
+    // XDamageNotify
+    if (damage_event_base != -1 && event.type == damage_event_base + XDamageNotify) {
+      XDamageNotifyEvent *notifyEvent = (XDamageNotifyEvent *) &event;
+      // clear damaged region, so that next change is reported again
+      XDamageSubtract(display, notifyEvent->damage, None, None);
+      goXEventDamage();
+      continue;
+    }
+
+    // keep drop strip at the screen edge and above other windows
+    if (drag_window != None && event.type == ConfigureNotify && event.xconfigure.window == root) {
+      XDragOutPlace(display, root, drag_window);
+      continue;
+    }
+    if (drag_mapped && event.type == MapNotify && event.xmap.window != drag_window) {
+      XRaiseWindow(display, drag_window);
+      continue;
+    }
+
+    // SelectionNotify, dragged data converted to uri list
+    if (drag_window != None && event.type == SelectionNotify) {
+      if (event.xselection.requestor == drag_window && event.xselection.selection == XdndSelection) {
+        if (event.xselection.property != None) {
+          XDragOutSelection(display, drag_window, event.xselection.property);
+        }
+        if (drag_source != None) {
+          XDragOutMessage(display, drag_window, drag_source, XdndFinished,
+            event.xselection.property != None, XdndActionCopy);
+          drag_source = None;
+        }
+      }
+      continue;
     }
 
     // ConfigureNotify
@@ -75,6 +163,16 @@ void XEventLoop(char *name) {
       continue;
     }
 
+    // PropertyNotify
+    if (event.type == PropertyNotify) {
+      if (event.xproperty.window == root && event.xproperty.atom == NET_CLIENT_LIST) {
+        XEventClientList(display, root, NET_CLIENT_LIST);
+      } else if (event.xproperty.window == root && event.xproperty.atom == NET_ACTIVE_WINDOW) {
+        XEventActiveWindow(display, root, NET_ACTIVE_WINDOW);
+      }
+      continue;
+    }
+
     // UnmapNotify
     if (event.type == UnmapNotify) {
       Window window = event.xunmap.window;
@@ -86,6 +184,22 @@ void XEventLoop(char *name) {
     if (event.type == ClientMessage) {
       Window window = event.xclient.window;
 
+      // XDND protocol, see https://freedesktop.org/wiki/Specifications/XDND/
+      if (drag_window != None && window == drag_window) {
+        Atom type = event.xclient.message_type;
+        if (type == XdndEnter) {
+          drag_source = event.xclient.data.l[0];
+        } else if (type == XdndPosition && drag_source != None) {
+          XDragOutMessage(display, drag_window, drag_source, XdndStatus, 1, XdndActionCopy);
+        } else if (type == XdndLeave) {
+          drag_source = None;
+        } else if (type == XdndDrop && drag_source != None) {
+          Time drag_time = event.xclient.data.l[2];
+          XConvertSelection(display, XdndSelection, TEXT_URI_LIST, NEKO_DRAG_OUT, drag_window, drag_time);
+        }
+        continue;
+      }
+
       // check for net window manager state (fullscreen, maximized, etc)
       if (event.xclient.message_type == XInternAtom(display, "_NET_WM_STATE", 0)) {
         // see documentation in XWindowManagerStateEvent
@@ -142,6 +256,136 @@ void XEventLoop(char *name) {
   XCloseDisplay(display);
 }
 
+// waits for events up to timeout in milliseconds, returns whether there are any
+static int XEventWait(Display *display, int timeout) {
+  int fd = ConnectionNumber(display);
+  fd_set fds;
+  FD_ZERO(&fds);
+  FD_SET(fd, &fds);
+
+  struct timeval tv;
+  tv.tv_sec = timeout / 1000;
+  tv.tv_usec = (timeout % 1000) * 1000;
+
+  return select(fd + 1, &fds, NULL, NULL, &tv) > 0;
+}
+
+static void XEventClientList(Display *display, Window root, Atom property) {
+  Atom actual_type;
+  int actual_format;
+  unsigned long nitems = 0, bytes_after;
+  unsigned char *data = NULL;
+
+  if (XGetWindowProperty(display, root, property, 0, LONG_MAX, 0, XA_WINDOW,
+      &actual_type, &actual_format, &nitems, &bytes_after, &data) != Success) {
+    return;
+  }
+
+  goXEventClientList((Window *) data, data == NULL ? 0 : nitems);
+
+  if (data != NULL)
+    XFree(data);
+}
+
+static void XEventActiveWindow(Display *display, Window root, Atom property) {
+  Atom actual_type;
+  int actual_format;
+  unsigned long nitems = 0, bytes_after;
+  unsigned char *data = NULL;
+
+  if (XGetWindowProperty(display, root, property, 0, 1, 0, XA_WINDOW,
+      &actual_type, &actual_format, &nitems, &bytes_after, &data) != Success) {
+    return;
+  }
+
+  Window window = None;
+  if (data != NULL) {
+    if (nitems > 0)
+      window = ((Window *) data)[0];
+    XFree(data);
+  }
+
+  goXEventActiveWindow(window);
+}
+
+static Window XDragOutCreate(Display *display, Window root) {
+  XSetWindowAttributes attributes;
+  attributes.override_redirect = 1;
+
+  Window window = XCreateWindow(display, root, 0, 0, 1, 1, 0, 0, InputOnly,
+    CopyFromParent, CWOverrideRedirect, &attributes);
+
+  // announce support of XDND protocol version 5
+  Atom version = 5;
+  XChangeProperty(display, window, XInternAtom(display, "XdndAware", 0),
+    XA_ATOM, 32, PropModeReplace, (unsigned char *) &version, 1);
+
+  XDragOutPlace(display, root, window);
+  return window;
+}
+
+static void XDragOutPlace(Display *display, Window root, Window window) {
+  XWindowAttributes attributes;
+  XGetWindowAttributes(display, root, &attributes);
+
+  // thin strip along the right edge of the screen
+  int width = 2;
+  XMoveResizeWindow(display, window, attributes.width - width, 0, width, attributes.height);
+  XRaiseWindow(display, window);
+}
+
+static int XDragOutHeld(Display *display, Window root) {
+  Window root_return, child_return;
+  int root_x, root_y, win_x, win_y;
+  unsigned int mask;
+
+  if (!XQueryPointer(display, root, &root_return, &child_return, &root_x, &root_y, &win_x, &win_y, &mask)) {
+    return 0;
+  }
+
+  return (mask & (Button1Mask | Button2Mask | Button3Mask)) != 0;
+}
+
+static void XDragOutMessage(Display *display, Window window, Window source, Atom type, long status, Atom action) {
+  XClientMessageEvent clientMessageEvent;
+  memset(&clientMessageEvent, 0, sizeof(clientMessageEvent));
+
+  // XdndStatus: data.l[1] bit 0 = accept, data.l[4] = action
+  // XdndFinished: data.l[1] bit 0 = success, data.l[2] = action
+  clientMessageEvent.type         = ClientMessage;
+  clientMessageEvent.window       = source;
+  clientMessageEvent.message_type = type;
+  clientMessageEvent.format       = 32;
+  clientMessageEvent.data.l[0]    = window;
+  clientMessageEvent.data.l[1]    = status;
+  if (type == XInternAtom(display, "XdndStatus", 0)) {
+    clientMessageEvent.data.l[4]  = status ? action : None;
+  } else {
+    clientMessageEvent.data.l[2]  = status ? action : None;
+  }
+
+  XSendEvent(display, source, 0, NoEventMask, (XEvent *)&clientMessageEvent);
+  XFlush(display);
+}
+
+static void XDragOutSelection(Display *display, Window window, Atom property) {
+  Atom actual_type;
+  int actual_format;
+  unsigned long nitems = 0, bytes_after;
+  unsigned char *data = NULL;
+
+  if (XGetWindowProperty(display, window, property, 0, LONG_MAX, 1, AnyPropertyType,
+      &actual_type, &actual_format, &nitems, &bytes_after, &data) != Success) {
+    return;
+  }
+
+  if (data != NULL) {
+    if (actual_format == 8 && nitems > 0)
+      goXEventDragOut((char *) data);
+    XFree(data);
+  }
+}
+
 static void XWindowManagerStateEvent(Display *display, Window window, ulong action, ulong first, ulong second) {
   Window root = DefaultRootWindow(display);
 
@@ -1,7 +1,7 @@
 package xevent
 
 /*
-#cgo LDFLAGS: -lX11 -lXfixes
+#cgo LDFLAGS: -lX11 -lXfixes -lXdamage
 
 #include "xevent.h"
 */
@@ -9,6 +9,7 @@ import "C"
 
 import (
 	"strings"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/kataras/go-events"
@@ -17,8 +18,16 @@ import (
 var Emmiter events.EventEmmiter
 var Unminimize bool = false
 var FileChooserDialog bool = false
+var DragOut bool = false
 var fileChooserDialogWindow uint32 = 0
 
+// incremented on every screen change, zero when not tracked
+var damageCounter atomic.Uint64
+
+// accessed only from the event loop
+var clientWindows map[uint32]struct{}
+var activeWindow uint32
+
 func init() {
 	Emmiter = events.New()
 }
@@ -31,7 +40,12 @@ func EventLoop(display string) {
 	displayUnsafe := C.CString(display)
 	defer C.free(unsafe.Pointer(displayUnsafe))
 
-	C.XEventLoop(displayUnsafe)
+	dragOut := C.int(0)
+	if DragOut {
+		dragOut = 1
+	}
+
+	C.XEventLoop(displayUnsafe, dragOut)
 }
 
 //export goXEventCursorChanged
@@ -39,6 +53,15 @@ func goXEventCursorChanged(event C.XFixesCursorNotifyEvent) {
 	Emmiter.Emit("cursor-changed", uint64(event.cursor_serial))
 }
 
+func DamageCounter() uint64 {
+	return damageCounter.Load()
+}
+
+//export goXEventDamage
+func goXEventDamage() {
+	damageCounter.Add(1)
+}
+
 //export goXEventClipboardUpdated
 func goXEventClipboardUpdated() {
 	Emmiter.Emit("clipboard-updated")
@@ -74,6 +97,44 @@ func goXEventUnmapNotify(window C.Window) {
 	Emmiter.Emit("file-chooser-dialog-closed")
 }
 
+//export goXEventClientList
+func goXEventClientList(windows *C.Window, count C.int) {
+	current := map[uint32]struct{}{}
+	for _, window := range unsafe.Slice(windows, int(count)) {
+		current[uint32(window)] = struct{}{}
+	}
+
+	// windows existing before the event loop started are not reported
+	if clientWindows != nil {
+		for id := range current {
+			if _, ok := clientWindows[id]; !ok {
+				Emmiter.Emit("window-opened", id)
+			}
+		}
+
+		for id := range clientWindows {
+			if _, ok := current[id]; !ok {
+				Emmiter.Emit("window-closed", id)
+			}
+		}
+	}
+
+	clientWindows = current
+}
+
+//export goXEventActiveWindow
+func goXEventActiveWindow(window C.Window) {
+	id := uint32(window)
+	if id == activeWindow {
+		return
+	}
+
+	activeWindow = id
+	if id != 0 {
+		Emmiter.Emit("window-focused", id)
+	}
+}
+
 //export goXEventWMChangeState
 func goXEventWMChangeState(display *C.Display, window C.Window, window_state C.ulong) {
 	// if we just realized that window is minimized and we want it to be unminimized
@@ -85,6 +146,23 @@ func goXEventWMChangeState(display *C.Display, window C.Window, window_state C.u
 	}
 }
 
+//export goXEventDragOut
+func goXEventDragOut(data *C.char) {
+	// text/uri-list, one uri per line, lines starting with # are comments
+	uris := []string{}
+	for _, line := range strings.Split(C.GoString(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		uris = append(uris, line)
+	}
+
+	if len(uris) > 0 {
+		Emmiter.Emit("drag-out", uris)
+	}
+}
+
 //export goXEventError
 func goXEventError(event *C.XErrorEvent, message *C.char) {
 	Emmiter.Emit("event-error", uint8(event.error_code), C.GoString(message), uint8(event.request_code), uint8(event.minor_code))
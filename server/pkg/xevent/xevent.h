@@ -5,20 +5,37 @@
 #include <X11/Xlib.h>
 #include <X11/extensions/Xrandr.h>
 #include <X11/extensions/Xfixes.h>
+#include <X11/extensions/Xdamage.h>
 #include <stdlib.h>
 #include <string.h>
+#include <limits.h>
+#include <sys/select.h>
 
 extern void goXEventCursorChanged(XFixesCursorNotifyEvent event);
 extern void goXEventClipboardUpdated();
+extern void goXEventDamage();
 extern void goXEventConfigureNotify(Display *display, Window window, char *name, char *role);
 extern void goXEventUnmapNotify(Window window);
+extern void goXEventClientList(Window *windows, int count);
+extern void goXEventActiveWindow(Window window);
 extern void goXEventWMChangeState(Display *display, Window window, ulong state);
+extern void goXEventDragOut(char *uris);
 extern void goXEventError(XErrorEvent *event, char *message);
 extern int goXEventActive();
 
 static int XEventError(Display *display, XErrorEvent *event);
 void XSetupErrorHandler();
-void XEventLoop(char *display);
+void XEventLoop(char *display, int drag_out);
+
+static int XEventWait(Display *display, int timeout);
+static void XEventClientList(Display *display, Window root, Atom property);
+static void XEventActiveWindow(Display *display, Window root, Atom property);
+
+static Window XDragOutCreate(Display *display, Window root);
+static void XDragOutPlace(Display *display, Window root, Window window);
+static int XDragOutHeld(Display *display, Window root);
+static void XDragOutMessage(Display *display, Window window, Window source, Atom type, long status, Atom action);
+static void XDragOutSelection(Display *display, Window window, Atom property);
 
 static void XWindowManagerStateEvent(Display *display, Window window, ulong action, ulong first, ulong second);
 void XFileChooserHide(Display *display, Window window);
@@ -0,0 +1,98 @@
+package xorg
+
+/*
+#include "xorg.h"
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"m1k1o/neko/pkg/types"
+)
+
+// top-level windows managed by the window manager
+func GetWindows() []uint32 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var count C.int
+	windowsUnsafe := C.XGetWindows(&count)
+	if windowsUnsafe == nil {
+		return []uint32{}
+	}
+	defer C.XFree(unsafe.Pointer(windowsUnsafe))
+
+	windows := make([]uint32, 0, int(count))
+	for _, window := range unsafe.Slice(windowsUnsafe, int(count)) {
+		windows = append(windows, uint32(window))
+	}
+
+	return windows
+}
+
+func GetActiveWindow() uint32 {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return uint32(C.XGetActiveWindow())
+}
+
+func GetWindow(id uint32) (types.Window, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var info C.xwindowinfo_t
+	if C.XGetWindowInfo(C.Window(id), &info) == 0 {
+		return types.Window{}, false
+	}
+
+	defer C.free(unsafe.Pointer(info.title))
+	defer C.free(unsafe.Pointer(info.class))
+
+	return types.Window{
+		ID:        id,
+		Title:     C.GoString(info.title),
+		Class:     C.GoString(info.class),
+		PID:       int(info.pid),
+		X:         int(info.x),
+		Y:         int(info.y),
+		Width:     int(info.width),
+		Height:    int(info.height),
+		Maximized: info.maximized != 0,
+		Minimized: info.minimized != 0,
+	}, true
+}
+
+func FocusWindow(id uint32) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	C.XWindowFocus(C.Window(id))
+}
+
+func MoveResizeWindow(id uint32, x, y, width, height int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	C.XWindowMoveResize(C.Window(id), C.int(x), C.int(y), C.int(width), C.int(height))
+}
+
+func MaximizeWindow(id uint32, maximize bool) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var value C.int
+	if maximize {
+		value = 1
+	}
+
+	C.XWindowMaximize(C.Window(id), value)
+}
+
+func CloseWindow(id uint32) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	C.XWindowClose(C.Window(id))
+}
@@ -0,0 +1,74 @@
+package xorg
+
+import (
+	"encoding/xml"
+	"os"
+	"sync"
+
+	"m1k1o/neko/pkg/types"
+)
+
+// registry of layouts known to the xkb rules
+const xkbRulesRegistry = "/usr/share/X11/xkb/rules/evdev.xml"
+
+type xkbConfigItem struct {
+	Name        string   `xml:"name"`
+	Description string   `xml:"description"`
+	Languages   []string `xml:"languageList>iso639Id"`
+}
+
+type xkbConfigRegistry struct {
+	Layouts []struct {
+		ConfigItem xkbConfigItem `xml:"configItem"`
+		Variants   []struct {
+			ConfigItem xkbConfigItem `xml:"configItem"`
+		} `xml:"variantList>variant"`
+	} `xml:"layoutList>layout"`
+}
+
+var keyboardLayouts []types.KeyboardLayout
+var keyboardLayoutsErr error
+var keyboardLayoutsOnce sync.Once
+
+// layouts do not change at runtime, so they are loaded only once
+func GetKeyboardLayouts() ([]types.KeyboardLayout, error) {
+	keyboardLayoutsOnce.Do(func() {
+		keyboardLayouts, keyboardLayoutsErr = loadKeyboardLayouts(xkbRulesRegistry)
+	})
+
+	return keyboardLayouts, keyboardLayoutsErr
+}
+
+func loadKeyboardLayouts(path string) ([]types.KeyboardLayout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := xkbConfigRegistry{}
+	if err := xml.Unmarshal(data, &registry); err != nil {
+		return nil, err
+	}
+
+	layouts := make([]types.KeyboardLayout, 0, len(registry.Layouts))
+	for _, l := range registry.Layouts {
+		layout := types.KeyboardLayout{
+			Name:        l.ConfigItem.Name,
+			Description: l.ConfigItem.Description,
+			Languages:   l.ConfigItem.Languages,
+			Variants:    []types.KeyboardVariant{},
+		}
+
+		for _, v := range l.Variants {
+			layout.Variants = append(layout.Variants, types.KeyboardVariant{
+				Name:        v.ConfigItem.Name,
+				Description: v.ConfigItem.Description,
+				Languages:   v.ConfigItem.Languages,
+			})
+		}
+
+		layouts = append(layouts, layout)
+	}
+
+	return layouts, nil
+}
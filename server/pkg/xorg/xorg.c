@@ -150,7 +150,8 @@ KeyCode XkbKeysymToKeycode(Display* dpy, KeySym keysym) {
 }
 
 // From https://github.com/TigerVNC/tigervnc/blob/a434ef3377943e89165ac13c537cd0f28be97f84/unix/x0vncserver/XDesktop.cxx#L401-L453
-KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym) {
+// with oneLevel, keysym is not affected by modifiers (e.g. shift does not change its case)
+KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym, int oneLevel) {
   int types[1];
   unsigned int key;
   XkbDescPtr xkb;
@@ -169,8 +170,10 @@ KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym) {
   }
 
   // no free keycodes
-  if (key < xkb->min_key_code)
+  if (key < xkb->min_key_code) {
+    XkbFreeKeyboard(xkb, XkbAllComponentsMask, True);
     return 0;
+  }
 
   // assign empty structure
   changes = *(XkbMapChangesRec *) malloc(sizeof(XkbMapChangesRec));
@@ -178,6 +181,9 @@ KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym) {
 
   XConvertCase(keysym, &lower, &upper);
 
+  if (oneLevel)
+    upper = lower = keysym;
+
   if (upper == lower)
     types[XkbGroup1Index] = XkbOneLevelIndex;
   else
@@ -197,9 +203,10 @@ KeyCode XkbAddKeyKeysym(Display* dpy, KeySym keysym) {
   changes.first_key_sym = key;
   changes.num_key_syms = 1;
 
-  if (XkbChangeMap(dpy, xkb, &changes)) {
+  Bool ok = XkbChangeMap(dpy, xkb, &changes);
+  XkbFreeKeyboard(xkb, XkbAllComponentsMask, True);
+  if (ok)
     return key;
-  }
 
   return 0;
 }
@@ -220,7 +227,7 @@ void XKey(KeySym keysym, int down) {
 
   // Map non-existing keysyms to new keycodes
   if (keycode == 0)
-    keycode = XkbAddKeyKeysym(display, keysym);
+    keycode = XkbAddKeyKeysym(display, keysym, 0);
 
   if (down)
     XKeyEntryAdd(keysym, keycode);
@@ -229,6 +236,115 @@ void XKey(KeySym keysym, int down) {
   XSync(display, 0);
 }
 
+static KeyCode xTypeKeycodes[XTYPE_KEYCODES_MAX];
+static KeySym xTypeKeysyms[XTYPE_KEYCODES_MAX];
+static int xTypeCount = 0;
+
+// map keysym to a spare keycode with single level, so that modifiers do not change it
+static KeyCode XTypeMapKeysym(Display *dpy, KeySym keysym) {
+  if (xTypeCount >= XTYPE_KEYCODES_MAX)
+    return 0;
+
+  // mapped keycodes have a group, so they are not free anymore
+  KeyCode key = XkbAddKeyKeysym(dpy, keysym, 1);
+  if (key == 0)
+    return 0;
+
+  xTypeKeycodes[xTypeCount] = key;
+  xTypeKeysyms[xTypeCount] = keysym;
+  xTypeCount++;
+  return key;
+}
+
+// press and release keysym, returns 0 when there is no keycode available
+int XTypeKeysym(KeySym keysym) {
+  Display *display = getXDisplay();
+  KeyCode keycode = 0;
+
+  for (int i = 0; i < xTypeCount; i++) {
+    if (xTypeKeysyms[i] == keysym) {
+      keycode = xTypeKeycodes[i];
+      break;
+    }
+  }
+
+  // keysyms reachable in the current keyboard state are typed directly
+  if (keycode == 0)
+    keycode = XkbKeysymToKeycode(display, keysym);
+
+  if (keycode == 0)
+    keycode = XTypeMapKeysym(display, keysym);
+
+  if (keycode == 0)
+    return 0;
+
+  XTestFakeKeyEvent(display, keycode, 1, CurrentTime);
+  XTestFakeKeyEvent(display, keycode, 0, CurrentTime);
+  XSync(display, 0);
+  return 1;
+}
+
+// number of keycodes remapped while typing
+int XTypeMapped() {
+  return xTypeCount;
+}
+
+// restore all keycodes remapped while typing
+void XTypeReset() {
+  if (xTypeCount == 0)
+    return;
+
+  Display *display = getXDisplay();
+  XkbDescPtr xkb = XkbGetMap(display, XkbAllComponentsMask, XkbUseCoreKbd);
+  if (!xkb)
+    return;
+
+  for (int i = 0; i < xTypeCount; i++) {
+    XkbMapChangesRec changes;
+    memset(&changes, 0, sizeof(changes));
+    XkbChangeTypesOfKey(xkb, xTypeKeycodes[i], 0, XkbGroup1Mask, NULL, &changes);
+
+    changes.changed |= XkbKeySymsMask;
+    changes.first_key_sym = xTypeKeycodes[i];
+    changes.num_key_syms = 1;
+    XkbChangeMap(display, xkb, &changes);
+  }
+
+  XkbFreeKeyboard(xkb, XkbAllComponentsMask, True);
+  XSync(display, 0);
+  xTypeCount = 0;
+}
+
+// connected outputs with their position in the screen
+void XGetScreenOutputs() {
+  Display *display = getXDisplay();
+  Window root = DefaultRootWindow(display);
+
+  XRRScreenResources *resources = XRRGetScreenResourcesCurrent(display, root);
+  if (resources == NULL)
+    return;
+
+  RROutput primary = XRRGetOutputPrimary(display, root);
+
+  for (int i = 0; i < resources->noutput; i++) {
+    XRROutputInfo *output = XRRGetOutputInfo(display, resources, resources->outputs[i]);
+    if (output == NULL)
+      continue;
+
+    if (output->connection == RR_Connected && output->crtc != None) {
+      XRRCrtcInfo *crtc = XRRGetCrtcInfo(display, resources, output->crtc);
+      if (crtc != NULL) {
+        goAddScreenOutput(output->name, crtc->x, crtc->y, crtc->width, crtc->height, resources->outputs[i] == primary);
+        XRRFreeCrtcInfo(crtc);
+      }
+    }
+
+    XRRFreeOutputInfo(output);
+  }
+
+  XRRFreeScreenResources(resources);
+}
+
 Status XSetScreenConfiguration(int width, int height, short rate) {
   Display *display = getXDisplay();
   Window root = DefaultRootWindow(display);
@@ -371,6 +487,102 @@ unsigned char XGetKeyboardModifiers() {
   return XkbBuildCoreState(XkbStateMods(&xkbState), xkbState.group);
 }
 
+static void XkbRF_VarDefsFree(XkbRF_VarDefsRec *vd) {
+  free(vd->model);
+  free(vd->layout);
+  free(vd->variant);
+  free(vd->options);
+}
+
+// compiles new keymap from rules, the same way as setxkbmap does
+int XSetKeyboardLayout(char *layouts, char *variants) {
+  Display *display = getXDisplay();
+
+  char *rules = NULL;
+  XkbRF_VarDefsRec vd;
+  memset(&vd, 0, sizeof(vd));
+
+  if (!XkbRF_GetNamesProp(display, &rules, &vd) || rules == NULL) {
+    rules = strdup(XKB_RULES_DEFAULT);
+  }
+
+  // keep model and options, replace layouts
+  free(vd.layout);
+  free(vd.variant);
+  vd.layout = strdup(layouts);
+  vd.variant = strdup(variants);
+
+  char path[PATH_MAX];
+  snprintf(path, sizeof(path), "%s/%s", XKB_RULES_PATH, rules);
+
+  int ok = 0;
+  XkbRF_RulesPtr rdefs = XkbRF_Load(path, "", True, True);
+  if (rdefs != NULL) {
+    XkbComponentNamesRec comps;
+    memset(&comps, 0, sizeof(comps));
+
+    if (XkbRF_GetComponents(rdefs, &vd, &comps)) {
+      XkbDescPtr xkb = XkbGetKeyboardByName(display, XkbUseCoreKbd, &comps,
+        XkbGBN_AllComponentsMask, XkbGBN_AllComponentsMask & (~XkbGBN_GeometryMask), True);
+
+      if (xkb != NULL) {
+        // update names property, so that it can be queried later
+        XkbRF_SetNamesProp(display, rules, &vd);
+        XkbFreeKeyboard(xkb, XkbAllComponentsMask, True);
+        ok = 1;
+      }
+    }
+
+    free(comps.keymap);
+    free(comps.keycodes);
+    free(comps.types);
+    free(comps.compat);
+    free(comps.symbols);
+    free(comps.geometry);
+    XkbRF_Free(rdefs, True);
+  }
+
+  XkbRF_VarDefsFree(&vd);
+  free(rules);
+  XFlush(display);
+  return ok;
+}
+
+// returned strings must be freed
+int XGetKeyboardLayout(char **layouts, char **variants) {
+  Display *display = getXDisplay();
+
+  char *rules = NULL;
+  XkbRF_VarDefsRec vd;
+  memset(&vd, 0, sizeof(vd));
+
+  if (!XkbRF_GetNamesProp(display, &rules, &vd)) {
+    return 0;
+  }
+
+  *layouts = vd.layout;
+  *variants = vd.variant;
+  vd.layout = NULL;
+  vd.variant = NULL;
+
+  XkbRF_VarDefsFree(&vd);
+  free(rules);
+  return 1;
+}
+
+void XSetKeyboardGroup(int group) {
+  Display *display = getXDisplay();
+  XkbLockGroup(display, XkbUseCoreKbd, group);
+  XFlush(display);
+}
+
+int XGetKeyboardGroup() {
+  XkbStateRec xkbState;
+  Display *display = getXDisplay();
+  XkbGetState(display, XkbUseCoreKbd, &xkbState);
+  return xkbState.group;
+}
+
 XFixesCursorImage *XGetCursorImage(void) {
   Display *display = getXDisplay();
   return XFixesGetCursorImage(display);
@@ -405,3 +617,165 @@ char *XGetScreenshot(int *w, int *h) {
   XDestroyImage(ximage);
   return pixels;
 }
+
+static unsigned char *XGetWindowPropertyData(Display *display, Window window, char *name, Atom type, unsigned long *count) {
+  Atom property = XInternAtom(display, name, 1);
+  if (property == None)
+    return NULL;
+
+  Atom actual_type;
+  int actual_format;
+  unsigned long nitems, bytes_after;
+  unsigned char *data = NULL;
+
+  if (XGetWindowProperty(display, window, property, 0, LONG_MAX, 0, type,
+      &actual_type, &actual_format, &nitems, &bytes_after, &data) != Success) {
+    return NULL;
+  }
+
+  if (data != NULL && actual_type != type) {
+    XFree(data);
+    return NULL;
+  }
+
+  *count = nitems;
+  return data;
+}
+
+// client message handled by the window manager, as described in EWMH
+static void XSendWindowMessage(Display *display, Window window, char *type, long l0, long l1, long l2, long l3, long l4) {
+  XClientMessageEvent event;
+  memset(&event, 0, sizeof(event));
+
+  event.type         = ClientMessage;
+  event.window       = window;
+  event.message_type = XInternAtom(display, type, 0);
+  event.format       = 32;
+  event.data.l[0]    = l0;
+  event.data.l[1]    = l1;
+  event.data.l[2]    = l2;
+  event.data.l[3]    = l3;
+  event.data.l[4]    = l4;
+
+  XSendEvent(display, DefaultRootWindow(display), 0, SubstructureRedirectMask | SubstructureNotifyMask, (XEvent *)&event);
+  XSync(display, 0);
+}
+
+// top-level windows managed by the window manager, must be freed using XFree
+Window *XGetWindows(int *count) {
+  Display *display = getXDisplay();
+  unsigned long nitems = 0;
+
+  Window *windows = (Window *) XGetWindowPropertyData(display, DefaultRootWindow(display), "_NET_CLIENT_LIST", XA_WINDOW, &nitems);
+  *count = windows == NULL ? 0 : nitems;
+  return windows;
+}
+
+Window XGetActiveWindow() {
+  Display *display = getXDisplay();
+  unsigned long nitems = 0;
+
+  Window *data = (Window *) XGetWindowPropertyData(display, DefaultRootWindow(display), "_NET_ACTIVE_WINDOW", XA_WINDOW, &nitems);
+  if (data == NULL)
+    return None;
+
+  Window window = nitems > 0 ? data[0] : None;
+  XFree(data);
+  return window;
+}
+
+// title and class are allocated and must be freed
+int XGetWindowInfo(Window window, xwindowinfo_t *info) {
+  Display *display = getXDisplay();
+  Window root = DefaultRootWindow(display);
+  memset(info, 0, sizeof(xwindowinfo_t));
+
+  XWindowAttributes attr;
+  if (!XGetWindowAttributes(display, window, &attr))
+    return 0;
+
+  Window child;
+  XTranslateCoordinates(display, window, root, 0, 0, &info->x, &info->y, &child);
+  info->width = attr.width;
+  info->height = attr.height;
+
+  // prefer utf-8 title
+  unsigned long nitems = 0;
+  unsigned char *title = XGetWindowPropertyData(display, window, "_NET_WM_NAME", XInternAtom(display, "UTF8_STRING", 0), &nitems);
+  if (title != NULL) {
+    info->title = strndup((char *) title, nitems);
+    XFree(title);
+  } else {
+    char *name = NULL;
+    if (XFetchName(display, window, &name) && name != NULL) {
+      info->title = strdup(name);
+      XFree(name);
+    }
+  }
+
+  XClassHint hint;
+  if (XGetClassHint(display, window, &hint)) {
+    if (hint.res_class != NULL)
+      info->class = strdup(hint.res_class);
+    XFree(hint.res_name);
+    XFree(hint.res_class);
+  }
+
+  unsigned long *pid = (unsigned long *) XGetWindowPropertyData(display, window, "_NET_WM_PID", XA_CARDINAL, &nitems);
+  if (pid != NULL) {
+    if (nitems > 0)
+      info->pid = (int) pid[0];
+    XFree(pid);
+  }
+
+  Atom *state = (Atom *) XGetWindowPropertyData(display, window, "_NET_WM_STATE", XA_ATOM, &nitems);
+  if (state != NULL) {
+    Atom maximized_vert = XInternAtom(display, "_NET_WM_STATE_MAXIMIZED_VERT", 0);
+    Atom maximized_horz = XInternAtom(display, "_NET_WM_STATE_MAXIMIZED_HORZ", 0);
+    Atom hidden = XInternAtom(display, "_NET_WM_STATE_HIDDEN", 0);
+
+    int vert = 0, horz = 0;
+    for (unsigned long i = 0; i < nitems; i++) {
+      if (state[i] == maximized_vert) vert = 1;
+      if (state[i] == maximized_horz) horz = 1;
+      if (state[i] == hidden) info->minimized = 1;
+    }
+
+    info->maximized = vert && horz;
+    XFree(state);
+  }
+
+  return 1;
+}
+
+void XWindowFocus(Window window) {
+  Display *display = getXDisplay();
+
+  // source indication 2 means request from pager, so it is not ignored
+  XSendWindowMessage(display, window, "_NET_ACTIVE_WINDOW", 2, CurrentTime, 0, 0, 0);
+}
+
+void XWindowMoveResize(Window window, int x, int y, int width, int height) {
+  Display *display = getXDisplay();
+
+  // maximized windows can not be moved
+  XWindowMaximize(window, 0);
+
+  // static gravity, so that position is of the client window, all values set, source pager
+  long flags = StaticGravity | (0xF << 8) | (2 << 12);
+  XSendWindowMessage(display, window, "_NET_MOVERESIZE_WINDOW", flags, x, y, width, height);
+}
+
+void XWindowMaximize(Window window, int maximize) {
+  Display *display = getXDisplay();
+
+  XSendWindowMessage(display, window, "_NET_WM_STATE", maximize ? 1 : 0,
+    XInternAtom(display, "_NET_WM_STATE_MAXIMIZED_VERT", 0),
+    XInternAtom(display, "_NET_WM_STATE_MAXIMIZED_HORZ", 0), 2, 0);
+}
+
+void XWindowClose(Window window) {
+  Display *display = getXDisplay();
+
+  XSendWindowMessage(display, window, "_NET_CLOSE_WINDOW", CurrentTime, 2, 0, 0, 0);
+}
@@ -1,7 +1,7 @@
 package xorg
 
 /*
-#cgo LDFLAGS: -lX11 -lXrandr -lXtst -lXfixes -lxcvt
+#cgo LDFLAGS: -lX11 -lXrandr -lXtst -lXfixes -lxcvt -lxkbfile
 
 #include "xorg.h"
 */
@@ -42,6 +42,9 @@ type ScreenConfiguration struct {
 
 var ScreenConfigurations = make(map[int]ScreenConfiguration)
 
+// filled while outputs are being read
+var screenOutputs []types.ScreenOutput
+
 var debounce_button = make(map[uint32]time.Time)
 var debounce_key = make(map[uint32]time.Time)
 var mu = sync.Mutex{}
@@ -157,6 +160,81 @@ func KeyUp(code uint32) error {
 	return nil
 }
 
+// remapped keycodes are restored only after clients had time to process typed keys
+const typeResetDelay = 100 * time.Millisecond
+
+// restores remapped keycodes, postponed by every text typed in the meantime
+var typeResetTimer *time.Timer
+
+// RuneToKeysym converts unicode character to X keysym
+func RuneToKeysym(r rune) uint32 {
+	switch r {
+	case '\b':
+		return 0xff08 // XK_BackSpace
+	case '\t':
+		return 0xff09 // XK_Tab
+	case '\n', '\r':
+		return 0xff0d // XK_Return
+	case 0x1b:
+		return 0xff1b // XK_Escape
+	}
+
+	// latin-1 characters map directly to keysyms
+	if (r >= 0x20 && r <= 0x7e) || (r >= 0xa0 && r <= 0xff) {
+		return uint32(r)
+	}
+
+	// other unicode characters use unicode keysyms
+	return 0x01000000 | uint32(r)
+}
+
+// TypeText types text independently of the current keyboard layout,
+// characters missing in the layout are temporarily mapped to spare keycodes
+func TypeText(text string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	defer scheduleTypeReset()
+
+	for _, r := range text {
+		keysym := C.KeySym(RuneToKeysym(r))
+		if C.XTypeKeysym(keysym) != 0 {
+			continue
+		}
+
+		// all spare keycodes are in use, release them and try again
+		mu.Unlock()
+		time.Sleep(typeResetDelay)
+		mu.Lock()
+		C.XTypeReset()
+
+		if C.XTypeKeysym(keysym) == 0 {
+			return fmt.Errorf("no spare keycode available for %q", r)
+		}
+	}
+
+	return nil
+}
+
+// restore remapped keycodes later, without blocking other input
+func scheduleTypeReset() {
+	if C.XTypeMapped() == 0 {
+		return
+	}
+
+	if typeResetTimer != nil {
+		typeResetTimer.Reset(typeResetDelay)
+		return
+	}
+
+	typeResetTimer = time.AfterFunc(typeResetDelay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+
+		C.XTypeReset()
+	})
+}
+
 func ResetKeys() {
 	mu.Lock()
 	defer mu.Unlock()
@@ -270,6 +348,53 @@ func GetKeyboardModifiers() KbdMod {
 	return KbdMod(C.XGetKeyboardModifiers())
 }
 
+// layouts and variants of multiple groups are separated by comma
+func SetKeyboardLayout(layouts string, variants string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	layoutsUnsafe := C.CString(layouts)
+	defer C.free(unsafe.Pointer(layoutsUnsafe))
+
+	variantsUnsafe := C.CString(variants)
+	defer C.free(unsafe.Pointer(variantsUnsafe))
+
+	if C.XSetKeyboardLayout(layoutsUnsafe, variantsUnsafe) != 1 {
+		return fmt.Errorf("unable to set keyboard layout %q with variant %q", layouts, variants)
+	}
+
+	return nil
+}
+
+func GetKeyboardLayout() (string, string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var layoutsUnsafe, variantsUnsafe *C.char
+	if C.XGetKeyboardLayout(&layoutsUnsafe, &variantsUnsafe) != 1 {
+		return "", "", fmt.Errorf("unable to get keyboard layout")
+	}
+
+	defer C.free(unsafe.Pointer(layoutsUnsafe))
+	defer C.free(unsafe.Pointer(variantsUnsafe))
+
+	return C.GoString(layoutsUnsafe), C.GoString(variantsUnsafe), nil
+}
+
+func SetKeyboardGroup(group int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	C.XSetKeyboardGroup(C.int(group))
+}
+
+func GetKeyboardGroup() int {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return int(C.XGetKeyboardGroup())
+}
+
 func GetCursorImage() *types.CursorImage {
 	mu.Lock()
 	defer mu.Unlock()
@@ -336,6 +461,28 @@ func GetScreenshotImage() *image.RGBA {
 	return img
 }
 
+func GetScreenOutputs() []types.ScreenOutput {
+	mu.Lock()
+	defer mu.Unlock()
+
+	screenOutputs = []types.ScreenOutput{}
+	C.XGetScreenOutputs()
+
+	return screenOutputs
+}
+
+//export goAddScreenOutput
+func goAddScreenOutput(name *C.char, x C.int, y C.int, width C.int, height C.int, primary C.int) {
+	screenOutputs = append(screenOutputs, types.ScreenOutput{
+		Name:    C.GoString(name),
+		X:       int(x),
+		Y:       int(y),
+		Width:   int(width),
+		Height:  int(height),
+		Primary: primary != 0,
+	})
+}
+
 //export goCreateScreenSize
 func goCreateScreenSize(index C.int, width C.int, height C.int, mwidth C.int, mheight C.int) {
 	ScreenConfigurations[int(index)] = ScreenConfiguration{
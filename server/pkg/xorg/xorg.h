@@ -3,18 +3,26 @@
 #include <X11/Xlib.h>
 #include <X11/XKBlib.h>
 #include <X11/Xutil.h>
+#include <X11/Xatom.h>
 #include <X11/extensions/Xrandr.h>
 #include <X11/extensions/XTest.h>
 #include <X11/extensions/Xfixes.h>
+#include <X11/extensions/XKBrules.h>
 #include <stdlib.h>
 #include <stdio.h>
 #include <string.h>
+#include <limits.h>
 
 // for computing xrandr modelines at runtime
 #include <libxcvt/libxcvt.h>
 
+// default xkb rules, when they are not set on the display
+#define XKB_RULES_PATH "/usr/share/X11/xkb/rules"
+#define XKB_RULES_DEFAULT "evdev"
+
 extern void goCreateScreenSize(int index, int width, int height, int mwidth, int mheight);
 extern void goSetScreenRates(int index, int rate_index, short rate);
+extern void goAddScreenOutput(char *name, int x, int y, int width, int height, int primary);
 
 Display *getXDisplay(void);
 int XDisplayOpen(char *input);
@@ -34,16 +42,50 @@ typedef struct xkeyentry_t {
 static void XKeyEntryAdd(KeySym keysym, KeyCode keycode);
 static KeyCode XKeyEntryGet(KeySym keysym);
 static KeyCode XkbKeysymToKeycode(Display *dpy, KeySym keysym);
+static KeyCode XkbAddKeyKeysym(Display *dpy, KeySym keysym, int oneLevel);
 void XKey(KeySym keysym, int down);
 
+// spare keycodes temporarily remapped while typing text
+#define XTYPE_KEYCODES_MAX 32
+
+int XTypeKeysym(KeySym keysym);
+int XTypeMapped();
+void XTypeReset();
+
 Status XSetScreenConfiguration(int width, int height, short rate);
 void XGetScreenConfiguration(int *width, int *height, short *rate);
 void XGetScreenConfigurations();
+void XGetScreenOutputs();
 void XCreateScreenMode(int width, int height, short rate);
 XRRModeInfo *XCreateScreenModeInfo(int hdisplay, int vdisplay, short vrefresh);
 
 void XSetKeyboardModifier(unsigned char mod, int on);
 unsigned char XGetKeyboardModifiers();
+
+int XSetKeyboardLayout(char *layouts, char *variants);
+int XGetKeyboardLayout(char **layouts, char **variants);
+void XSetKeyboardGroup(int group);
+int XGetKeyboardGroup();
 XFixesCursorImage *XGetCursorImage(void);
 
 char *XGetScreenshot(int *w, int *h);
+
+typedef struct xwindowinfo_t {
+  char *title;
+  char *class;
+  int pid;
+  int x, y;
+  int width, height;
+  int maximized;
+  int minimized;
+} xwindowinfo_t;
+
+static unsigned char *XGetWindowPropertyData(Display *display, Window window, char *name, Atom type, unsigned long *count);
+static void XSendWindowMessage(Display *display, Window window, char *type, long l0, long l1, long l2, long l3, long l4);
+Window *XGetWindows(int *count);
+Window XGetActiveWindow();
+int XGetWindowInfo(Window window, xwindowinfo_t *info);
+void XWindowFocus(Window window);
+void XWindowMoveResize(Window window, int x, int y, int width, int height);
+void XWindowMaximize(Window window, int maximize);
+void XWindowClose(Window window);